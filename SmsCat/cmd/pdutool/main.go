@@ -0,0 +1,207 @@
+// Command pdutool decodes hex SMS PDUs into readable fields. It takes PDUs
+// as arguments, or scans log files (or stdin) for the PDUs SmsCat logs, so
+// a message the carrier rejected can be checked field by field.
+//
+//	pdutool 0011000B916407281553F80000AA0AE8329BFD4697D9EC37
+//	pdutool smscat.log
+//	pdutool < smscat.log
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"smallNfast/internal/pdu"
+)
+
+var (
+	// loggedPDU matches the PDU in lines such as
+	// "SMS [part 1/2]: PDU hex = 0041... (Length parameter = 140)".
+	loggedPDU = regexp.MustCompile(`PDU hex = ([0-9A-Fa-f]+)`)
+	// hexRun matches other candidates, e.g. +CMGL bodies or +CDS reports.
+	hexRun = regexp.MustCompile(`\b[0-9A-Fa-f]{24,}\b`)
+)
+
+func main() {
+	all := flag.Bool("all", false, "when scanning logs, also report hex runs that fail to decode")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pdutool [-all] [PDU | logfile | -]...")
+		fmt.Fprintln(os.Stderr, "Without arguments, the log is read from stdin.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	failed := false
+	for _, arg := range args {
+		switch {
+		case arg == "-":
+			failed = scan(os.Stdin, "stdin", *all) || failed
+		case isHex(arg):
+			failed = !decode(os.Stdout, arg, "") || failed
+		default:
+			f, err := os.Open(arg)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "pdutool:", err)
+				failed = true
+				continue
+			}
+			failed = scan(f, arg, *all) || failed
+			f.Close()
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// scan decodes the PDUs found in a log. Logged "PDU hex" values are always
+// reported; other hex runs only when they decode, unless all is set. It
+// reports whether reading failed.
+func scan(r io.Reader, name string, all bool) bool {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := sc.Text()
+		where := fmt.Sprintf("%s:%d", name, line)
+		if m := loggedPDU.FindStringSubmatch(text); m != nil {
+			decode(os.Stdout, m[1], where)
+			continue
+		}
+		for _, h := range hexRun.FindAllString(text, -1) {
+			if len(h)%2 != 0 {
+				continue
+			}
+			if _, err := pdu.Decode(h); err == nil || all {
+				decode(os.Stdout, h, where)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "pdutool: %s: %v\n", name, err)
+		return true
+	}
+	return false
+}
+
+// decode prints the fields of one PDU and reports whether it decoded.
+func decode(w io.Writer, pduHex, where string) bool {
+	if where != "" {
+		fmt.Fprintf(w, "%s\n", where)
+	}
+	fmt.Fprintf(w, "PDU %s\n", strings.ToUpper(pduHex))
+	msg, err := pdu.Decode(pduHex)
+	switch m := msg.(type) {
+	case *pdu.Submit:
+		field(w, "Type", pdu.TypeSubmit)
+		field(w, "SMSC", smsc(m.SMSC))
+		field(w, "Recipient", m.Recipient)
+		field(w, "Message ref", fmt.Sprintf("%d", m.MessageRef))
+		field(w, "Flags", flags(map[string]bool{
+			"reject duplicates":       m.RejectDuplicates,
+			"reply path":              m.ReplyPath,
+			"status report requested": m.StatusReportRequest,
+		}))
+		field(w, "PID", fmt.Sprintf("%02X", m.PID))
+		field(w, "Validity", m.Validity.String())
+		userData(w, &m.UserData)
+	case *pdu.Deliver:
+		field(w, "Type", pdu.TypeDeliver)
+		field(w, "SMSC", smsc(m.SMSC))
+		field(w, "Sender", m.Sender)
+		field(w, "Timestamp", timestamp(m.Timestamp))
+		field(w, "Flags", flags(map[string]bool{
+			"more messages":           m.MoreMessages,
+			"reply path":              m.ReplyPath,
+			"status report indicated": m.StatusReportIndication,
+		}))
+		field(w, "PID", fmt.Sprintf("%02X", m.PID))
+		userData(w, &m.UserData)
+	case *pdu.StatusReport:
+		field(w, "Type", pdu.TypeStatusReport)
+		field(w, "SMSC", smsc(m.SMSC))
+		field(w, "Recipient", m.Recipient)
+		field(w, "Message ref", fmt.Sprintf("%d", m.MessageRef))
+		field(w, "Submitted", timestamp(m.SubmitTime))
+		field(w, "Discharged", timestamp(m.Discharge))
+		field(w, "Status", fmt.Sprintf("%02X (%s)", m.Status, m.State()))
+		if m.Text != "" || m.UDH != nil {
+			userData(w, &m.UserData)
+		}
+	}
+	if err != nil {
+		field(w, "Error", err.Error())
+	}
+	fmt.Fprintln(w)
+	return err == nil
+}
+
+func userData(w io.Writer, u *pdu.UserData) {
+	dcs := fmt.Sprintf("%02X (%s", u.DCS, u.Alphabet())
+	if class := pdu.MessageClass(u.DCS); class >= 0 {
+		dcs += fmt.Sprintf(", class %d", class)
+	}
+	field(w, "DCS", dcs+")")
+	for _, ie := range u.UDH {
+		field(w, "UDH", fmt.Sprintf("IE %02X: % X", ie.ID, ie.Data))
+	}
+	if ref, total, seq, ok := u.UDH.Concat(); ok {
+		field(w, "Part", fmt.Sprintf("%d/%d (ref %d)", seq, total, ref))
+	}
+	field(w, "Text", fmt.Sprintf("%q", u.Text))
+}
+
+func field(w io.Writer, name, value string) {
+	fmt.Fprintf(w, "  %-12s %s\n", name+":", value)
+}
+
+func smsc(number string) string {
+	if number == "" {
+		return "(from SIM)"
+	}
+	return number
+}
+
+func timestamp(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05 -07:00")
+}
+
+// flags lists the set flags in a stable order.
+func flags(set map[string]bool) string {
+	var on []string
+	for _, name := range []string{
+		"more messages", "reject duplicates", "reply path",
+		"status report requested", "status report indicated",
+	} {
+		if set[name] {
+			on = append(on, name)
+		}
+	}
+	if len(on) == 0 {
+		return "none"
+	}
+	return strings.Join(on, ", ")
+}
+
+func isHex(s string) bool {
+	if len(s) == 0 || len(s)%2 != 0 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -19,6 +19,39 @@ const i18n = {
         error: "Error !",
         restarting: "Restarting...",
         port: "Port:",
+        signal: "Signal:",
+        notRegistered: "Not registered",
+        noSignal: "No signal",
+        unlockSim: "Unlock SIM",
+        smsc: "SMSC:",
+        smscNone: "not set",
+        smscFromConfig: "from smscat.properties (sms.smsc); click to change the SIM's SMSC",
+        smscFromSim: "stored on the SIM; click to change",
+        enterSmsc: "New SMSC address for the SIM (e.g. +8613800100500):",
+        smscFailed: "Failed to set SMSC: ",
+        pinLeft: "PIN attempts left",
+        pukLeft: "PUK attempts left",
+        enterPin: "Enter the SIM PIN:",
+        enterPuk: "The SIM is PUK-locked. Enter the PUK:",
+        enterNewPin: "Enter a new PIN for the SIM:",
+        unlockFailed: "Unlock failed: ",
+        modemStates: { healthy: "OK", down: "failing", unchecked: "not checked yet" },
+        modemCounts: "sent %s, failed %s",
+        modemResets: "reset %s time(s) by the watchdog",
+        balance: "Credit:",
+        balanceLow: "Low credit",
+        balanceCheck: "click to check the credit now",
+        balanceFailed: "Balance check failed: ",
+        storage: "Storage:",
+        storagePolicy: "Delete read/sent messages after %s day(s) or above %s%% full. Click to change.",
+        storagePolicyOff: "Read/sent messages are never deleted. Click to set up a cleanup.",
+        enterMaxAge: "Delete read and sent messages older than how many days? (0 = never)",
+        enterMaxPercent: "Delete the oldest ones when the storage is fuller than what percentage? (0 = never)",
+        storageFailed: "Storage cleanup failed: ",
+        clock: "Clock:",
+        clockSynced: "in sync",
+        clockDrift: "drifting",
+        clockDetail: "Network time from %s; host clock %s, database clock %s",
         autoStart: "Auto-Start on OS Bootup",
         restart: "Restart Service",
         exit: "Exit Application",
@@ -46,6 +79,20 @@ const i18n = {
         testSmsSend: "Send Test SMS",
         testSmsSending: "Sending...",
         testSmsOk: "✓ Sent successfully!",
+        tabInbox: "Inbox",
+        inboxEmpty: "No messages received.",
+        inboxIncomplete: "(incomplete)",
+        inboxDeleteConfirm: "Delete this message?",
+        tabDelivery: "Delivery",
+        deliveryEmpty: "No messages sent yet.",
+        deliveryTest: "Test message",
+        deliveryAlarm: "Alarm",
+        deliveryStates: { delivered: "Delivered", pending: "Pending", failed: "Failed", sent: "Sent" },
+        tabConsole: "AT Console",
+        consoleSend: "Send",
+        consoleExport: "Export",
+        consolePreferred: "Preferred modem",
+        consoleExported: "Transcript saved to %s",
     },
     cn: {
         monitorService: "SMSCat 服务:",
@@ -55,6 +102,39 @@ const i18n = {
         error: "错误 !",
         restarting: "重启中...",
         port: "端口:",
+        signal: "信号:",
+        notRegistered: "未注册",
+        noSignal: "无信号",
+        unlockSim: "解锁 SIM",
+        smsc: "短信中心:",
+        smscNone: "未设置",
+        smscFromConfig: "来自 smscat.properties (sms.smsc)；点击修改 SIM 卡短信中心",
+        smscFromSim: "存储于 SIM 卡；点击修改",
+        enterSmsc: "请输入 SIM 卡新的短信中心号码 (例如 +8613800100500):",
+        smscFailed: "设置短信中心失败: ",
+        pinLeft: "PIN 剩余次数",
+        pukLeft: "PUK 剩余次数",
+        enterPin: "请输入 SIM 卡 PIN 码:",
+        enterPuk: "SIM 卡已被 PUK 锁定，请输入 PUK 码:",
+        enterNewPin: "请输入新的 SIM 卡 PIN 码:",
+        unlockFailed: "解锁失败: ",
+        modemStates: { healthy: "正常", down: "故障", unchecked: "尚未检查" },
+        modemCounts: "成功 %s，失败 %s",
+        modemResets: "看门狗已重启 %s 次",
+        balance: "余额:",
+        balanceLow: "余额不足",
+        balanceCheck: "点击立即查询余额",
+        balanceFailed: "余额查询失败: ",
+        storage: "短信存储:",
+        storagePolicy: "已读/已发短信保留 %s 天，超过 %s%% 时删除最早的。点击修改。",
+        storagePolicyOff: "已读/已发短信不会被删除。点击设置清理规则。",
+        enterMaxAge: "已读和已发短信保留多少天？(0 = 不删除)",
+        enterMaxPercent: "存储超过百分之多少时删除最早的短信？(0 = 不删除)",
+        storageFailed: "短信存储清理失败: ",
+        clock: "时钟:",
+        clockSynced: "正常",
+        clockDrift: "偏差过大",
+        clockDetail: "网络时间来自 %s；本机时钟 %s，数据库时钟 %s",
         autoStart: "开机自动启动",
         restart: "重启程序",
         relaunch: "重启应用",
@@ -84,6 +164,15 @@ const i18n = {
         testSmsSend: "发送测试短信",
         testSmsSending: "发送中...",
         testSmsOk: "✓ 发送成功!",
+        tabInbox: "收件箱",
+        inboxEmpty: "暂无收到的短信。",
+        inboxIncomplete: "(不完整)",
+        inboxDeleteConfirm: "确认删除此短信?",
+        tabDelivery: "送达状态",
+        deliveryEmpty: "暂无已发送的短信。",
+        deliveryTest: "测试短信",
+        deliveryAlarm: "报警",
+        deliveryStates: { delivered: "已送达", pending: "等待中", failed: "失败", sent: "已发送" },
     }
 };
 let currentLang = "en";
@@ -268,11 +357,219 @@ async function updateStatus() {
         }
 
         port.innerText = status.port || "None";
+        updateModems(status.modems, t);
+        updateBalance(status.modems, t);
+        updateStorage(status.modems, status.storage_policy, t);
+        updateSignal(status.network, status.signal_history, t);
+        updateSimLock(status.sim_lock, t);
+        updateClock(status.clock, t);
+
+        const smsc = document.getElementById('smsc-text');
+        smsc.innerText = status.smsc || t.smscNone;
+        smsc.style.color = status.smsc ? "" : "#dc3545";
+        smsc.title = status.smsc_source === "config" ? t.smscFromConfig : t.smscFromSim;
     } catch (e) {
         console.error("Failed to get status:", e);
     }
 }
 
+// Port tooltip: one line per modem of the pool, in priority order
+function updateModems(modems, t) {
+    const el = document.getElementById('port-text');
+    if (!modems || !modems.length) {
+        el.style.color = "";
+        el.title = "";
+        return;
+    }
+
+    const lines = modems.map(m => {
+        const state = !m.Checked ? t.modemStates.unchecked : (m.Healthy ? t.modemStates.healthy : t.modemStates.down);
+        const counts = t.modemCounts.replace('%s', m.Sent).replace('%s', m.Failed);
+        let line = `${m.Priority}. ${m.Port}: ${state}, ${counts}`;
+        if (m.Network) line += `, ${m.Network.RSSI < 0 ? t.noSignal : m.Network.RSSIdBm + ' dBm'}`;
+        if (m.SIMLock) line += `, ${m.SIMLock.State}`;
+        if (m.Watchdog && m.Watchdog.Resets) line += `, ${t.modemResets.replace('%s', m.Watchdog.Resets)}`;
+        if (m.LastError) line += ` (${m.LastError})`;
+        // Which stick and SIM, for remote support
+        if (m.Info) line += `\n    ${[m.Info.Model, m.Info.Revision].filter(Boolean).join(' ')}, IMEI ${m.Info.IMEI || '?'}, ICCID ${m.Info.ICCID || '?'}${m.Info.Number ? ', ' + m.Info.Number : ''}`;
+        return line;
+    });
+    el.title = lines.join('\n');
+    const down = modems.filter(m => m.Checked && !m.Healthy).length;
+    el.style.color = down === 0 ? "" : (down === modems.length ? "#dc3545" : "#e0a800");
+}
+
+// Credit: prepaid balance of every SIM, red when one is below the threshold
+function updateBalance(modems, t) {
+    const item = document.getElementById('balance-item');
+    const el = document.getElementById('balance-text');
+    const known = (modems || []).filter(m => m.Balance);
+    if (!known.length) {
+        item.style.display = "none";
+        return;
+    }
+    item.style.display = "";
+
+    const low = known.filter(m => m.Balance.Low);
+    el.innerText = known.map(m => (known.length > 1 ? `${m.Port} ` : '') + m.Balance.Amount).join(' · ')
+        + (low.length ? ` (${t.balanceLow})` : '');
+    el.style.color = low.length ? "#dc3545" : "";
+    el.title = known.map(m => `${m.Port}: ${m.Balance.Text}`).concat(t.balanceCheck).join('\n');
+}
+
+async function checkBalance() {
+    const t = i18n[currentLang];
+    try {
+        const err = await callBackend('CheckBalance');
+        if (err) alert(t.balanceFailed + err);
+        updateStatus();
+    } catch (e) {
+        alert(t.balanceFailed + e);
+    }
+}
+
+// Storage: SIM message storage fill level, amber above the cleanup limit
+let storagePolicy = null;
+
+function updateStorage(modems, policy, t) {
+    const item = document.getElementById('storage-item');
+    const el = document.getElementById('storage-text');
+    storagePolicy = policy || null;
+    const known = (modems || []).filter(m => m.Storage);
+    if (!known.length) {
+        item.style.display = "none";
+        return;
+    }
+    item.style.display = "";
+
+    const usage = m => m.Storage.Read;
+    const percent = u => u.Total > 0 ? Math.floor(u.Used * 100 / u.Total) : 0;
+    el.innerText = known.map(m => (known.length > 1 ? `${m.Port} ` : '') + `${usage(m).Name} ${usage(m).Used}/${usage(m).Total}`).join(' · ');
+    const full = policy && policy.MaxPercent > 0 && known.some(m => percent(usage(m)) > policy.MaxPercent);
+    el.style.color = full ? "#e0a800" : "";
+    if (!policy) {
+        el.title = "";
+    } else if (!policy.MaxAgeDays && !policy.MaxPercent) {
+        el.title = t.storagePolicyOff;
+    } else {
+        el.title = t.storagePolicy.replace('%s', policy.MaxAgeDays).replace('%s', policy.MaxPercent);
+    }
+}
+
+// Clock: host and database clock against the network time, red when one
+// drifts beyond clock.drift_threshold
+function updateClock(clock, t) {
+    const item = document.getElementById('clock-item');
+    const el = document.getElementById('clock-text');
+    if (!clock) {
+        item.style.display = "none";
+        return;
+    }
+    item.style.display = "";
+
+    const warnings = clock.Warnings || [];
+    const drift = ns => `${ns >= 0 ? '+' : ''}${Math.round(ns / 1e9)}s`;
+    el.innerText = warnings.length ? t.clockDrift : t.clockSynced;
+    el.style.color = warnings.length ? "#dc3545" : "";
+    el.title = [t.clockDetail.replace('%s', clock.Port).replace('%s', drift(clock.HostDrift))
+        .replace('%s', clock.DBKnown ? drift(clock.DBDrift) : '?')].concat(warnings).join('\n');
+}
+
+async function editStoragePolicy() {
+    const t = i18n[currentLang];
+    if (!storagePolicy) return;
+    const age = prompt(t.enterMaxAge, storagePolicy.MaxAgeDays);
+    if (age === null) return;
+    const percent = prompt(t.enterMaxPercent, storagePolicy.MaxPercent);
+    if (percent === null) return;
+    const policy = { ...storagePolicy, MaxAgeDays: parseInt(age, 10) || 0, MaxPercent: parseInt(percent, 10) || 0 };
+    try {
+        let err = await callBackend('SetStoragePolicy', policy);
+        if (!err) err = await callBackend('CleanStorage');
+        if (err) alert(t.storageFailed + err);
+        updateStatus();
+    } catch (e) {
+        alert(t.storageFailed + e);
+    }
+}
+
+function updateSignal(net, history, t) {
+    const el = document.getElementById('signal-text');
+    if (!net) {
+        el.innerText = "--";
+        el.style.color = "";
+        el.title = "";
+        return;
+    }
+
+    const dbm = net.RSSI < 0 ? t.noSignal : `${net.RSSIdBm} dBm`;
+    if (net.Registered) {
+        el.innerText = `${dbm} · ${net.Operator || '?'} ${net.AccessTech || ''}`.trim();
+        el.style.color = net.RSSI >= 10 ? "#28a745" : "#e0a800";
+    } else {
+        el.innerText = `${t.notRegistered} (${net.State}) · ${dbm}`;
+        el.style.color = "#dc3545";
+    }
+
+    // Tooltip: min/max signal over the kept history
+    const levels = (history || []).filter(h => h.RSSI >= 0).map(h => h.RSSIdBm);
+    el.title = levels.length
+        ? `${Math.min(...levels)} .. ${Math.max(...levels)} dBm (${levels.length} samples)`
+        : "";
+}
+
+let simLockState = null;
+
+function updateSimLock(lock, t) {
+    const btn = document.getElementById('btn-unlock-sim');
+    simLockState = lock ? lock.State : null;
+    if (!lock) {
+        btn.style.display = "none";
+        return;
+    }
+
+    const left = [];
+    if (lock.Attempts.PIN >= 0) left.push(`${t.pinLeft}: ${lock.Attempts.PIN}`);
+    if (lock.Attempts.PUK >= 0) left.push(`${t.pukLeft}: ${lock.Attempts.PUK}`);
+    const el = document.getElementById('signal-text');
+    el.innerText = lock.State + (left.length ? ` (${left.join(', ')})` : '');
+    el.style.color = "#dc3545";
+    el.title = lock.Reason;
+    btn.style.display = "";
+}
+
+async function unlockSIM() {
+    const t = i18n[currentLang];
+    const puk = simLockState === "SIM PUK";
+    const code = prompt(puk ? t.enterPuk : t.enterPin);
+    if (!code) return;
+    let newPin = "";
+    if (puk) {
+        newPin = prompt(t.enterNewPin);
+        if (!newPin) return;
+    }
+    try {
+        const err = await callBackend('UnlockSIM', code, newPin);
+        if (err) alert(t.unlockFailed + err);
+        updateStatus();
+    } catch (e) {
+        alert(t.unlockFailed + e);
+    }
+}
+
+async function editSMSC() {
+    const t = i18n[currentLang];
+    const number = prompt(t.enterSmsc);
+    if (!number) return;
+    try {
+        const err = await callBackend('SetSMSC', number);
+        if (err) alert(t.smscFailed + err);
+        updateStatus();
+    } catch (e) {
+        alert(t.smscFailed + e);
+    }
+}
+
 async function toggleAutoStart(checkbox) {
     const wasChecked = !checkbox.checked; // Store previous state
     try {
@@ -338,6 +635,12 @@ function updateLanguageUI() {
     // Running/Stopped is dynamic, handled in updateStatus, but we update the text logic there too
 
     document.querySelectorAll('.status-item span')[1].childNodes[0].textContent = t.port + " "; // Port label
+    document.querySelectorAll('.status-item span')[2].childNodes[0].textContent = t.signal + " "; // Signal label
+    document.getElementById('btn-unlock-sim').innerText = t.unlockSim;
+    document.querySelectorAll('.status-item span')[3].childNodes[0].textContent = t.smsc + " "; // SMSC label
+    document.querySelectorAll('.status-item span')[4].childNodes[0].textContent = t.balance + " "; // Credit label
+    document.querySelectorAll('.status-item span')[5].childNodes[0].textContent = t.storage + " "; // Storage label
+    document.querySelectorAll('.status-item span')[6].childNodes[0].textContent = t.clock + " "; // Clock label
 
     document.querySelector('label[for="chk-autostart"]').innerText = t.autoStart;
     document.getElementById('btn-lang').innerText = t.langBtn;
@@ -364,6 +667,14 @@ async function openHelp() {
     // Update tab labels
     document.getElementById('about-tab-guide').innerText = t.tabGuide;
     document.getElementById('about-tab-test').innerText = t.tabTestSms;
+    document.getElementById('about-tab-inbox').innerText = t.tabInbox;
+    document.getElementById('inbox-title').innerText = '\u{1F4E5} ' + t.tabInbox;
+    document.getElementById('about-tab-delivery').innerText = t.tabDelivery;
+    document.getElementById('delivery-title').innerText = '\u2705 ' + t.tabDelivery;
+    document.getElementById('about-tab-console').innerText = t.tabConsole;
+    document.getElementById('console-title').innerText = '\u{1F4BB} ' + t.tabConsole;
+    document.getElementById('btn-console-send').innerText = t.consoleSend;
+    document.getElementById('btn-console-export').innerText = t.consoleExport;
     // Update guide content
     document.getElementById('help-title').innerText = t.helpTitle;
     document.getElementById('help-body').innerHTML = t.helpBody;
@@ -405,11 +716,133 @@ function closeHelp() {
 }
 
 function switchAboutTab(tab) {
-    const isGuide = tab === 'guide';
-    document.getElementById('about-panel-guide').style.display = isGuide ? 'block' : 'none';
-    document.getElementById('about-panel-test').style.display = isGuide ? 'none' : 'block';
-    document.getElementById('about-tab-guide').classList.toggle('about-tab-active', isGuide);
-    document.getElementById('about-tab-test').classList.toggle('about-tab-active', !isGuide);
+    ['guide', 'test', 'inbox', 'delivery', 'console'].forEach(name => {
+        const active = name === tab;
+        document.getElementById(`about-panel-${name}`).style.display = active ? 'block' : 'none';
+        document.getElementById(`about-tab-${name}`).classList.toggle('about-tab-active', active);
+    });
+    if (tab === 'inbox') loadInbox();
+    if (tab === 'delivery') loadDeliveries();
+    if (tab === 'console') loadConsole();
+}
+
+// Inbox: messages received by the modem
+async function loadInbox() {
+    const t = i18n[currentLang];
+    const list = document.getElementById('inbox-list');
+    const msgs = await callBackend('GetInbox');
+    list.innerHTML = '';
+    if (!msgs || msgs.length === 0) {
+        list.innerHTML = `<li style="color:#888;">${t.inboxEmpty}</li>`;
+        return;
+    }
+    msgs.forEach(m => {
+        const li = document.createElement('li');
+        li.className = 'recipient-item';
+        const when = new Date(m.ReceivedAt).toLocaleString();
+        const note = m.Complete ? '' : ` <em style="color:#dc3545;">${t.inboxIncomplete}</em>`;
+        li.innerHTML = `
+            <div style="min-width:0;">
+                <strong>${m.Sender}</strong> <span style="color:#888; font-size:0.8rem;">${when}</span>${note}
+                <div style="white-space:pre-wrap; word-break:break-word;"></div>
+            </div>
+            <button class="btn-danger" onclick="deleteInboxMessage(${m.InboxID})">X</button>
+        `;
+        li.querySelector('div > div').innerText = m.Message;
+        list.appendChild(li);
+    });
+}
+
+// Delivery: outcome of recent sends from the status reports
+const deliveryColors = { delivered: '#28a745', pending: '#e0a800', failed: '#dc3545', sent: '#6c757d' };
+
+async function loadDeliveries() {
+    const t = i18n[currentLang];
+    const list = document.getElementById('delivery-list');
+    const rows = await callBackend('GetDeliveries');
+    list.innerHTML = '';
+    if (!rows || rows.length === 0) {
+        list.innerHTML = `<li style="color:#888;">${t.deliveryEmpty}</li>`;
+        return;
+    }
+    rows.forEach(d => {
+        const li = document.createElement('li');
+        li.className = 'recipient-item';
+        const when = new Date(d.SubmittedAt).toLocaleString();
+        let what = d.AlarmHistorysID ? `${t.deliveryAlarm} #${d.AlarmHistorysID}` : t.deliveryTest;
+        if (d.Port) what += ` · ${d.Port}`;
+        const state = t.deliveryStates[d.Status] || d.Status;
+        li.innerHTML = `
+            <div style="min-width:0;">
+                <strong>${d.Recipient}</strong> <span style="color:#888; font-size:0.8rem;">${when} · ${what}</span>
+                <div style="color:#888; font-size:0.8rem; word-break:break-word;"></div>
+            </div>
+            <span style="color:${deliveryColors[d.Status] || '#666'}; font-weight:600; white-space:nowrap;">${state} ${d.Delivered}/${d.Segments}</span>
+        `;
+        li.querySelector('div > div').innerText = d.Detail || '';
+        list.appendChild(li);
+    });
+}
+
+// AT console: commands sent to a modem of the pool, with this session's transcript
+function consoleText(e) {
+    let text = `${e.Port ? '[' + e.Port + '] ' : ''}> ${e.Command}\n`;
+    (e.Lines || []).forEach(line => text += line + '\n');
+    if (e.Final) text += e.Final + '\n';
+    if (e.Error) text += '! ' + e.Error + '\n';
+    return text;
+}
+
+async function loadConsole() {
+    const t = i18n[currentLang];
+    const select = document.getElementById('console-port');
+    const status = await callBackend('GetStatus');
+    const ports = status && status.modems ? status.modems.map(m => m.Port) : [];
+    const current = select.value;
+    select.innerHTML = `<option value="">${t.consolePreferred}</option>` +
+        ports.map(p => `<option value="${p}">${p}</option>`).join('');
+    if (ports.includes(current)) select.value = current;
+
+    const out = document.getElementById('console-output');
+    const entries = await callBackend('GetATTranscript');
+    out.textContent = (entries || []).map(consoleText).join('');
+    out.scrollTop = out.scrollHeight;
+    document.getElementById('console-input').focus();
+}
+
+async function runATCommand() {
+    const input = document.getElementById('console-input');
+    const cmd = input.value.trim();
+    if (!cmd) return;
+    const btn = document.getElementById('btn-console-send');
+    const out = document.getElementById('console-output');
+    btn.disabled = true;
+    try {
+        const entry = await callBackend('RunATCommand', document.getElementById('console-port').value, cmd);
+        if (entry) out.textContent += consoleText(entry);
+        input.value = '';
+    } catch (e) {
+        out.textContent += `> ${cmd}\n! ${e}\n`;
+    } finally {
+        btn.disabled = false;
+        out.scrollTop = out.scrollHeight;
+        input.focus();
+    }
+}
+
+async function exportATTranscript() {
+    try {
+        const path = await callBackend('ExportATTranscript');
+        if (path) alert(i18n[currentLang].consoleExported.replace('%s', path));
+    } catch (e) {
+        alert(e);
+    }
+}
+
+async function deleteInboxMessage(id) {
+    if (!confirm(i18n[currentLang].inboxDeleteConfirm)) return;
+    await callBackend('DeleteInboxMessage', id);
+    loadInbox();
 }
 
 async function sendTestSms() {
@@ -469,3 +902,10 @@ window.openHelp = openHelp;
 window.closeHelp = closeHelp;
 window.switchAboutTab = switchAboutTab;
 window.sendTestSms = sendTestSms;
+window.deleteInboxMessage = deleteInboxMessage;
+window.unlockSIM = unlockSIM;
+window.editSMSC = editSMSC;
+window.checkBalance = checkBalance;
+window.editStoragePolicy = editStoragePolicy;
+window.runATCommand = runATCommand;
+window.exportATTranscript = exportATTranscript;
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"smallNfast/internal/config"
 	"smallNfast/internal/db"
@@ -11,6 +12,7 @@ import (
 	"smallNfast/internal/monitor"
 	"smallNfast/internal/serial"
 	"sync"
+	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -110,7 +112,22 @@ func (a *App) DeleteRecipient(id int64) error {
 	return db.DeleteRecipient(id)
 }
 
-func (a *App) CheckPorts() []string {
+// GetInbox returns the most recent received SMS, newest first.
+func (a *App) GetInbox() ([]db.InboxModel, error) {
+	return db.GetInbox(200)
+}
+
+func (a *App) DeleteInboxMessage(id int64) error {
+	return db.DeleteInboxMessage(id)
+}
+
+// GetDeliveries returns the delivery state of the most recent sends,
+// one entry per recipient and message.
+func (a *App) GetDeliveries() ([]db.DeliverySummary, error) {
+	return db.GetDeliverySummaries(100)
+}
+
+func (a *App) CheckPorts() []serial.PortInfo {
 	return serial.CheckAvailablePorts()
 }
 
@@ -121,9 +138,19 @@ func (a *App) GetStatus() map[string]interface{} {
 			"port":  "",
 		}
 	}
+	network, history := a.Monitor.NetworkStatus()
+	smsc, smscSource := a.Monitor.SMSC()
 	return map[string]interface{}{
-		"state": a.Monitor.State, // "stopped", "initializing", "running", "error"
-		"port":  a.Monitor.PortName,
+		"state":          a.Monitor.State, // "stopped", "initializing", "running", "error"
+		"port":           strings.Join(a.Monitor.Ports(), ", "),
+		"modems":         a.Monitor.ModemStates(), // per-modem health, counters and telemetry, in priority order
+		"network":        network, // latest signal/registration sample, nil until polled
+		"signal_history": history,
+		"sim_lock":       a.Monitor.SIMLock(), // nil unless the SIM needs a PIN/PUK
+		"smsc":           smsc,
+		"smsc_source":    smscSource, // "config" (sms.smsc) or "sim" (AT+CSCA)
+		"storage_policy": a.Monitor.StoragePolicy(),
+		"clock":          a.Monitor.ClockStatus(), // nil until the network time was read
 	}
 }
 
@@ -183,7 +210,11 @@ func (a *App) RestartService() error {
 	a.AddLog("Database Connected.")
 
 	if a.Monitor != nil {
-		a.Monitor.Start()
+		if err := a.Monitor.Start(); err != nil {
+			errMsg := fmt.Sprintf("Service Restart Failed: %v", err)
+			a.AddLog(errMsg)
+			return fmt.Errorf(errMsg)
+		}
 	}
 
 	return nil
@@ -197,6 +228,129 @@ func (a *App) SetLanguage(lang string) string {
 	return "Monitor not ready"
 }
 
+// UnlockSIM enters the SIM PIN, or the PUK together with a new PIN when the
+// SIM is PUK-locked. Returns "" on success, or an error message string.
+func (a *App) UnlockSIM(code string, newPIN string) string {
+	if a.Monitor == nil {
+		return "Monitor service is not running"
+	}
+	if err := a.Monitor.UnlockSIM("", code, newPIN); err != nil {
+		return err.Error()
+	}
+	a.AddLog("SIM unlocked successfully.")
+	return ""
+}
+
+// SetSMSC stores a new service centre address on the SIM.
+// Returns "" on success, or an error message string.
+func (a *App) SetSMSC(number string) string {
+	if a.Monitor == nil {
+		return "Monitor service is not running"
+	}
+	if err := a.Monitor.SetSMSC("", number); err != nil {
+		return err.Error()
+	}
+	a.AddLog(fmt.Sprintf("SMSC on SIM set to %s", strings.TrimSpace(number)))
+	return ""
+}
+
+// CheckBalance queries the prepaid credit of every modem now.
+// Returns "" on success, or an error message string.
+func (a *App) CheckBalance() string {
+	if a.Monitor == nil {
+		return "Monitor service is not running"
+	}
+	if err := a.Monitor.CheckBalance(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// GetStoragePolicy returns the SIM message storage and cleanup policy.
+func (a *App) GetStoragePolicy() config.StoragePolicy {
+	if a.Monitor == nil {
+		return config.DefaultSettings().Storage
+	}
+	return a.Monitor.StoragePolicy()
+}
+
+// SetStoragePolicy applies a new storage policy and saves it to
+// smscat.properties. Returns "" on success, or an error message string.
+func (a *App) SetStoragePolicy(p config.StoragePolicy) string {
+	if a.Monitor == nil {
+		return "Monitor service is not running"
+	}
+	if err := p.Validate(); err != nil {
+		return err.Error()
+	}
+	// A modem refusing the memory does not stop the policy being kept
+	applyErr := a.Monitor.SetStoragePolicy(p)
+	p = a.Monitor.StoragePolicy()
+	if err := config.SaveValues(config.SettingsFile, p.Values()); err != nil {
+		return fmt.Sprintf("Policy applied but not saved: %v", err)
+	}
+	a.AddLog(fmt.Sprintf("Storage policy: memory %q, delete after %d day(s), above %d%% full", p.Memory, p.MaxAgeDays, p.MaxPercent))
+	if applyErr != nil {
+		return applyErr.Error()
+	}
+	return ""
+}
+
+// CleanStorage applies the storage cleanup policy to every modem now.
+// Returns "" on success, or an error message string.
+func (a *App) CleanStorage() string {
+	if a.Monitor == nil {
+		return "Monitor service is not running"
+	}
+	if err := a.Monitor.CleanStorage(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// GetModemInfo returns the model, firmware, IMEI, IMSI, ICCID and own
+// number of every connected modem, for remote support.
+func (a *App) GetModemInfo() []serial.ModemInfo {
+	if a.Monitor == nil {
+		return nil
+	}
+	return a.Monitor.ModemInfo()
+}
+
+// RunATCommand sends an AT command to the modem on port (the preferred
+// modem when empty) and returns the response, or why it was refused.
+func (a *App) RunATCommand(port string, cmd string) monitor.ConsoleEntry {
+	if a.Monitor == nil {
+		return monitor.ConsoleEntry{Command: cmd, Error: "Monitor service is not running"}
+	}
+	return a.Monitor.RunATCommand(port, cmd)
+}
+
+// GetATTranscript returns the AT console commands of this session.
+func (a *App) GetATTranscript() []monitor.ConsoleEntry {
+	if a.Monitor == nil {
+		return nil
+	}
+	return a.Monitor.ATTranscript()
+}
+
+// ExportATTranscript saves the AT console transcript under logs/ and
+// returns the file's path.
+func (a *App) ExportATTranscript() (string, error) {
+	if a.Monitor == nil {
+		return "", fmt.Errorf("Monitor service is not running")
+	}
+	if err := os.MkdirAll("logs", 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join("logs", "at-console-"+time.Now().Format("20060102-150405")+".txt")
+	if err := a.Monitor.ExportATTranscript(path); err != nil {
+		return "", err
+	}
+	a.AddLog("AT console transcript saved to " + path)
+	return path, nil
+}
+
 // SendTestSMS sends a one-off test SMS to the given number.
 // Returns "" on success, or an error message string on failure.
 func (a *App) SendTestSMS(number string, text string) string {
@@ -208,9 +362,9 @@ func (a *App) SendTestSMS(number string, text string) string {
 
 	a.AddLog(fmt.Sprintf("Test SMS → %s : \"%s\"", number, text))
 
-	// Use the monitor's active modem if available
-	if a.Monitor != nil && a.Monitor.Modem != nil {
-		err := a.Monitor.Modem.SendSMS(number, text)
+	// Use the monitor's modem pool if available
+	if a.Monitor != nil && a.Monitor.HasModem() {
+		err := a.Monitor.SendDirect(number, text)
 		if err != nil {
 			a.AddLog(fmt.Sprintf("Test SMS FAILED: %v", err))
 			return fmt.Sprintf("Failed: %v", err)
@@ -221,14 +375,22 @@ func (a *App) SendTestSMS(number string, text string) string {
 
 	// Modem not initialised yet — try a quick one-shot connect
 	a.AddLog("Modem not running. Attempting one-shot connect for test SMS...")
-	port, err := serial.FindModemPort()
+	port, profile, err := serial.FindModemPort()
 	if err != nil {
 		msg := fmt.Sprintf("No modem found: %v", err)
 		a.AddLog("Test SMS FAILED: " + msg)
 		return msg
 	}
 
-	modem := serial.NewGSMModem(port, func(msg string, _ bool) { a.AddLog(msg) })
+	modem := serial.NewGSMModem(port.Path, func(msg string, _ bool) { a.AddLog(msg) })
+	modem.Profile = profile
+	if a.Monitor != nil {
+		if forced := serial.LookupProfile(a.Monitor.Settings.ModemProfile); forced != nil {
+			modem.Profile = forced
+		}
+		modem.PIN = a.Monitor.Settings.SIMPIN
+		modem.SMSC = a.Monitor.Settings.SMSC
+	}
 	if err := modem.Connect(); err != nil {
 		msg := fmt.Sprintf("Modem connect failed: %v", err)
 		a.AddLog("Test SMS FAILED: " + msg)
@@ -236,7 +398,7 @@ func (a *App) SendTestSMS(number string, text string) string {
 	}
 	defer modem.Close()
 
-	if err := modem.SendSMS(number, text); err != nil {
+	if _, err := modem.SendSMS(context.Background(), number, text, serial.SendOptions{}); err != nil {
 		msg := fmt.Sprintf("Send failed: %v", err)
 		a.AddLog("Test SMS FAILED: " + msg)
 		return msg
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package config
+
+import "fmt"
+
+// Auto-start uses the Windows Run registry key; other platforms are
+// expected to manage SMSCat through their own service manager.
+
+var errAutoStartUnsupported = fmt.Errorf("auto-start is only supported on Windows")
+
+func EnableAutoStart() error {
+	return errAutoStartUnsupported
+}
+
+func DisableAutoStart() error {
+	return errAutoStartUnsupported
+}
+
+func IsAutoStartEnabled() bool {
+	return false
+}
+
+// GetLastError returns the last error encountered
+func GetLastError() error {
+	return nil
+}
@@ -0,0 +1,480 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SettingsFile is SMSCat's own options file, next to database.properties.
+const SettingsFile = "smscat.properties"
+
+// ConcatRefsFile keeps the concatenated SMS reference counters across
+// restarts.
+const ConcatRefsFile = "concat_refs.properties"
+
+// InventoryFile remembers the SIM (ICCID) last seen in each modem (IMEI).
+const InventoryFile = "modem_inventory.properties"
+
+// Settings holds the modem and messaging options from smscat.properties.
+// A missing file or key keeps the default.
+type Settings struct {
+	// StatusReports requests an SMS-STATUS-REPORT for every segment sent.
+	// Off by default: the request changes every PDU and may be billed.
+	StatusReports bool
+	// Concat16 numbers long messages with the 16-bit reference element
+	// instead of the 8-bit one, leaving one character less per part.
+	Concat16 bool
+	// ModemPorts lists the modems to use, in priority order. Empty
+	// auto-detects every supported modem.
+	ModemPorts []string
+	// ModemSelect picks the modem for each SMS: "priority" (first healthy
+	// modem in ModemPorts order) or "roundrobin".
+	ModemSelect string
+	// ModemProfile forces a vendor profile (quectel, sim7600, huawei,
+	// generic). Empty picks the profile by the detected USB IDs.
+	ModemProfile string
+	// Serial is the line setup of the modem ports; SerialPorts holds
+	// the ports with their own serial.<port>.* keys.
+	Serial      SerialLine
+	SerialPorts map[string]SerialLine
+	// CaptureDir records every byte exchanged with each modem to a
+	// capture file in this directory, for replaying a session elsewhere.
+	// Empty disables recording.
+	CaptureDir string
+	// SIMPIN is entered when the SIM asks for its PIN. Empty leaves a
+	// PIN-locked SIM for the operator to unlock from the UI.
+	SIMPIN string
+	// SMSC overrides the service centre stored on the SIM; it is encoded
+	// into every PDU. Empty uses the SIM's address.
+	SMSC string
+	// CriticalAlarms lists the alarm_setting_id values whose alarms are
+	// escalated with a voice call; "*" marks every alarm critical.
+	CriticalAlarms []string
+	// CriticalFlash sends the SMS of critical alarms as flash messages
+	// (class 0), shown at once instead of landing in the inbox.
+	CriticalFlash bool
+	// ResumedValidity is how long the SMSC keeps trying to deliver an
+	// "alarm resumed" SMS; 0 keeps the default of 27 days.
+	ResumedValidity time.Duration
+	// ClockDrift is how far the host or database clock may be off the
+	// network time before a warning is logged; 0 disables the check.
+	ClockDrift time.Duration
+	// NetworkTime corrects the "Time:" line of alarm messages by the
+	// database clock's drift from the network time.
+	NetworkTime bool
+	// CallMode is "both" (SMS and call) or "call" (call instead of SMS)
+	// for critical alarms.
+	CallMode string
+	// CallRingTime hangs up a call that is not answered in time.
+	CallRingTime time.Duration
+	// CallConfirm asks the callee to press a key. Escalation then stops at
+	// the first confirmation instead of the first answer.
+	CallConfirm bool
+	// BalanceUSSD is the USSD code that returns the prepaid credit, e.g.
+	// "*100#". Empty disables the balance check.
+	BalanceUSSD string
+	// BalancePattern extracts the amount from the answer; its first group
+	// (or the whole match) is parsed as a number, which may use a decimal
+	// comma and thousands separators.
+	BalancePattern string
+	// BalanceThreshold raises a low-credit alert below this amount.
+	BalanceThreshold float64
+	// BalanceInterval is how often the credit is checked.
+	BalanceInterval time.Duration
+	// AdminNumbers receive low-credit alerts. Empty uses the active
+	// alarm recipients.
+	AdminNumbers []string
+	// Storage selects the SIM message storage and how it is cleaned up.
+	Storage StoragePolicy
+	// WatchdogInterval is how often every modem is pinged with AT; 0
+	// disables the watchdog.
+	WatchdogInterval time.Duration
+	// WatchdogFailures is the number of failed pings in a row after which
+	// the modem is reset (AT+CFUN=1,1) and rediscovered.
+	WatchdogFailures int
+	// ConsoleAllow, when set, limits the AT console to commands starting
+	// with one of these prefixes.
+	ConsoleAllow []string
+	// ConsoleDeny lists prefixes of commands the AT console refuses.
+	// Queries (ending in "?") are always allowed.
+	ConsoleDeny []string
+}
+
+// StoragePolicy is the message storage selection and cleanup policy.
+// Only read messages and sent copies are ever deleted, and only once a
+// limit was set: the zero value keeps everything.
+type StoragePolicy struct {
+	// Memory is selected with AT+CPMS for reading, writing and receiving
+	// (SM, ME, MT). Empty keeps the modem's choice.
+	Memory string
+	// MaxAgeDays deletes messages older than this many days; 0 disables.
+	MaxAgeDays int
+	// MaxPercent deletes the oldest messages while the storage is fuller
+	// than this percentage; 0 disables.
+	MaxPercent int
+}
+
+// SerialLine is the line setup of a physical modem port.
+type SerialLine struct {
+	// Baud is the rate to open the port at; 0 ("auto") starts at 115200
+	// and tries the other common rates when the modem does not answer.
+	Baud        int
+	DataBits    int    // 5 to 8
+	Parity      string // N, E, O, M or S
+	StopBits    string // 1, 1.5 or 2
+	FlowControl string // none, rtscts or xonxoff
+	// ReadTimeout bounds a read with no data; 0 keeps the default.
+	ReadTimeout time.Duration
+	// CommandTimeout is the least time a command gets to answer; 0 keeps
+	// the per-command defaults.
+	CommandTimeout time.Duration
+}
+
+// SerialLine returns the line setup of port.
+func (s *Settings) SerialLine(port string) SerialLine {
+	for name, line := range s.SerialPorts {
+		if strings.EqualFold(name, port) {
+			return line
+		}
+	}
+	return s.Serial
+}
+
+// set applies one serial.* key, e.g. field "baud". Invalid values are
+// ignored.
+func (l *SerialLine) set(field, val string) {
+	switch field {
+	case "baud":
+		if strings.EqualFold(val, "auto") {
+			l.Baud = 0
+		} else if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			l.Baud = n
+		}
+	case "data_bits":
+		if n, err := strconv.Atoi(val); err == nil && n >= 5 && n <= 8 {
+			l.DataBits = n
+		}
+	case "parity":
+		// none, even, odd, mark, space or their initials
+		if v := strings.ToUpper(val); v != "" && strings.Contains("NEOMS", v[:1]) {
+			l.Parity = v[:1]
+		}
+	case "stop_bits":
+		if val == "1" || val == "1.5" || val == "2" {
+			l.StopBits = val
+		}
+	case "flow_control":
+		if v := strings.ToLower(val); v == "none" || v == "rtscts" || v == "xonxoff" {
+			l.FlowControl = v
+		}
+	case "read_timeout":
+		if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+			l.ReadTimeout = time.Duration(n) * time.Millisecond
+		}
+	case "command_timeout":
+		if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+			l.CommandTimeout = time.Duration(n) * time.Millisecond
+		}
+	}
+}
+
+// Validate checks the limits are in range.
+func (p StoragePolicy) Validate() error {
+	if p.MaxAgeDays < 0 {
+		return fmt.Errorf("invalid maximum age %d days", p.MaxAgeDays)
+	}
+	if p.MaxPercent < 0 || p.MaxPercent > 100 {
+		return fmt.Errorf("invalid fill limit %d%%, want 0-100", p.MaxPercent)
+	}
+	return nil
+}
+
+// Values returns the policy as storage.* keys for SaveValues.
+func (p StoragePolicy) Values() map[string]string {
+	return map[string]string{
+		"storage.memory":       p.Memory,
+		"storage.max_age_days": strconv.Itoa(p.MaxAgeDays),
+		"storage.max_percent":  strconv.Itoa(p.MaxPercent),
+	}
+}
+
+// IsCritical reports whether alarms of the alarm setting id are escalated
+// with a voice call.
+func (s *Settings) IsCritical(settingID int64) bool {
+	id := strconv.FormatInt(settingID, 10)
+	for _, c := range s.CriticalAlarms {
+		if c == "*" || c == id {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultConsoleDeny keeps the AT console away from commands that power
+// the modem down or reset it, change the serial link or the state SMSCat
+// relies on, use up PIN attempts or delete messages.
+var DefaultConsoleDeny = []string{
+	"AT+CFUN", "AT+CPWROFF", "AT+QPOWD", "AT+CPOF", "ATZ", "AT&F", "AT&W",
+	"ATE", "ATV", "ATQ", "AT+IPR", "AT+ICF", "AT+IFC", "AT+QCFG",
+	"AT+CMGF", "AT+CSCS", "AT+CNMI", "AT+CMEE",
+	"AT+CMGS", "AT+CMGW", "AT+CMSS", "AT+CMGD",
+	"AT+CPIN", "AT+CLCK", "AT+CPWD",
+}
+
+// DefaultSettings returns the settings used when no file is present.
+func DefaultSettings() *Settings {
+	return &Settings{
+		ModemSelect:      "priority",
+		CallMode:         "both",
+		ResumedValidity:  6 * time.Hour,
+		ClockDrift:       2 * time.Minute,
+		CallRingTime:     30 * time.Second,
+		BalancePattern:   `(\d{1,3}(?:[ \x{A0},.]\d{3})+(?:[.,]\d+)?|\d+(?:[.,]\d+)?)`,
+		BalanceInterval:  12 * time.Hour,
+		Serial:           SerialLine{Baud: 115200, DataBits: 8, Parity: "N", StopBits: "1", FlowControl: "none"},
+		WatchdogInterval: time.Minute,
+		WatchdogFailures: 3,
+		ConsoleDeny:      DefaultConsoleDeny,
+	}
+}
+
+// LoadSettings reads key=value pairs from path. A missing file is not an
+// error; the defaults are returned.
+func LoadSettings(path string) (*Settings, error) {
+	settings := DefaultSettings()
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return settings, err
+	}
+	defer file.Close()
+
+	// serial.<port>.<field> keys, applied over the serial.<field> ones
+	// once the whole file is read
+	var portKeys [][3]string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		val := strings.TrimSpace(parts[1])
+
+		if field := strings.TrimPrefix(key, "serial."); field != key {
+			if i := strings.LastIndex(field, "."); i > 0 {
+				portKeys = append(portKeys, [3]string{field[:i], field[i+1:], val})
+			} else {
+				settings.Serial.set(field, val)
+			}
+			continue
+		}
+
+		switch key {
+		case "sms.status_report":
+			settings.StatusReports = parseBool(val, settings.StatusReports)
+		case "sms.concat_16bit":
+			settings.Concat16 = parseBool(val, settings.Concat16)
+		case "modem.ports":
+			settings.ModemPorts = splitList(val)
+		case "modem.select":
+			if v := strings.ToLower(val); v == "priority" || v == "roundrobin" {
+				settings.ModemSelect = v
+			}
+		case "modem.profile":
+			settings.ModemProfile = strings.ToLower(val)
+		case "modem.capture_dir":
+			settings.CaptureDir = val
+		case "sim.pin":
+			settings.SIMPIN = val
+		case "sms.smsc":
+			settings.SMSC = val
+		case "alarm.critical":
+			settings.CriticalAlarms = splitList(val)
+		case "alarm.critical_flash":
+			settings.CriticalFlash = parseBool(val, settings.CriticalFlash)
+		case "alarm.resumed_validity":
+			if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+				settings.ResumedValidity = time.Duration(n) * time.Minute
+			}
+		case "clock.drift_threshold":
+			if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+				settings.ClockDrift = time.Duration(n) * time.Second
+			}
+		case "clock.network_time":
+			settings.NetworkTime = parseBool(val, settings.NetworkTime)
+		case "call.mode":
+			if v := strings.ToLower(val); v == "both" || v == "call" {
+				settings.CallMode = v
+			}
+		case "call.ring_time":
+			if n, err := strconv.Atoi(val); err == nil && n > 0 {
+				settings.CallRingTime = time.Duration(n) * time.Second
+			}
+		case "call.confirm":
+			settings.CallConfirm = parseBool(val, settings.CallConfirm)
+		case "balance.ussd":
+			settings.BalanceUSSD = val
+		case "balance.pattern":
+			if val != "" {
+				settings.BalancePattern = val
+			}
+		case "balance.threshold":
+			if f, err := strconv.ParseFloat(val, 64); err == nil {
+				settings.BalanceThreshold = f
+			}
+		case "balance.interval":
+			if n, err := strconv.Atoi(val); err == nil && n > 0 {
+				settings.BalanceInterval = time.Duration(n) * time.Minute
+			}
+		case "admin.numbers":
+			settings.AdminNumbers = splitList(val)
+		case "storage.memory":
+			settings.Storage.Memory = strings.ToUpper(val)
+		case "storage.max_age_days":
+			if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+				settings.Storage.MaxAgeDays = n
+			}
+		case "storage.max_percent":
+			if n, err := strconv.Atoi(val); err == nil && n >= 0 && n <= 100 {
+				settings.Storage.MaxPercent = n
+			}
+		case "watchdog.interval":
+			if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+				settings.WatchdogInterval = time.Duration(n) * time.Second
+			}
+		case "watchdog.failures":
+			if n, err := strconv.Atoi(val); err == nil && n > 0 {
+				settings.WatchdogFailures = n
+			}
+		case "console.allow":
+			settings.ConsoleAllow = upperList(splitList(val))
+		case "console.deny":
+			settings.ConsoleDeny = upperList(splitList(val))
+		}
+	}
+	for _, kv := range portKeys {
+		if settings.SerialPorts == nil {
+			settings.SerialPorts = make(map[string]SerialLine)
+		}
+		line, ok := settings.SerialPorts[kv[0]]
+		if !ok {
+			line = settings.Serial
+		}
+		line.set(kv[1], kv[2])
+		settings.SerialPorts[kv[0]] = line
+	}
+	return settings, scanner.Err()
+}
+
+// LoadValues reads the key=value pairs of path. A missing file yields no
+// values and no error.
+func LoadValues(path string) (map[string]string, error) {
+	values := make(map[string]string)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return values, nil
+		}
+		return values, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
+			values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return values, scanner.Err()
+}
+
+// SaveValues writes key=value pairs into path. Keys already in the file
+// are replaced in place, keeping comments and other keys; new keys are
+// appended. A missing file is created.
+func SaveValues(path string, values map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimRight(string(data), "\r\n"), "\n")
+	}
+	written := make(map[string]bool)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		parts := strings.SplitN(trimmed, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if val, ok := values[key]; ok {
+			lines[i] = key + "=" + val
+			written[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if !written[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, key+"="+values[key])
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// upperList upper-cases every entry, for matching AT commands.
+func upperList(list []string) []string {
+	for i := range list {
+		list[i] = strings.ToUpper(list[i])
+	}
+	return list
+}
+
+// splitList splits a comma-separated value, dropping empty entries.
+func splitList(val string) []string {
+	var list []string
+	for _, v := range strings.Split(val, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// parseBool accepts true/false, yes/no, on/off and 1/0.
+func parseBool(val string, def bool) bool {
+	switch strings.ToLower(val) {
+	case "yes", "on":
+		return true
+	case "no", "off":
+		return false
+	}
+	if b, err := strconv.ParseBool(val); err == nil {
+		return b
+	}
+	return def
+}
@@ -36,6 +36,59 @@ func (SmsModel) TableName() string {
 	return "smsmodel"
 }
 
+// InboxModel is an SMS received by the modem, stored after its parts
+// have been reassembled and removed from SIM storage.
+type InboxModel struct {
+	InboxID    int64     `gorm:"primaryKey;column:inbox_id"`
+	Sender     string    `gorm:"column:sender;size:64"`
+	Message    string    `gorm:"column:message;type:text"`
+	SMSC       string    `gorm:"column:smsc;size:32"`
+	Parts      int       `gorm:"column:parts"`
+	Complete   bool      `gorm:"column:complete"`    // false when parts were missing
+	ReceivedAt time.Time `gorm:"column:received_at"` // service centre time stamp
+	StoredAt   time.Time `gorm:"column:stored_at"`
+}
+
+func (InboxModel) TableName() string {
+	return "sms_inbox"
+}
+
+// DeliveryModel tracks one SMS segment sent to one recipient, from the
+// SMSC accepting it (+CMGS) to its status report (+CDS).
+type DeliveryModel struct {
+	DeliveryID      int64     `gorm:"primaryKey;column:delivery_id"`
+	Batch           string    `gorm:"column:batch;size:64;index"`     // one SendSMS call
+	Port            string    `gorm:"column:port;size:64"`            // modem that sent it
+	AlarmHistorysID int64     `gorm:"column:alarm_historys_id;index"` // 0 for test messages
+	Recipient       string    `gorm:"column:recipient;size:64"`
+	MessageRef      int       `gorm:"column:message_ref"` // -1 when the segment was never accepted
+	Segment         int       `gorm:"column:segment"`
+	Segments        int       `gorm:"column:segments"`
+	Status          string    `gorm:"column:status;size:16"` // sent, pending, delivered, failed
+	StatusCode      int       `gorm:"column:status_code"`    // TP-Status of the report
+	Detail          string    `gorm:"column:detail;size:255"`
+	SubmittedAt     time.Time `gorm:"column:submitted_at;index"`
+	UpdatedAt       time.Time `gorm:"column:updated_at"`
+}
+
+func (DeliveryModel) TableName() string {
+	return "sms_delivery"
+}
+
+// DeliverySummary is the outcome of one SendSMS call to one recipient.
+type DeliverySummary struct {
+	Batch           string
+	AlarmHistorysID int64
+	Recipient       string
+	Port            string
+	Segments        int
+	Delivered       int
+	Status          string
+	Detail          string
+	SubmittedAt     time.Time
+	UpdatedAt       time.Time
+}
+
 type AlarmSettings struct {
 	AlarmSettingID int64    `gorm:"primaryKey;column:alarm_setting_id"`
 	ChannelID      int64    `gorm:"column:channel_id"`
@@ -137,12 +190,19 @@ func Connect(path string) error {
 	// SetConnMaxLifetime sets the maximum amount of time a connection may be reused.
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
+	// Tables owned by SMSCat are created on demand; the S4M tables are not touched.
+	if err := newDB.AutoMigrate(&InboxModel{}, &DeliveryModel{}); err != nil {
+		return fmt.Errorf("failed to migrate SMSCat tables: %w", err)
+	}
+
 	DB = newDB
 	return nil
 }
 
 // AlarmDetailDTO holds the result of the complex join query for SMS details
 type AlarmDetailDTO struct {
+	AlarmHistorysID     int64     `gorm:"column:alarm_historys_id"`
+	AlarmSettingID      int64     `gorm:"column:alarm_setting_id"`
 	CreatedDate         time.Time `gorm:"column:createddate"`
 	AlarmStatus         int       `gorm:"column:alarm_status"`
 	Threshold           float64   `gorm:"column:threshold"`
@@ -157,6 +217,38 @@ type AlarmDetailDTO struct {
 	LocationDescription string    `gorm:"column:location_description"`
 }
 
+// FetchAlarmDetails returns the SMS-enabled alarms created after since,
+// oldest first, with their setting, channel, sensor and location.
+func FetchAlarmDetails(since time.Time) ([]AlarmDetailDTO, error) {
+	var results []AlarmDetailDTO
+
+	// Complex query as requested
+	// SELECT ... FROM ... WHERE ah.createddate > ? AND as_tab.sms = 1
+	// ALIAS 'description' -> '..._description' to match DTO
+	query := `
+		SELECT
+			ah.alarm_historys_id,
+			ah.alarm_setting_id,
+			ah.createddate,
+			ah.alarm_status,
+			as_tab.threshold, as_tab.hysteresis, as_tab.direction,
+			c.channel_description, c.unit_index, c.unit_in_ascii,
+			c.measurement_value, c.resolution,
+			s.description AS sensor_description,
+			l.description AS location_description
+		FROM alarm_historys ah
+		JOIN alarm_settings as_tab ON ah.alarm_setting_id = as_tab.alarm_setting_id
+		JOIN channels c ON as_tab.channel_id = c.channel_id
+		JOIN sensors s ON c.logic_sensor_id = s.sensor_id
+		JOIN locations l ON s.location_id = l.location_id
+		WHERE ah.createddate > ? AND as_tab.sms = 1
+		ORDER BY ah.createddate ASC
+	`
+
+	err := DB.Raw(query, since).Scan(&results).Error
+	return results, err
+}
+
 // GetMaxCreatedDate returns the max createddate or NOW() if empty
 func GetMaxCreatedDate() (time.Time, error) {
 	var result sql.NullTime
@@ -172,6 +264,13 @@ func GetMaxCreatedDate() (time.Time, error) {
 	return result.Time, nil
 }
 
+// Now returns the database server's clock, read the way createddate is.
+func Now() (time.Time, error) {
+	var now sql.NullTime
+	err := DB.Raw("SELECT NOW()").Scan(&now).Error
+	return now.Time, err
+}
+
 // FetchActiveRecipients returns a list of phone numbers from active SmsRecord
 // requirement: recipient format '18900001111&13311112222'
 func FetchActiveRecipients() ([]string, error) {
@@ -203,3 +302,140 @@ func DeleteRecipient(id int64) error {
 func RemoveRecipientByNumber(number string) error {
 	return DB.Where("recipient = ?", number).Delete(&SmsModel{}).Error
 }
+
+// AddInboxMessage stores a received SMS
+func AddInboxMessage(msg InboxModel) error {
+	return DB.Create(&msg).Error
+}
+
+// GetInbox returns received messages, newest first
+func GetInbox(limit int) ([]InboxModel, error) {
+	var msgs []InboxModel
+	result := DB.Order("received_at DESC").Limit(limit).Find(&msgs)
+	return msgs, result.Error
+}
+
+// DeleteInboxMessage removes a received message by ID
+func DeleteInboxMessage(id int64) error {
+	return DB.Delete(&InboxModel{}, id).Error
+}
+
+// AddDeliveries records the segments of one send
+func AddDeliveries(rows []DeliveryModel) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return DB.Create(&rows).Error
+}
+
+// ApplyStatusReport marks the newest pending segment with message reference
+// ref sent to recipient by the modem on port. Message references are per
+// modem and wrap at 256, so only that modem's segments (or rows written
+// before the port was recorded) submitted within the last week are
+// considered. It returns the updated row, or nil when no segment matched.
+func ApplyStatusReport(port string, recipient string, ref int, status string, code int, at time.Time) (*DeliveryModel, error) {
+	var candidates []DeliveryModel
+	err := DB.Where("message_ref = ? AND status = ? AND submitted_at > ? AND port IN ?", ref, "pending", time.Now().AddDate(0, 0, -7), []string{port, ""}).
+		Order("delivery_id DESC").Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range candidates {
+		if !SameNumber(c.Recipient, recipient) {
+			continue
+		}
+		c.Status = status
+		c.StatusCode = code
+		c.UpdatedAt = at
+		if err := DB.Model(&DeliveryModel{}).Where("delivery_id = ?", c.DeliveryID).
+			Updates(map[string]interface{}{"status": status, "status_code": code, "updated_at": at}).Error; err != nil {
+			return nil, err
+		}
+		return &c, nil
+	}
+	return nil, nil
+}
+
+// GetDeliverySummaries returns the outcome of the most recent sends, one
+// entry per recipient and send, newest first.
+func GetDeliverySummaries(limit int) ([]DeliverySummary, error) {
+	var batches []string
+	err := DB.Model(&DeliveryModel{}).Select("batch").Group("batch").
+		Order("MAX(delivery_id) DESC").Limit(limit).Pluck("batch", &batches).Error
+	if err != nil || len(batches) == 0 {
+		return nil, err
+	}
+
+	var rows []DeliveryModel
+	if err := DB.Where("batch IN ?", batches).Order("delivery_id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	byBatch := make(map[string]*DeliverySummary)
+	for _, r := range rows {
+		sum, ok := byBatch[r.Batch]
+		if !ok {
+			sum = &DeliverySummary{
+				Batch:           r.Batch,
+				AlarmHistorysID: r.AlarmHistorysID,
+				Recipient:       r.Recipient,
+				Port:            r.Port,
+				Segments:        r.Segments,
+				SubmittedAt:     r.SubmittedAt,
+			}
+			byBatch[r.Batch] = sum
+		}
+		if r.UpdatedAt.After(sum.UpdatedAt) {
+			sum.UpdatedAt = r.UpdatedAt
+		}
+		if r.Detail != "" {
+			sum.Detail = r.Detail
+		}
+		if r.Status == "delivered" {
+			sum.Delivered++
+		}
+		sum.Status = worseStatus(sum.Status, r.Status)
+	}
+
+	summaries := make([]DeliverySummary, 0, len(batches))
+	for _, b := range batches {
+		if sum, ok := byBatch[b]; ok {
+			summaries = append(summaries, *sum)
+		}
+	}
+	return summaries, nil
+}
+
+// worseStatus combines segment states: any failure fails the send, any
+// pending segment keeps it pending, and it is delivered only when all are.
+func worseStatus(a, b string) string {
+	rank := map[string]int{"": 0, "delivered": 1, "sent": 2, "pending": 3, "failed": 4}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// SameNumber compares phone numbers ignoring formatting and a national or
+// international prefix, by matching the shorter number's trailing digits.
+func SameNumber(a, b string) bool {
+	da, dbb := digitsOnly(a), digitsOnly(b)
+	if da == "" || dbb == "" {
+		return false
+	}
+	if len(da) > len(dbb) {
+		da, dbb = dbb, da
+	}
+	da = strings.TrimLeft(da, "0")
+	return len(da) >= 6 && strings.HasSuffix(dbb, da)
+}
+
+func digitsOnly(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
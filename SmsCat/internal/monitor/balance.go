@@ -0,0 +1,198 @@
+package monitor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// balanceStartDelay lets the modems come up before the first check.
+const balanceStartDelay = 2 * time.Minute
+
+// Balance is the prepaid credit last read from a modem's SIM.
+type Balance struct {
+	Time   time.Time
+	Amount float64
+	Text   string // the network's full answer
+	Low    bool   // below balance.threshold
+}
+
+func (s *Service) balanceLoop() {
+	defer s.wg.Done()
+
+	if s.Settings.BalanceUSSD == "" {
+		return
+	}
+	if _, err := s.balancePattern(); err != nil {
+		s.log(fmt.Sprintf("Balance check disabled: %v", err), false)
+		return
+	}
+
+	timer := time.NewTimer(balanceStartDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-timer.C:
+			s.CheckBalance()
+			timer.Reset(s.Settings.BalanceInterval)
+		}
+	}
+}
+
+func (s *Service) balancePattern() (*regexp.Regexp, error) {
+	pattern, err := regexp.Compile(s.Settings.BalancePattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid balance.pattern %q: %v", s.Settings.BalancePattern, err)
+	}
+	return pattern, nil
+}
+
+// CheckBalance queries the credit of every modem's SIM with the
+// balance.ussd code. It returns the last error, if any.
+func (s *Service) CheckBalance() error {
+	if s.Settings.BalanceUSSD == "" {
+		return fmt.Errorf("no balance.ussd code configured")
+	}
+	pattern, err := s.balancePattern()
+	if err != nil {
+		return err
+	}
+	if !s.HasModem() {
+		return fmt.Errorf("no modem configured")
+	}
+
+	var lastErr error
+	for _, slot := range s.slotList() {
+		if err := s.checkBalance(slot, pattern); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (s *Service) checkBalance(slot *modemSlot, pattern *regexp.Regexp) error {
+	reply, err := slot.modem.USSD(s.Settings.BalanceUSSD)
+	if err != nil {
+		s.log(fmt.Sprintf("Balance: %s: %v", slot.port, err), false)
+		return err
+	}
+	amount, ok := parseAmount(pattern, reply.Text)
+	if !ok {
+		err := fmt.Errorf("no amount in %q, check balance.pattern", reply.Text)
+		s.log(fmt.Sprintf("Balance: %s: %v", slot.port, err), false)
+		return err
+	}
+
+	threshold := s.Settings.BalanceThreshold
+	low := threshold > 0 && amount < threshold
+	slot.mu.Lock()
+	wasLow := slot.balance != nil && slot.balance.Low
+	slot.balance = &Balance{Time: time.Now(), Amount: amount, Text: reply.Text, Low: low}
+	slot.mu.Unlock()
+
+	switch {
+	case low:
+		s.log(fmt.Sprintf("WARNING: low credit on %s: %s (below %s)", slot.port, formatAmount(amount), formatAmount(threshold)), false)
+		if !wasLow {
+			s.alertLowBalance(slot.port, amount)
+		}
+	case wasLow:
+		s.log(fmt.Sprintf("Balance: %s: credit topped up to %s", slot.port, formatAmount(amount)), false)
+	default:
+		s.log(fmt.Sprintf("Balance: %s: %s", slot.port, formatAmount(amount)), true)
+	}
+	return nil
+}
+
+// alertLowBalance texts the admins once when a SIM's credit drops below
+// the threshold. The pool routes it around the starving SIM if it fails.
+func (s *Service) alertLowBalance(port string, amount float64) {
+	recipients := s.Settings.AdminNumbers
+	if len(recipients) == 0 {
+		var err error
+		if recipients, err = s.Store.FetchActiveRecipients(); err != nil {
+			s.log(fmt.Sprintf("Failed to fetch recipients: %v", err), false)
+			return
+		}
+	}
+	if len(recipients) == 0 {
+		s.log("Low credit but no admin numbers or active recipients found.", false)
+		return
+	}
+
+	s.mu.Lock()
+	lang := s.Language
+	s.mu.Unlock()
+
+	msg := fmt.Sprintf("SMSCat: low SIM credit on %s: %s (below %s). Please top up.",
+		port, formatAmount(amount), formatAmount(s.Settings.BalanceThreshold))
+	if lang == "cn" {
+		msg = fmt.Sprintf("SMSCat: %s 的SIM卡余额不足: %s (低于 %s)，请及时充值。",
+			port, formatAmount(amount), formatAmount(s.Settings.BalanceThreshold))
+	}
+	for _, number := range recipients {
+		s.Enqueue(SmsTask{Recipient: number, Message: msg})
+	}
+}
+
+// parseAmount extracts the credit from a USSD answer: the first group of
+// pattern, or the whole match. A decimal comma and thousands separators
+// are accepted ("1,234.56", "1 234,56", "1.234,56").
+func parseAmount(pattern *regexp.Regexp, text string) (float64, bool) {
+	m := pattern.FindStringSubmatch(text)
+	if m == nil {
+		return 0, false
+	}
+	value := m[0]
+	if len(m) > 1 {
+		value = m[1]
+	}
+	amount, err := strconv.ParseFloat(normalizeAmount(value), 64)
+	return amount, err == nil
+}
+
+// normalizeAmount drops the thousands separators of a number and turns
+// its decimal comma into a point. With both '.' and ',' the last one is
+// the decimal mark; a single one is a thousands separator when exactly
+// three digits follow a non-zero integer part, as in "1,234".
+func normalizeAmount(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '\'' {
+			return -1
+		}
+		return r
+	}, value)
+
+	dots, commas := strings.Count(value, "."), strings.Count(value, ",")
+	decimal := strings.LastIndexAny(value, ".,")
+	if decimal >= 0 && (dots == 0 || commas == 0) {
+		// One kind of separator: a decimal mark only if it occurs once
+		// and does not group three digits
+		integer := strings.TrimLeft(value[:decimal], "0")
+		if dots+commas > 1 || (len(value)-decimal-1 == 3 && integer != "") {
+			decimal = -1
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range value {
+		switch {
+		case i == decimal:
+			b.WriteByte('.')
+		case r == '.' || r == ',':
+			// thousands separator
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
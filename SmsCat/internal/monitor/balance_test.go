@@ -0,0 +1,41 @@
+package monitor
+
+import (
+	"regexp"
+	"testing"
+
+	"smallNfast/internal/config"
+)
+
+func TestParseAmount(t *testing.T) {
+	def := regexp.MustCompile(config.DefaultSettings().BalancePattern)
+	yuan := regexp.MustCompile(`余额(\S+)元`)
+
+	tests := []struct {
+		pattern *regexp.Regexp
+		text    string
+		want    float64
+		ok      bool
+	}{
+		{def, "Your balance is 12.50 CNY", 12.5, true},
+		{def, "Balance 12,50 EUR valid 30 days", 12.5, true},
+		{def, "Credit 100", 100, true},
+		{def, "Balance: 1,234.56", 1234.56, true},
+		{def, "Balance: 1 234,56 RUB", 1234.56, true},
+		{def, "Balance: 1\u00a0234,56 RUB", 1234.56, true},
+		{def, "Saldo: 1.234,56 EUR", 1234.56, true},
+		{def, "Saldo: 1.234.567,89 EUR", 1234567.89, true},
+		{def, "Balance 12,345 IDR", 12345, true},
+		{def, "Balance 0,125 KWD", 0.125, true},
+		{def, "Balance 25 and 300 SMS", 25, true},
+		{def, "no credit information", 0, false},
+		{yuan, "您的余额1,234.56元", 1234.56, true},
+		{yuan, "您的余额12,5元", 12.5, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseAmount(tt.pattern, tt.text)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseAmount(%q) = %v, %v; want %v, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
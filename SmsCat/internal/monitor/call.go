@@ -0,0 +1,112 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"smallNfast/internal/serial"
+)
+
+// callGap is the pause between two calls of one escalation.
+const callGap = 5 * time.Second
+
+// CallTask escalates one alarm by phone: the recipients are called in
+// order until one answers, or confirms with a key when call.confirm is set.
+type CallTask struct {
+	Recipients []string
+	AlarmID    int64
+}
+
+// EnqueueCall queues a call escalation. It returns false when the queue is
+// full and the task was dropped.
+func (s *Service) EnqueueCall(task CallTask) bool {
+	select {
+	case s.callQueue <- task:
+		return true
+	default:
+		s.log("Error: Call Queue Full! Dropping escalation.", false)
+		return false
+	}
+}
+
+func (s *Service) callLoop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case task := <-s.callQueue:
+			s.escalate(task)
+		}
+	}
+}
+
+// escalate works through the recipients of task until one of them
+// acknowledges the call.
+func (s *Service) escalate(task CallTask) {
+	opts := serial.CallOptions{
+		RingTime: s.Settings.CallRingTime,
+		Confirm:  s.Settings.CallConfirm,
+	}
+	for i, number := range task.Recipients {
+		if i > 0 {
+			select {
+			case <-s.stopChan:
+				return
+			case <-time.After(callGap):
+			}
+		}
+		if !s.HasModem() {
+			s.log("Error: No modem configured, skipping escalation call", false)
+			return
+		}
+		if !s.awaitRegistration(number) {
+			return
+		}
+
+		result, port, err := s.call(s.stopCtx, number, opts)
+		if err != nil && s.stopCtx.Err() != nil {
+			s.log(fmt.Sprintf("Alarm #%d: escalation cancelled", task.AlarmID), false)
+			return
+		}
+		if err != nil {
+			s.log(fmt.Sprintf("Failed to call %s on every modem: %v", number, err), false)
+			continue
+		}
+		s.log(fmt.Sprintf("Call to %s via %s: %s", number, port, result.Outcome), false)
+		if result.Outcome == serial.CallConfirmed || (result.Outcome == serial.CallAnswered && !opts.Confirm) {
+			s.log(fmt.Sprintf("Alarm #%d acknowledged by %s", task.AlarmID, number), false)
+			return
+		}
+	}
+	s.log(fmt.Sprintf("Alarm #%d: no recipient acknowledged the call", task.AlarmID), false)
+}
+
+// call places a voice call through the pool, moving on to the next modem
+// when one fails. Busy or unanswered calls are results, not failures, and
+// a call cancelled through ctx is not held against the modem.
+func (s *Service) call(ctx context.Context, number string, opts serial.CallOptions) (*serial.CallResult, string, error) {
+	tried := make(map[*modemSlot]bool)
+	lastErr := fmt.Errorf("no modem configured")
+	for {
+		slot := s.pickModem(tried)
+		if slot == nil {
+			return nil, "", lastErr
+		}
+		tried[slot] = true
+
+		result, err := slot.modem.Call(ctx, number, opts)
+		if err != nil && ctx.Err() != nil {
+			return nil, slot.port, err
+		}
+		slot.noteHealth(err)
+		if err == nil {
+			return result, slot.port, nil
+		}
+		s.log(fmt.Sprintf("Failed to call %s via %s: %v", number, slot.port, err), false)
+		s.noteModemError(slot, err)
+		lastErr = err
+	}
+}
@@ -0,0 +1,139 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// clockCheckInterval is how often the host and database clocks are
+// compared with the network time.
+const clockCheckInterval = time.Hour
+
+// ClockStatus is the last comparison of the host and database clocks
+// with the network time kept by a modem.
+type ClockStatus struct {
+	Time    time.Time // host clock when the modem was read
+	Port    string    // modem the network time came from
+	Network time.Time
+	// HostDrift and DBDrift are how far the host and database clocks run
+	// ahead of the network time, negative when behind. DBDrift is only
+	// set when DBKnown.
+	HostDrift time.Duration
+	DBDrift   time.Duration
+	DBKnown   bool
+	// Warnings are the clocks off by more than Settings.ClockDrift
+	Warnings []string
+}
+
+// checkClock reads the network time from the modem at most once per
+// clockCheckInterval and warns about a host or database clock that is off
+// by more than Settings.ClockDrift. It runs from the network poll.
+func (s *Service) checkClock(slot *modemSlot) {
+	if s.Settings.ClockDrift <= 0 && !s.Settings.NetworkTime {
+		return
+	}
+	s.clockMu.Lock()
+	due := time.Since(s.clockChecked) >= clockCheckInterval
+	if due {
+		s.clockChecked = time.Now()
+	}
+	s.clockMu.Unlock()
+	if !due {
+		return
+	}
+
+	before := time.Now()
+	network, err := slot.modem.Clock()
+	if err != nil {
+		s.log(fmt.Sprintf("Clock: %s: %v", slot.port, err), true)
+		return
+	}
+	// The reply took a round trip; the clock was read halfway through.
+	// It reports whole seconds, half a second short on average.
+	host := before.Add(time.Since(before) / 2)
+	network = network.Add(500 * time.Millisecond)
+	st := &ClockStatus{
+		Time:      host,
+		Port:      slot.port,
+		Network:   network,
+		HostDrift: host.Sub(network).Round(time.Second),
+	}
+	if dbNow, err := s.Store.Now(); err != nil {
+		s.log(fmt.Sprintf("Clock: cannot read the database clock: %v", err), true)
+	} else {
+		st.DBDrift = dbNow.Sub(network.Add(time.Since(host))).Round(time.Second)
+		st.DBKnown = true
+	}
+
+	if limit := s.Settings.ClockDrift; limit > 0 {
+		if abs(st.HostDrift) > limit {
+			st.Warnings = append(st.Warnings, fmt.Sprintf("host clock is %s, compared with the network time from %s", describeDrift(st.HostDrift), slot.port))
+		}
+		if st.DBKnown && abs(st.DBDrift) > limit {
+			st.Warnings = append(st.Warnings, fmt.Sprintf("database clock is %s, compared with the network time from %s; alarm times are off by as much", describeDrift(st.DBDrift), slot.port))
+		}
+	}
+
+	s.clockMu.Lock()
+	s.clock = st
+	s.clockMu.Unlock()
+
+	s.log(fmt.Sprintf("Clock: network time %s (from %s); host clock %s, database clock %s",
+		network.Format("2006-01-02 15:04:05 -07:00"), slot.port,
+		describeDrift(st.HostDrift), describeDBDrift(st)), true)
+	for _, w := range st.Warnings {
+		s.log("WARNING: "+w, false)
+	}
+}
+
+// ClockStatus returns the last clock comparison, or nil before the first.
+func (s *Service) ClockStatus() *ClockStatus {
+	s.clockMu.Lock()
+	defer s.clockMu.Unlock()
+	if s.clock == nil {
+		return nil
+	}
+	st := *s.clock
+	st.Warnings = append([]string(nil), s.clock.Warnings...)
+	return &st
+}
+
+// alarmTime is the time shown in an alarm message: createddate, moved to
+// network time when Settings.NetworkTime is on and the database clock's
+// drift is known.
+func (s *Service) alarmTime(created time.Time) time.Time {
+	if !s.Settings.NetworkTime {
+		return created
+	}
+	st := s.ClockStatus()
+	if st == nil || !st.DBKnown {
+		return created
+	}
+	return created.Add(-st.DBDrift)
+}
+
+// describeDrift renders a drift as "3m12s ahead", "40s behind" or
+// "in sync".
+func describeDrift(d time.Duration) string {
+	switch {
+	case d > 0:
+		return d.String() + " ahead"
+	case d < 0:
+		return (-d).String() + " behind"
+	}
+	return "in sync"
+}
+
+func describeDBDrift(st *ClockStatus) string {
+	if !st.DBKnown {
+		return "unknown"
+	}
+	return describeDrift(st.DBDrift)
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
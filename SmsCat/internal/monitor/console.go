@@ -0,0 +1,159 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"smallNfast/internal/serial"
+)
+
+// consoleHistory bounds the AT console transcript kept in memory.
+const consoleHistory = 500
+
+// ConsoleEntry is one command of the AT console transcript.
+type ConsoleEntry struct {
+	Time    time.Time
+	Port    string
+	Command string
+	Lines   []string // information lines of the answer
+	Final   string   // final result code, "" when none arrived
+	Error   string   // why the command was refused or got no answer
+}
+
+// RunATCommand sends an operator's command to the modem on port (the
+// preferred modem when empty), after checking it against the console
+// allow and deny lists. Every command, refused or not, is added to the
+// transcript, with PIN codes masked.
+func (s *Service) RunATCommand(port string, cmd string) ConsoleEntry {
+	cmd = strings.TrimSpace(cmd)
+	entry := ConsoleEntry{Time: time.Now(), Port: port, Command: serial.Redact(cmd)}
+	defer func() { s.addConsoleEntry(entry) }()
+
+	slot := s.slotFor(port)
+	if slot == nil {
+		entry.Error = "no modem configured"
+		return entry
+	}
+	entry.Port = slot.port
+	if err := checkConsoleCommand(cmd, s.Settings.ConsoleAllow, s.Settings.ConsoleDeny); err != nil {
+		entry.Error = err.Error()
+		s.log(fmt.Sprintf("AT console: [%s] %s refused: %v", entry.Port, entry.Command, err), false)
+		return entry
+	}
+
+	resp, err := slot.modem.RunCommand(cmd)
+	if resp != nil {
+		entry.Lines, entry.Final = resp.Lines, resp.Final
+	}
+	var atErr *serial.ATError
+	if err != nil && !errors.As(err, &atErr) {
+		entry.Error = err.Error()
+	}
+	s.log(fmt.Sprintf("AT console: [%s] %s -> %s", entry.Port, entry.Command, entry.result()), false)
+	return entry
+}
+
+// result summarizes the outcome for the log.
+func (e ConsoleEntry) result() string {
+	if e.Error != "" {
+		return e.Error
+	}
+	return e.Final
+}
+
+// checkConsoleCommand refuses anything but a single-line AT command, and
+// commands (or parts of a ';'-chained command line) outside allow or in
+// deny. Prefixes match case-insensitively; a denied extended command is
+// also found after a basic one ("ATI+CFUN=0"). Queries ("AT+CFUN?",
+// "AT+CFUN=?") pass the deny list.
+func checkConsoleCommand(cmd string, allow, deny []string) error {
+	upper := strings.ToUpper(cmd)
+	if !strings.HasPrefix(upper, "AT") {
+		return fmt.Errorf("not an AT command")
+	}
+	if strings.ContainsAny(cmd, "\r\n\x1a\x1b") {
+		return fmt.Errorf("control characters are not allowed")
+	}
+	// "AT+CSQ;+CFUN=0" runs both; check every part as a command of its own
+	for i, part := range strings.Split(upper[2:], ";") {
+		part = strings.TrimSpace(part)
+		if part == "" && i > 0 {
+			continue // trailing ';' of ATD<number>;
+		}
+		part = "AT" + strings.TrimPrefix(part, "AT")
+		if len(allow) > 0 && !hasPrefix(part, allow) {
+			return fmt.Errorf("%s is not in console.allow", serial.Redact(part))
+		}
+		if !isQuery(part) && denied(part, deny) {
+			return fmt.Errorf("%s is blocked by console.deny", serial.Redact(part))
+		}
+	}
+	return nil
+}
+
+func hasPrefix(cmd string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(cmd, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// denied reports whether cmd starts with an entry of deny or contains one
+// of its extended commands (AT+<name>).
+func denied(cmd string, deny []string) bool {
+	for _, p := range deny {
+		if strings.HasPrefix(cmd, p) || (strings.HasPrefix(p, "AT+") && strings.Contains(cmd, p[2:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// isQuery reports whether cmd only reads a setting or its range: it ends
+// in "?" or "=?" and sets no value.
+func isQuery(cmd string) bool {
+	body := strings.TrimSuffix(strings.TrimSuffix(cmd, "?"), "=")
+	return strings.HasSuffix(cmd, "?") && !strings.Contains(body, "=")
+}
+
+func (s *Service) addConsoleEntry(entry ConsoleEntry) {
+	s.consoleMu.Lock()
+	defer s.consoleMu.Unlock()
+	s.console = append(s.console, entry)
+	if len(s.console) > consoleHistory {
+		s.console = s.console[len(s.console)-consoleHistory:]
+	}
+}
+
+// ATTranscript returns the AT console commands of this session, oldest
+// first.
+func (s *Service) ATTranscript() []ConsoleEntry {
+	s.consoleMu.Lock()
+	defer s.consoleMu.Unlock()
+	return append([]ConsoleEntry(nil), s.console...)
+}
+
+// ExportATTranscript writes the transcript to path as plain text, the
+// way a terminal would have shown it.
+func (s *Service) ExportATTranscript(path string) error {
+	var b strings.Builder
+	for _, e := range s.ATTranscript() {
+		fmt.Fprintf(&b, "%s [%s] > %s\n", e.Time.Format("2006-01-02 15:04:05"), e.Port, e.Command)
+		for _, line := range e.Lines {
+			b.WriteString(line + "\n")
+		}
+		if e.Final != "" {
+			b.WriteString(e.Final + "\n")
+		}
+		if e.Error != "" {
+			b.WriteString("! " + e.Error + "\n")
+		}
+		b.WriteString("\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
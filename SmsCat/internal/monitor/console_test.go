@@ -0,0 +1,100 @@
+package monitor
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"smallNfast/internal/config"
+	"smallNfast/internal/serial"
+)
+
+func TestCheckConsoleCommand(t *testing.T) {
+	deny := config.DefaultConsoleDeny
+	allow := []string{"AT+CSQ", "AT+COPS", "AT+CPIN"}
+
+	tests := []struct {
+		cmd   string
+		allow []string
+		want  string // part of the error, "" passes
+	}{
+		{"AT+CSQ", nil, ""},
+		{"at+csq", nil, ""},
+		{"  AT+CSQ", nil, "not an AT command"},
+		{"+CSQ", nil, "not an AT command"},
+		{"AT+CSQ\r+CFUN=0", nil, "control characters"},
+		{"AT+CMGS=23\x1a", nil, "control characters"},
+		{"AT+CFUN=0", nil, "AT+CFUN=0 is blocked"},
+		{"at+cfun=0", nil, "AT+CFUN=0 is blocked"},
+		{"AT+CSQ;+CFUN=0", nil, "AT+CFUN=0 is blocked"},
+		{"at+csq;+cfun=0", nil, "AT+CFUN=0 is blocked"},
+		{"AT+CSQ; +CFUN=1,1", nil, "AT+CFUN=1,1 is blocked"},
+		{"AT+CSQ;AT+CFUN=0", nil, "AT+CFUN=0 is blocked"},
+		{"ATI+CFUN=0", nil, "ATI+CFUN=0 is blocked"},
+		{"ATE1", nil, "is blocked"},
+		{"AT+CFUN?", nil, ""},
+		{"at+cfun?", nil, ""},
+		{"AT+CFUN=?", nil, ""},
+		{"AT+CSQ;+CFUN?", nil, ""},
+		{"AT+CFUN=0?", nil, "AT+CFUN=0? is blocked"},
+		{"AT+CPIN?", nil, ""},
+		{"AT+CPIN=1234", nil, "is blocked"},
+		{"AT+CSQ;+CPIN=1234", nil, "is blocked"},
+		{"ATD+8613800138000;", nil, ""},
+		{"AT+COPS?", allow, ""},
+		{"at+csq;+cops?", allow, ""},
+		{"AT+CSQ;+CREG?", allow, "AT+CREG? is not in console.allow"},
+		{"ATI", allow, "ATI is not in console.allow"},
+		{"AT+CPIN=1234", allow, "is blocked"},
+	}
+	for _, tt := range tests {
+		err := checkConsoleCommand(tt.cmd, tt.allow, deny)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%q (allow %v): refused: %v", tt.cmd, tt.allow, err)
+		case tt.want != "" && err == nil:
+			t.Errorf("%q (allow %v): passed, want %q", tt.cmd, tt.allow, tt.want)
+		case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+			t.Errorf("%q (allow %v): %v, want %q", tt.cmd, tt.allow, err, tt.want)
+		}
+	}
+}
+
+func TestConsoleRedactsPIN(t *testing.T) {
+	s := newTestService(t, serial.NewSimulator(), &memStore{})
+	var mu sync.Mutex
+	var logs []string
+	s.LogFunc = func(msg string) {
+		mu.Lock()
+		logs = append(logs, msg)
+		mu.Unlock()
+	}
+	s.AddModem("SIM0", nil)
+
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"AT+CPIN=1234", "AT+CPIN=****"},
+		{"at+cpin=\"1234\"", "at+cpin=****"},
+		{"AT+CSQ;+CPIN=1234", "AT+CSQ;+CPIN=****"},
+		{"AT+CPIN?", "AT+CPIN?"},
+	}
+	for _, tt := range tests {
+		if entry := s.RunATCommand("", tt.cmd); entry.Command != tt.want {
+			t.Errorf("%q: transcript shows %q, want %q", tt.cmd, entry.Command, tt.want)
+		}
+	}
+	for _, entry := range s.ATTranscript() {
+		if strings.Contains(entry.Command, "1234") {
+			t.Errorf("transcript keeps the PIN: %q", entry.Command)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, msg := range logs {
+		if strings.Contains(msg, "1234") {
+			t.Errorf("log keeps the PIN: %q", msg)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"smallNfast/internal/db"
+	"smallNfast/internal/pdu"
+	"smallNfast/internal/serial"
+)
+
+// deliverySent marks segments accepted by the SMSC when no status report
+// was requested, so "pending" always means a report is still expected.
+const deliverySent = "sent"
+
+// portReport is a status report together with the modem it arrived on.
+type portReport struct {
+	port   string
+	report pdu.StatusReport
+}
+
+// SendDirect sends a one-off message through the pool outside the queue
+// and records its delivery like an alarm SMS.
+func (s *Service) SendDirect(number string, text string) error {
+	_, err := s.send(context.Background(), SmsTask{Recipient: number, Message: text})
+	return err
+}
+
+// recordDelivery stores one row per segment of a send on port. refs are
+// the message references returned by SendSMS; sendErr is its error, if any.
+func (s *Service) recordDelivery(port string, task SmsTask, refs []int, sendErr error) {
+	now := time.Now()
+	batch := fmt.Sprintf("%d-%s", now.UnixNano(), task.Recipient)
+
+	status := deliverySent
+	if s.Settings.StatusReports {
+		status = pdu.DeliveryPending
+	}
+
+	segments := len(refs)
+	if sendErr != nil {
+		segments++
+	}
+
+	rows := make([]db.DeliveryModel, 0, segments)
+	for i, mr := range refs {
+		rows = append(rows, db.DeliveryModel{
+			Batch:           batch,
+			Port:            port,
+			AlarmHistorysID: task.AlarmID,
+			Recipient:       task.Recipient,
+			MessageRef:      mr,
+			Segment:         i + 1,
+			Segments:        segments,
+			Status:          status,
+			SubmittedAt:     now,
+			UpdatedAt:       now,
+		})
+	}
+	if sendErr != nil {
+		detail := sendErr.Error()
+		if len(detail) > 255 {
+			detail = detail[:255]
+		}
+		rows = append(rows, db.DeliveryModel{
+			Batch:           batch,
+			Port:            port,
+			AlarmHistorysID: task.AlarmID,
+			Recipient:       task.Recipient,
+			MessageRef:      -1,
+			Segment:         len(refs) + 1,
+			Segments:        segments,
+			Status:          pdu.DeliveryFailed,
+			Detail:          detail,
+			SubmittedAt:     now,
+			UpdatedAt:       now,
+		})
+	}
+
+	if err := s.Store.AddDeliveries(rows); err != nil {
+		s.log(fmt.Sprintf("Failed to record delivery for %s: %v", task.Recipient, err), false)
+	}
+}
+
+// onStatusReport is the +CDS handler of the modem on port. It runs on the
+// modem reader, so the report is only decoded here and handed to reportLoop.
+func (s *Service) onStatusReport(port string, u serial.URC) {
+	report, err := pdu.DecodeStatusReport(u.Body)
+	if err != nil {
+		s.log(fmt.Sprintf("Ignoring undecodable status report: %v", err), true)
+		return
+	}
+	select {
+	case s.reports <- portReport{port: port, report: *report}:
+	default:
+		s.log("Status report queue full, dropping report", false)
+	}
+}
+
+func (s *Service) reportLoop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case pr := <-s.reports:
+			s.applyStatusReport(pr.port, pr.report)
+		}
+	}
+}
+
+func (s *Service) applyStatusReport(port string, report pdu.StatusReport) {
+	state := report.State()
+	row, err := s.Store.ApplyStatusReport(port, report.Recipient, report.MessageRef, state, report.Status, time.Now())
+	if err != nil {
+		s.log(fmt.Sprintf("Failed to record status report for %s: %v", report.Recipient, err), false)
+		return
+	}
+	if row == nil {
+		s.log(fmt.Sprintf("Status report for %s (ref %d) matches no pending SMS", report.Recipient, report.MessageRef), true)
+		return
+	}
+
+	alarm := "test message"
+	if row.AlarmHistorysID != 0 {
+		alarm = fmt.Sprintf("alarm #%d", row.AlarmHistorysID)
+	}
+	switch state {
+	case pdu.DeliveryDelivered:
+		s.log(fmt.Sprintf("Delivered to %s (%s, part %d/%d)", row.Recipient, alarm, row.Segment, row.Segments), false)
+	case pdu.DeliveryFailed:
+		s.log(fmt.Sprintf("Delivery to %s FAILED (%s, status 0x%02X)", row.Recipient, alarm, report.Status), false)
+	default:
+		s.log(fmt.Sprintf("Delivery to %s still pending (%s, status 0x%02X)", row.Recipient, alarm, report.Status), true)
+	}
+}
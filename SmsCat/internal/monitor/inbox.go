@@ -0,0 +1,166 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"smallNfast/internal/db"
+	"smallNfast/internal/serial"
+)
+
+const (
+	// inboxPollInterval is how often SIM storage is listed even without +CMTI.
+	inboxPollInterval = 60 * time.Second
+	// partialTimeout is how long missing parts of a concatenated message
+	// are waited for before the fragments are stored as incomplete.
+	partialTimeout = 24 * time.Hour
+)
+
+// partKey identifies the parts of one concatenated message. References
+// are per SIM, so the receiving port is part of the key.
+type partKey struct {
+	port   string
+	sender string
+	ref    int
+	total  int
+}
+
+// assembledMessage is a complete (or timed-out) inbound message together
+// with the storage indexes of its parts.
+type assembledMessage struct {
+	model   db.InboxModel
+	indexes []int
+}
+
+// signalInbox wakes the inbox worker. It is called from the modem's URC
+// reader, so it never blocks.
+func (s *Service) signalInbox() {
+	select {
+	case s.inboxSignal <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Service) inboxLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(inboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			// Periodic sweeps only run on an open port; a missing modem
+			// should not be reopened every minute just to list the inbox.
+			for _, slot := range s.slotList() {
+				if slot.modem.IsConnected() {
+					s.pollInbox(slot)
+				}
+			}
+		case <-s.inboxSignal:
+			for _, slot := range s.slotList() {
+				s.pollInbox(slot)
+			}
+		}
+	}
+}
+
+// pollInbox moves every complete message from the slot's SIM storage into
+// sms_inbox. Parts are only deleted from the SIM once the message is stored.
+func (s *Service) pollInbox(slot *modemSlot) {
+	modem := slot.modem
+	msgs, err := modem.ListMessages()
+	if err != nil {
+		s.log(fmt.Sprintf("Inbox: %s: failed to read SIM messages: %v", slot.port, err), true)
+		return
+	}
+	if len(msgs) == 0 {
+		return
+	}
+
+	for _, am := range s.assemble(slot.port, msgs, time.Now()) {
+		if err := s.Store.AddInboxMessage(am.model); err != nil {
+			s.log(fmt.Sprintf("Inbox: failed to store message from %s: %v", am.model.Sender, err), false)
+			continue
+		}
+		s.log(fmt.Sprintf("Received SMS from %s (%d part(s))", am.model.Sender, am.model.Parts), false)
+		for _, idx := range am.indexes {
+			if err := modem.DeleteMessage(idx); err != nil {
+				s.log(fmt.Sprintf("Inbox: %v", err), false)
+			}
+		}
+	}
+}
+
+// assemble groups concatenated parts received on port by sender and
+// reference. Messages
+// whose parts are all present, and singles, are returned; incomplete sets
+// are held back until partialTimeout has passed since they were first seen.
+func (s *Service) assemble(port string, msgs []serial.InboundMessage, now time.Time) []assembledMessage {
+	var out []assembledMessage
+	groups := make(map[partKey][]serial.InboundMessage)
+
+	for _, m := range msgs {
+		if m.ConcatTotal <= 1 {
+			out = append(out, assembledMessage{
+				model:   newInboxModel(m.Sender, m.SMSC, m.Text, 1, true, m.Timestamp, now),
+				indexes: []int{m.Index},
+			})
+			continue
+		}
+		key := partKey{port: port, sender: m.Sender, ref: m.ConcatRef, total: m.ConcatTotal}
+		groups[key] = append(groups[key], m)
+	}
+
+	for key, parts := range groups {
+		sort.Slice(parts, func(i, j int) bool { return parts[i].ConcatSeq < parts[j].ConcatSeq })
+
+		var text strings.Builder
+		var indexes []int
+		seen := make(map[int]bool)
+		for _, p := range parts {
+			indexes = append(indexes, p.Index)
+			if seen[p.ConcatSeq] {
+				continue // duplicate delivery of the same part
+			}
+			seen[p.ConcatSeq] = true
+			text.WriteString(p.Text)
+		}
+
+		complete := len(seen) == key.total
+		if !complete {
+			first, ok := s.partsSeen[key]
+			if !ok {
+				s.partsSeen[key] = now
+				continue
+			}
+			if now.Sub(first) < partialTimeout {
+				continue
+			}
+			s.log(fmt.Sprintf("Inbox: storing incomplete message from %s (%d/%d parts)", key.sender, len(seen), key.total), false)
+		}
+		delete(s.partsSeen, key)
+
+		out = append(out, assembledMessage{
+			model:   newInboxModel(key.sender, parts[0].SMSC, text.String(), len(seen), complete, parts[0].Timestamp, now),
+			indexes: indexes,
+		})
+	}
+	return out
+}
+
+func newInboxModel(sender, smsc, text string, parts int, complete bool, received, now time.Time) db.InboxModel {
+	return db.InboxModel{
+		Sender:     sender,
+		Message:    text,
+		SMSC:       smsc,
+		Parts:      parts,
+		Complete:   complete,
+		ReceivedAt: received,
+		StoredAt:   now,
+	}
+}
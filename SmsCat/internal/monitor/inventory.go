@@ -0,0 +1,81 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"smallNfast/internal/config"
+	"smallNfast/internal/serial"
+)
+
+// refreshInventory picks up the identity the modem read when it last
+// connected. It runs from the network poll; a new reading is logged and
+// its ICCID checked against the one seen in this modem before.
+func (s *Service) refreshInventory(slot *modemSlot) {
+	info := slot.modem.Info()
+	if info == nil {
+		return
+	}
+	slot.mu.Lock()
+	prev := slot.info
+	slot.info = info
+	slot.mu.Unlock()
+	if prev != nil && prev.Time.Equal(info.Time) {
+		return // nothing new since the last poll
+	}
+	// A reconnect of the same stick and SIM is only worth a verbose line
+	s.log(fmt.Sprintf("Modem %s: %s", slot.port, info), prev != nil && sameIdentity(prev, info))
+	s.checkSIM(info)
+}
+
+// sameIdentity reports whether two readings describe the same stick and SIM.
+func sameIdentity(a, b *serial.ModemInfo) bool {
+	x, y := *a, *b
+	x.Time, y.Time = time.Time{}, time.Time{}
+	return x == y
+}
+
+// checkSIM compares the ICCID with the one recorded for the modem's IMEI
+// in InventoryFile, warning when the SIM was swapped, and records
+// the current one.
+func (s *Service) checkSIM(info *serial.ModemInfo) {
+	if info.ICCID == "" {
+		return
+	}
+	key := info.IMEI
+	if key == "" {
+		key = "port." + info.Port
+	}
+
+	s.inventoryMu.Lock()
+	defer s.inventoryMu.Unlock()
+	known, err := config.LoadValues(s.InventoryFile)
+	if err != nil {
+		s.log(fmt.Sprintf("Failed to read %s: %v", s.InventoryFile, err), false)
+		return
+	}
+	prev, seen := known[key]
+	if prev == info.ICCID {
+		return
+	}
+	if seen {
+		s.log(fmt.Sprintf("WARNING: SIM swapped in modem %s (IMEI %s): ICCID %s, was %s", info.Port, info.IMEI, info.ICCID, prev), false)
+	}
+	if err := config.SaveValues(s.InventoryFile, map[string]string{key: info.ICCID}); err != nil {
+		s.log(fmt.Sprintf("Failed to save %s: %v", s.InventoryFile, err), false)
+	}
+}
+
+// ModemInfo returns the identity of every modem of the pool that has
+// connected, in priority order.
+func (s *Service) ModemInfo() []serial.ModemInfo {
+	var infos []serial.ModemInfo
+	for _, slot := range s.slotList() {
+		slot.mu.Lock()
+		if slot.info != nil {
+			infos = append(infos, *slot.info)
+		}
+		slot.mu.Unlock()
+	}
+	return infos
+}
@@ -0,0 +1,151 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"smallNfast/internal/serial"
+)
+
+const (
+	// networkPollInterval is how often signal and registration are sampled.
+	networkPollInterval = 30 * time.Second
+	// networkHistorySize keeps the last hour of samples.
+	networkHistorySize = 120
+	// registrationRecheck is how often a held send re-checks registration.
+	registrationRecheck = 10 * time.Second
+)
+
+func (s *Service) networkLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(networkPollInterval)
+	defer ticker.Stop()
+
+	s.pollAll()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.pollAll()
+		}
+	}
+}
+
+// pollAll health-checks every modem of the pool.
+func (s *Service) pollAll() {
+	for _, slot := range s.slotList() {
+		s.pollNetwork(slot)
+	}
+}
+
+// pollNetwork samples one modem and appends the result to its history.
+// The query doubles as the modem's health check. It returns nil when the
+// query failed.
+func (s *Service) pollNetwork(slot *modemSlot) *serial.NetworkStatus {
+	st, err := slot.modem.QueryNetwork()
+	slot.noteHealth(err)
+	if err != nil {
+		s.log(fmt.Sprintf("Network: %s: query failed: %v", slot.port, err), true)
+		s.noteModemError(slot, err)
+		return nil
+	}
+	s.clearSIMLock(slot)
+	s.refreshSMSC(slot)
+	s.refreshInventory(slot)
+	s.checkClock(slot)
+
+	slot.mu.Lock()
+	var prev *serial.NetworkStatus
+	if n := len(slot.netHistory); n > 0 {
+		prev = &slot.netHistory[n-1]
+	}
+	changed := prev == nil || prev.Registered != st.Registered || prev.Operator != st.Operator
+	slot.netHistory = append(slot.netHistory, *st)
+	if len(slot.netHistory) > networkHistorySize {
+		slot.netHistory = slot.netHistory[len(slot.netHistory)-networkHistorySize:]
+	}
+	slot.mu.Unlock()
+
+	s.log(fmt.Sprintf("Network: %s: %s", slot.port, describeNetwork(st)), !changed)
+	return st
+}
+
+// describeNetwork renders a sample for the log, e.g.
+// "registered (home) on CMCC LTE, -71 dBm".
+func describeNetwork(st *serial.NetworkStatus) string {
+	desc := "NOT registered (" + st.State + ")"
+	if st.Registered {
+		desc = "registered (" + st.State + ")"
+		if st.Operator != "" {
+			desc += " on " + st.Operator
+			if st.AccessTech != "" {
+				desc += " " + st.AccessTech
+			}
+		}
+	}
+	if st.RSSI < 0 {
+		return desc + ", no signal"
+	}
+	return fmt.Sprintf("%s, %d dBm", desc, st.RSSIdBm)
+}
+
+// NetworkStatus returns the preferred modem's latest sample (nil before
+// the first one) and its recent history, oldest first.
+func (s *Service) NetworkStatus() (*serial.NetworkStatus, []serial.NetworkStatus) {
+	slot := s.slotFor("")
+	if slot == nil {
+		return nil, nil
+	}
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	if len(slot.netHistory) == 0 {
+		return nil, nil
+	}
+	history := append([]serial.NetworkStatus(nil), slot.netHistory...)
+	latest := history[len(history)-1]
+	return &latest, history
+}
+
+// anyRegistered reports whether some modem may be able to send: one whose
+// latest sample is registered, or one with no sample yet. An unknown state
+// does not hold sends.
+func (s *Service) anyRegistered() (bool, *serial.NetworkStatus) {
+	var last *serial.NetworkStatus
+	for _, slot := range s.slotList() {
+		st := slot.latestNetwork()
+		if st == nil || st.Registered {
+			return true, nil
+		}
+		last = st
+	}
+	return last == nil, last
+}
+
+// awaitRegistration holds a send while every modem of the pool reports it
+// is not registered, re-checking every registrationRecheck. It returns
+// false if the service stops while waiting.
+func (s *Service) awaitRegistration(recipient string) bool {
+	ok, st := s.anyRegistered()
+	if ok {
+		return true
+	}
+
+	s.log(fmt.Sprintf("Holding SMS for %s: modem %s", recipient, describeNetwork(st)), false)
+	ticker := time.NewTicker(registrationRecheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return false
+		case <-ticker.C:
+			for _, slot := range s.slotList() {
+				if st := s.pollNetwork(slot); st == nil || st.Registered {
+					s.log(fmt.Sprintf("Resuming SMS for %s", recipient), false)
+					return true
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"smallNfast/internal/serial"
+)
+
+// Modem selection strategies (modem.select).
+const (
+	selectPriority   = "priority"
+	selectRoundRobin = "roundrobin"
+)
+
+// modemSlot is one modem of the pool together with its health, telemetry
+// and SIM state. Slots are kept in priority order.
+type modemSlot struct {
+	port    string
+	modem   serial.Modem
+	profile *serial.Profile // nil: the modem's default
+
+	mu         sync.Mutex
+	healthy    bool // last health check or send succeeded
+	checked    bool // a health check or send has run at least once
+	lastError  string
+	sent       int
+	failed     int
+	netHistory []serial.NetworkStatus
+	simLock    *serial.SIMLockError
+	simSMSC    string // service centre stored on the SIM
+	smscRead   bool
+	balance    *Balance
+	storage    *serial.StorageStatus
+	watchdog   WatchdogStats
+	info       *serial.ModemInfo
+}
+
+// ModemState is the per-modem status reported to the UI.
+type ModemState struct {
+	Port      string
+	Priority  int // 1 is preferred
+	Healthy   bool
+	Checked   bool
+	LastError string
+	Sent      int
+	Failed    int
+	Network   *serial.NetworkStatus
+	SIMLock   *serial.SIMLockError
+	SMSC      string
+	Balance   *Balance // nil until balance.ussd was queried
+	Storage   *serial.StorageStatus
+	Watchdog  WatchdogStats
+	Info      *serial.ModemInfo // nil until the modem connected
+}
+
+// latestNetwork returns the newest telemetry sample, or nil.
+func (m *modemSlot) latestNetwork() *serial.NetworkStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n := len(m.netHistory); n > 0 {
+		st := m.netHistory[n-1]
+		return &st
+	}
+	return nil
+}
+
+// usable reports whether the slot looks able to send: not SIM-locked, not
+// known to be unregistered, and healthy or not yet checked.
+func (m *modemSlot) usable() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.simLock != nil {
+		return false
+	}
+	if n := len(m.netHistory); n > 0 && !m.netHistory[n-1].Registered {
+		return false
+	}
+	return m.healthy || !m.checked
+}
+
+// noteHealth records the outcome of a health check or send.
+func (m *modemSlot) noteHealth(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checked = true
+	m.healthy = err == nil
+	if err != nil {
+		m.lastError = err.Error()
+	} else {
+		m.lastError = ""
+	}
+}
+
+// AddModem adds a modem on port to the pool with the lowest priority.
+// profile may be nil to use the forced or default profile.
+func (s *Service) AddModem(port string, profile *serial.Profile) {
+	s.poolMu.Lock()
+	for _, slot := range s.slots {
+		if slot.port == port {
+			s.poolMu.Unlock()
+			return
+		}
+	}
+	slot := s.newSlot(port, s.modemProfile(profile))
+	s.slots = append(s.slots, slot)
+	count := len(s.slots)
+	s.poolMu.Unlock()
+
+	s.signalInbox() // pick up messages that arrived while we were away
+	s.log(fmt.Sprintf("Modem port %s added (priority %d)", port, count), false)
+
+	// Update state to running if we were initializing
+	s.mu.Lock()
+	if s.State == "initializing" || s.State == "error" {
+		s.State = "running"
+	}
+	s.mu.Unlock()
+}
+
+// newSlot builds the modem on port and subscribes to its URCs.
+func (s *Service) newSlot(port string, profile *serial.Profile) *modemSlot {
+	slot := &modemSlot{port: port, profile: profile}
+	logFunc := func(msg string, verbose bool) { s.log(fmt.Sprintf("[%s] %s", port, msg), verbose) }
+	slot.modem = s.NewModem(port, profile, logFunc)
+	slot.modem.OnURC("+CMTI:", false, func(serial.URC) { s.signalInbox() })
+	slot.modem.OnURC("+CDS:", true, func(u serial.URC) { s.onStatusReport(port, u) })
+	return slot
+}
+
+// replaceSlot moves old to a modem on port, keeping its place in the pool
+// and its counters. It returns nil when old is no longer in the pool.
+func (s *Service) replaceSlot(old *modemSlot, port string) *modemSlot {
+	slot := s.newSlot(port, old.profile)
+	old.mu.Lock()
+	slot.sent, slot.failed = old.sent, old.failed
+	slot.watchdog = old.watchdog
+	slot.balance = old.balance
+	old.mu.Unlock()
+
+	s.poolMu.Lock()
+	found := false
+	for i := range s.slots {
+		if s.slots[i] == old {
+			s.slots[i], found = slot, true
+		}
+	}
+	s.poolMu.Unlock()
+	old.modem.Close()
+	if !found {
+		slot.modem.Close()
+		return nil
+	}
+	return slot
+}
+
+// SetModemPort replaces the pool with the single modem on port. An empty
+// port clears the pool so the next Start detects modems again. The old
+// modems are closed in the background, since one may be busy in a command
+// that cannot be cancelled; Start waits for them.
+func (s *Service) SetModemPort(port string) {
+	s.poolMu.Lock()
+	old := s.slots
+	s.slots = nil
+	prev := s.closed
+	closed := make(chan struct{})
+	s.closed = closed
+	s.poolMu.Unlock()
+	go func() {
+		for _, slot := range old {
+			slot.modem.Close()
+		}
+		<-prev
+		close(closed)
+	}()
+
+	if port != "" {
+		s.AddModem(port, nil)
+	}
+}
+
+// slotList returns a snapshot of the pool in priority order.
+func (s *Service) slotList() []*modemSlot {
+	s.poolMu.Lock()
+	defer s.poolMu.Unlock()
+	return append([]*modemSlot(nil), s.slots...)
+}
+
+// slotFor returns the slot on port, or the preferred slot when port is "".
+func (s *Service) slotFor(port string) *modemSlot {
+	for _, slot := range s.slotList() {
+		if port == "" || slot.port == port {
+			return slot
+		}
+	}
+	return nil
+}
+
+// Ports returns the ports of the pool in priority order.
+func (s *Service) Ports() []string {
+	var ports []string
+	for _, slot := range s.slotList() {
+		ports = append(ports, slot.port)
+	}
+	return ports
+}
+
+// HasModem reports whether at least one modem is configured.
+func (s *Service) HasModem() bool {
+	return len(s.slotList()) > 0
+}
+
+// ModemStates returns the status of every modem in priority order.
+func (s *Service) ModemStates() []ModemState {
+	slots := s.slotList()
+	states := make([]ModemState, 0, len(slots))
+	for i, slot := range slots {
+		network := slot.latestNetwork()
+		slot.mu.Lock()
+		states = append(states, ModemState{
+			Port:      slot.port,
+			Priority:  i + 1,
+			Healthy:   slot.healthy,
+			Checked:   slot.checked,
+			LastError: slot.lastError,
+			Sent:      slot.sent,
+			Failed:    slot.failed,
+			Network:   network,
+			SIMLock:   slot.simLock,
+			SMSC:      slot.simSMSC,
+			Balance:   slot.balance,
+			Storage:   slot.storage,
+			Watchdog:  slot.watchdog,
+			Info:      slot.info,
+		})
+		slot.mu.Unlock()
+	}
+	return states
+}
+
+// pickModem chooses the modem for the next send among those not tried yet.
+// Usable modems are preferred; when none looks usable the first untried
+// one is returned anyway, since a send reconnects and may succeed.
+func (s *Service) pickModem(tried map[*modemSlot]bool) *modemSlot {
+	var candidates, usable []*modemSlot
+	for _, slot := range s.slotList() {
+		if tried[slot] {
+			continue
+		}
+		candidates = append(candidates, slot)
+		if slot.usable() {
+			usable = append(usable, slot)
+		}
+	}
+	if len(usable) == 0 {
+		usable = candidates
+	}
+	if len(usable) == 0 {
+		return nil
+	}
+
+	if s.Settings.ModemSelect == selectRoundRobin && len(tried) == 0 {
+		s.poolMu.Lock()
+		s.rr++
+		slot := usable[s.rr%len(usable)]
+		s.poolMu.Unlock()
+		return slot
+	}
+	return usable[0]
+}
+
+// send delivers task through the pool, re-routing to the next modem when
+// one fails. Every attempt is recorded. It returns the modem that sent the
+// message, or the last error when all modems failed. A send cancelled
+// through ctx is not held against the modem and not re-routed. Neither is
+// one the network refused for its recipient or content, which every modem
+// would be refused, nor one of which a part may have reached the SMSC,
+// since sending it again would deliver those parts twice.
+func (s *Service) send(ctx context.Context, task SmsTask) (string, error) {
+	tried := make(map[*modemSlot]bool)
+	lastErr := fmt.Errorf("no modem configured")
+	for {
+		slot := s.pickModem(tried)
+		if slot == nil {
+			return "", lastErr
+		}
+		if len(tried) > 0 {
+			s.log(fmt.Sprintf("Re-routing SMS for %s to %s", task.Recipient, slot.port), false)
+		}
+		tried[slot] = true
+
+		refs, err := slot.modem.SendSMS(ctx, task.Recipient, task.Message, serial.SendOptions{
+			Validity:     task.Validity,
+			Flash:        task.Flash,
+			StatusReport: s.Settings.StatusReports,
+		})
+		s.recordDelivery(slot.port, task, refs, err)
+		if err != nil && ctx.Err() != nil {
+			s.log(fmt.Sprintf("SMS to %s via %s cancelled: %v", task.Recipient, slot.port, err), false)
+			return "", err
+		}
+		rejected := serial.IsRejected(err)
+		if rejected {
+			slot.noteHealth(nil) // the modem did its part
+		} else {
+			slot.noteHealth(err)
+		}
+		slot.mu.Lock()
+		if err != nil {
+			slot.failed++
+		} else {
+			slot.sent++
+		}
+		slot.mu.Unlock()
+
+		if err == nil {
+			return slot.port, nil
+		}
+		s.log(fmt.Sprintf("Failed to send to %s via %s: %v", task.Recipient, slot.port, err), false)
+		s.noteModemError(slot, err)
+		if rejected {
+			return "", err
+		}
+		if len(refs) > 0 || errors.Is(err, serial.ErrSubmitted) {
+			s.log(fmt.Sprintf("SMS to %s not re-routed: part of it may have been sent", task.Recipient), false)
+			return "", err
+		}
+		lastErr = err
+	}
+}
@@ -0,0 +1,59 @@
+package monitor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"smallNfast/internal/serial"
+)
+
+func TestSendReroutes(t *testing.T) {
+	long := strings.Repeat("Server room temperature high. ", 7) // two parts
+
+	tests := []struct {
+		name     string
+		text     string
+		fails    []int // +CMS ERROR per submission to SIM0, 0 accepts
+		port     string
+		sent0    int // parts accepted by SIM0
+		sent1    int // parts accepted by SIM1
+		rejected bool
+	}{
+		{"modem fault re-routed", "short", []int{500}, "SIM1", 0, 1, false},
+		{"fault on first part re-routed", long, []int{500}, "SIM1", 0, 2, false},
+		{"fault after part 1 not re-routed", long, []int{0, 500}, "", 1, 0, false},
+		{"unassigned number not re-routed", "short", []int{1}, "", 0, 0, true},
+		{"barred destination not re-routed", "short", []int{196}, "", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim0, sim1 := serial.NewSimulator(), serial.NewSimulator()
+			sim0.FailSends(tt.fails...)
+			store := &memStore{}
+			s := newPoolService(t, store, sim0, sim1)
+			for _, port := range s.Settings.ModemPorts {
+				s.AddModem(port, nil)
+			}
+
+			port, err := s.send(context.Background(), SmsTask{Recipient: "+8613800138000", Message: tt.text})
+			if port != tt.port || (err == nil) != (tt.port != "") {
+				t.Fatalf("send = %q, %v, want port %q", port, err, tt.port)
+			}
+			if serial.IsRejected(err) != tt.rejected {
+				t.Errorf("IsRejected(%v) = %v, want %v", err, !tt.rejected, tt.rejected)
+			}
+			if n := len(sim0.SentPDUs()); n != tt.sent0 {
+				t.Errorf("SIM0 accepted %d parts, want %d", n, tt.sent0)
+			}
+			if n := len(sim1.SentPDUs()); n != tt.sent1 {
+				t.Errorf("SIM1 accepted %d parts, want %d", n, tt.sent1)
+			}
+
+			states := s.ModemStates()
+			if tt.rejected && !states[0].Healthy {
+				t.Errorf("SIM0 marked unhealthy by a rejected number: %s", states[0].LastError)
+			}
+		})
+	}
+}
@@ -1,11 +1,13 @@
 package monitor
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"smallNfast/internal/config"
 	"smallNfast/internal/db"
 	"smallNfast/internal/logger"
 	"smallNfast/internal/serial"
@@ -15,39 +17,185 @@ import (
 type SmsTask struct {
 	Recipient string
 	Message   string
+	AlarmID   int64 // alarm_historys_id, 0 when not triggered by an alarm
+	// Flash sends the message as class 0, shown at once on the handset
+	Flash bool
+	// Validity overrides how long the SMSC keeps an undelivered message
+	Validity time.Duration
 }
 
+// stopTimeout bounds how long Stop waits for the workers to finish the
+// send or call they were cancelled in, and how long Start then waits for
+// the ones still busy.
+const stopTimeout = 10 * time.Second
+
 type Service struct {
-	DB       *db.DBConfig // active config
-	Modem    *serial.GSMModem
-	stopChan chan struct{}
-	wg       sync.WaitGroup
-	LogFunc  func(string) // Callback for logging to UI
-	PortName string
-	State    string // "stopped", "initializing", "running", "error"
-	mu       sync.Mutex
-	smsQueue chan SmsTask
-	Language string
+	DB        *db.DBConfig // active config
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+	LogFunc   func(string) // Callback for logging to UI
+	State     string       // "stopped", "initializing", "running", "error"
+	mu        sync.Mutex
+	smsQueue  chan SmsTask
+	callQueue chan CallTask
+	Language  string
+	Settings  *config.Settings
+
+	// NewModem builds the modem for a port. Defaults to a GSMModem;
+	// tests swap in a simulator-backed or fake modem.
+	NewModem func(port string, profile *serial.Profile, logFunc func(string, bool)) serial.Modem
+	// FindModems lists the attached modems matching profiles (all when
+	// none are given). Defaults to serial.FindModemPorts.
+	FindModems func(profiles ...*serial.Profile) ([]serial.DetectedModem, error)
+	// Store persists recipients, inbox and delivery state. Defaults to the
+	// S4M database.
+	Store Store
+
+	// ConcatRefs numbers the parts of long messages per recipient, shared
+	// by every modem. Defaults to counters saved in config.ConcatRefsFile.
+	ConcatRefs *serial.ConcatRefs
+	// InventoryFile records the SIM last seen in each modem. Defaults to
+	// config.InventoryFile.
+	InventoryFile string
+
+	// The modem pool, in priority order
+	poolMu sync.Mutex
+	slots  []*modemSlot
+	rr     int           // round-robin cursor
+	closed chan struct{} // closed once the modems SetModemPort dropped are closed
+
+	inboxSignal chan struct{}
+	partsSeen   map[partKey]time.Time
+	reports     chan portReport
+
+	storageMu   sync.Mutex // guards Settings.Storage, which the UI can change
+	inventoryMu sync.Mutex // serializes updates of InventoryFile
+
+	consoleMu sync.Mutex
+	console   []ConsoleEntry // AT console transcript of this session
+
+	clockMu      sync.Mutex
+	clock        *ClockStatus
+	clockChecked time.Time
+
+	// stopCtx is cancelled by Stop, aborting the sends and calls in flight
+	stopCtx   context.Context
+	cancelRun context.CancelFunc
+	runDone   chan struct{} // closed when the workers of the last Start exited
 }
 
 func NewService(logFunc func(string)) *Service {
-	return &Service{
-		stopChan: make(chan struct{}),
-		LogFunc:  logFunc,
-		smsQueue: make(chan SmsTask, 100), // Buffer of 100 SMS
-		Language: "en",
-		State:    "stopped",
+	s := &Service{
+		stopChan:    make(chan struct{}),
+		LogFunc:     logFunc,
+		smsQueue:    make(chan SmsTask, 100), // Buffer of 100 SMS
+		callQueue:   make(chan CallTask, 16),
+		Language:    "en",
+		State:       "stopped",
+		Settings:    config.DefaultSettings(),
+		Store:       dbStore{},
+		inboxSignal: make(chan struct{}, 1),
+		partsSeen:   make(map[partKey]time.Time),
+		reports:     make(chan portReport, 32),
+		ConcatRefs:  serial.NewConcatRefs(config.ConcatRefsFile),
+	}
+	s.NewModem = s.newGSMModem
+	s.FindModems = serial.FindModemPorts
+	s.InventoryFile = config.InventoryFile
+	s.stopCtx, s.cancelRun = context.WithCancel(context.Background())
+	s.runDone = make(chan struct{})
+	close(s.runDone) // nothing running yet
+	s.closed = make(chan struct{})
+	close(s.closed)
+	return s
+}
+
+// newGSMModem is the default NewModem: a GSMModem configured from Settings.
+func (s *Service) newGSMModem(port string, profile *serial.Profile, logFunc func(string, bool)) serial.Modem {
+	m := serial.NewGSMModem(port, logFunc)
+	m.ConcatRefs = s.ConcatRefs
+	m.Concat16 = s.Settings.Concat16
+	m.Profile = profile
+	m.PIN = s.Settings.SIMPIN
+	m.SMSC = s.Settings.SMSC
+	m.Storage = s.StoragePolicy().Memory
+	line := s.Settings.SerialLine(port)
+	if line.Baud > 0 {
+		m.BaudRate = line.Baud
+	} else {
+		m.AutoBaud = true
+	}
+	m.Line = serial.LineConfig{
+		DataBits:       line.DataBits,
+		Parity:         line.Parity,
+		StopBits:       line.StopBits,
+		FlowControl:    line.FlowControl,
+		ReadTimeout:    line.ReadTimeout,
+		CommandTimeout: line.CommandTimeout,
+	}
+	if dir := s.Settings.CaptureDir; dir != "" {
+		m.OpenFunc = serial.RecordTo(m.OpenFunc, dir, logFunc)
+	}
+	return m
+}
+
+// modemProfile returns the profile forced in Settings, else detected (the
+// one matched during auto-detection). Nil leaves the modem on its default.
+func (s *Service) modemProfile(detected *serial.Profile) *serial.Profile {
+	if name := s.Settings.ModemProfile; name != "" {
+		if p := serial.LookupProfile(name); p != nil {
+			return p
+		}
+		s.log(fmt.Sprintf("Unknown modem.profile %q, using auto-detection", name), false)
 	}
+	return detected
 }
 
-func (s *Service) Start() {
+// Start runs the workers. Workers of a Stop that timed out, and modems
+// still closing after SetModemPort, are waited for up to stopTimeout; a
+// modem operation that is stuck longer fails the start, and a later
+// Start can try again.
+func (s *Service) Start() error {
 	s.mu.Lock()
 	if s.State == "running" || s.State == "initializing" {
 		s.mu.Unlock()
-		return
+		return nil
+	}
+	prev := s.runDone
+	s.mu.Unlock()
+	s.poolMu.Lock()
+	closed := s.closed
+	s.poolMu.Unlock()
+
+	idle := make(chan struct{})
+	go func() {
+		<-prev
+		<-closed
+		close(idle)
+	}()
+	select {
+	case <-idle:
+	default:
+		s.log("Waiting for the previous workers to finish...", false)
+		select {
+		case <-idle:
+		case <-time.After(stopTimeout):
+			err := fmt.Errorf("a modem operation of the previous run is still busy after %v", stopTimeout)
+			s.log(fmt.Sprintf("Alarm Monitor not started: %v", err), false)
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	if s.State == "running" || s.State == "initializing" {
+		s.mu.Unlock()
+		return nil
 	}
 	s.State = "initializing"
 	s.stopChan = make(chan struct{})
+	s.stopCtx, s.cancelRun = context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.runDone = done
 	s.mu.Unlock()
 
 	s.wg.Add(1)
@@ -56,58 +204,90 @@ func (s *Service) Start() {
 	s.wg.Add(1)
 	go s.processSmsQueue() // Start SMS worker
 
+	s.wg.Add(1)
+	go s.callLoop() // Start voice call escalation worker
+
+	s.wg.Add(1)
+	go s.inboxLoop() // Start inbound SMS worker
+
+	s.wg.Add(1)
+	go s.reportLoop() // Start delivery status worker
+
+	s.wg.Add(1)
+	go s.networkLoop() // Start signal/registration telemetry
+
+	s.wg.Add(1)
+	go s.balanceLoop() // Start prepaid credit checks
+
+	s.wg.Add(1)
+	go s.storageLoop() // Start SIM storage cleanup
+
+	s.wg.Add(1)
+	go s.watchdogLoop() // Start modem watchdog
+
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
 	s.log("Alarm Monitor Started", false)
 
-	// Auto-detect port if not set (in background to avoid blocking)
+	// Auto-detect modems if none are set (in background to avoid blocking).
+	// It belongs to this run, so Stop and the next Start wait for it too
+	s.wg.Add(1)
 	go func() {
-		if s.PortName == "" {
-			port, err := serial.FindModemPort()
+		defer s.wg.Done()
+		switch {
+		case s.HasModem():
+			// Ports already set manually or previous config
+			s.mu.Lock()
+			s.State = "running"
+			s.mu.Unlock()
+		case len(s.Settings.ModemPorts) > 0:
+			for _, port := range s.Settings.ModemPorts {
+				s.AddModem(port, nil)
+			}
+		default:
+			found, err := s.FindModems()
 			if err != nil {
 				s.log(fmt.Sprintf("Auto-detection failed: %v", err), false)
 				s.mu.Lock()
 				s.State = "error" // Failed to detect
 				s.mu.Unlock()
-			} else {
-				s.log(fmt.Sprintf("Auto-detected Modem at %s", port), false)
-				s.SetModemPort(port)
-				// SetModemPort will set state to running if success
+				return
+			}
+			for _, m := range found {
+				s.log(fmt.Sprintf("Auto-detected %s modem at %s", m.Profile.Vendor, m.Port), false)
+				s.AddModem(m.Port.Path, m.Profile)
+				// AddModem will set state to running
 			}
-		} else {
-			// Port already set manually or previous config
-			s.mu.Lock()
-			s.State = "running"
-			s.mu.Unlock()
 		}
 	}()
+	return nil
 }
 
+// Stop ends the workers, cancelling the SMS or call in flight, and waits
+// for them up to stopTimeout. A worker stuck past that is left to finish
+// on its own; Start waits for it.
 func (s *Service) Stop() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	if s.State == "stopped" {
+		s.mu.Unlock()
 		return
 	}
 	close(s.stopChan)
-	s.wg.Wait()
+	s.cancelRun()
+	done := s.runDone
 	s.State = "stopped"
-	s.log("Alarm Monitor Stopped", false)
-}
-
-func (s *Service) SetModemPort(port string) {
-	// If modem is changing, we might need to re-init
-	if s.Modem != nil {
-		s.Modem.Close()
-	}
-	s.PortName = port
-	s.Modem = serial.NewGSMModem(port, s.log)
-	s.log(fmt.Sprintf("Modem port set to %s", port), false)
+	s.mu.Unlock()
 
-	// Update state to running if we were initializing
-	s.mu.Lock()
-	if s.State == "initializing" || s.State == "error" {
-		s.State = "running"
+	// Not under s.mu: workers take it, e.g. for the language
+	select {
+	case <-done:
+		s.log("Alarm Monitor Stopped", false)
+	case <-time.After(stopTimeout):
+		s.log(fmt.Sprintf("WARNING: Alarm Monitor stopped, but a worker is still busy after %v", stopTimeout), false)
 	}
-	s.mu.Unlock()
 }
 
 func (s *Service) SetLanguage(lang string) {
@@ -139,7 +319,7 @@ func (s *Service) loop() {
 
 	// Initialize tracking time
 	var lastCheckedTime time.Time
-	startT, err := db.GetMaxCreatedDate()
+	startT, err := s.Store.MaxAlarmDate()
 	if err != nil {
 		s.log(fmt.Sprintf("Error getting start time: %v, using NOW", err), false)
 		lastCheckedTime = time.Now()
@@ -159,30 +339,8 @@ func (s *Service) loop() {
 }
 
 func (s *Service) checkDetailedAlarms(lastTime *time.Time) {
-	var results []db.AlarmDetailDTO
-
-	// Complex query as requested
-	// SELECT ... FROM ... WHERE ah.createddate > ? AND as_tab.sms = 1
-	// ALIAS 'description' -> '..._description' to match DTO
-	query := `
-		SELECT
-			ah.createddate,
-			ah.alarm_status,
-			as_tab.threshold, as_tab.hysteresis, as_tab.direction,
-			c.channel_description, c.unit_index, c.unit_in_ascii,
-			c.measurement_value, c.resolution,
-			s.description AS sensor_description,
-			l.description AS location_description
-		FROM alarm_historys ah
-		JOIN alarm_settings as_tab ON ah.alarm_setting_id = as_tab.alarm_setting_id
-		JOIN channels c ON as_tab.channel_id = c.channel_id
-		JOIN sensors s ON c.logic_sensor_id = s.sensor_id
-		JOIN locations l ON s.location_id = l.location_id
-		WHERE ah.createddate > ? AND as_tab.sms = 1
-		ORDER BY ah.createddate ASC
-	`
-
-	if err := db.DB.Raw(query, *lastTime).Scan(&results).Error; err != nil {
+	results, err := s.Store.FetchAlarmDetails(*lastTime)
+	if err != nil {
 		s.log(fmt.Sprintf("Error checking detailed alarms: %v", err), false)
 		return
 	}
@@ -199,7 +357,7 @@ func (s *Service) checkDetailedAlarms(lastTime *time.Time) {
 
 func (s *Service) handleDetailedSms(details db.AlarmDetailDTO) {
 	// 1. Fetch Recipients
-	recipients, err := db.FetchActiveRecipients()
+	recipients, err := s.Store.FetchActiveRecipients()
 	if err != nil {
 		s.log(fmt.Sprintf("Failed to fetch recipients: %v", err), false)
 		return
@@ -216,6 +374,7 @@ func (s *Service) handleDetailedSms(details db.AlarmDetailDTO) {
 	s.mu.Unlock()
 
 	// Format Value based on resolution
+	createdAt := s.alarmTime(details.CreatedDate).Format("2006-01-02 15:04:05")
 	valStr := s.formatValue(details.MeasurementValue, details.Resolution)
 
 	var msg string
@@ -245,7 +404,7 @@ func (s *Service) handleDetailedSms(details db.AlarmDetailDTO) {
 				"方向: %s\n"+
 				"当前值: %s",
 			statusStr,
-			details.CreatedDate.Format("2006-01-02 15:04:05"),
+			createdAt,
 			details.LocationDescription,
 			details.SensorDescription,
 			details.ChannelDescription,
@@ -281,7 +440,7 @@ func (s *Service) handleDetailedSms(details db.AlarmDetailDTO) {
 				"Direction: %s\n"+
 				"Current value: %s",
 			statusStr,
-			details.CreatedDate.Format("2006-01-02 15:04:05"),
+			createdAt,
 			details.LocationDescription,
 			details.SensorDescription,
 			details.ChannelDescription,
@@ -293,16 +452,39 @@ func (s *Service) handleDetailedSms(details db.AlarmDetailDTO) {
 		)
 	}
 
-	// 3. Queue Send
-	s.log(fmt.Sprintf("Queueing SMS for %d recipients...", len(recipients)), false)
+	// 3. Queue Send (critical alarms are also, or only, escalated by phone)
+	critical := details.AlarmStatus != 0 && s.Settings.IsCritical(details.AlarmSettingID)
+	if !critical || s.Settings.CallMode != "call" {
+		s.log(fmt.Sprintf("Queueing SMS for %d recipients...", len(recipients)), false)
 
-	for _, number := range recipients {
-		select {
-		case s.smsQueue <- SmsTask{Recipient: number, Message: msg}:
-		default:
-			s.log("Error: SMS Queue Full! Dropping message.", false)
+		task := SmsTask{Message: msg, AlarmID: details.AlarmHistorysID}
+		if critical {
+			task.Flash = s.Settings.CriticalFlash
+		}
+		if details.AlarmStatus == 0 {
+			task.Validity = s.Settings.ResumedValidity
+		}
+		for _, number := range recipients {
+			task.Recipient = number
+			s.Enqueue(task)
 		}
 	}
+	if critical {
+		s.log(fmt.Sprintf("Critical alarm #%d: queueing call escalation to %d recipients...", details.AlarmHistorysID, len(recipients)), false)
+		s.EnqueueCall(CallTask{Recipients: recipients, AlarmID: details.AlarmHistorysID})
+	}
+}
+
+// Enqueue queues a single SMS for the worker. It returns false when the
+// queue is full and the task was dropped.
+func (s *Service) Enqueue(task SmsTask) bool {
+	select {
+	case s.smsQueue <- task:
+		return true
+	default:
+		s.log("Error: SMS Queue Full! Dropping message.", false)
+		return false
+	}
 }
 
 func (s *Service) formatValue(val float64, res int) string {
@@ -334,21 +516,36 @@ func (s *Service) processSmsQueue() {
 			return
 
 		case task := <-s.smsQueue:
-			if s.Modem == nil {
+			if !s.HasModem() {
 				s.log("Error: No modem configured, skipping queued SMS", false)
 				continue
 			}
 
+			if !s.awaitRegistration(task.Recipient) {
+				s.log("SMS Queue Worker Stopped", false)
+				return
+			}
+
 			s.log(fmt.Sprintf("Processing SMS for %s...", task.Recipient), false)
-			err := s.Modem.SendSMS(task.Recipient, task.Message)
+			port, err := s.send(s.stopCtx, task)
+			if err != nil && s.stopCtx.Err() != nil {
+				continue // stopping; send logged the cancelled message
+			}
 			if err != nil {
 				s.log(fmt.Sprintf("Failed to send to %s: %v", task.Recipient, err), false)
+			} else if s.Settings.StatusReports {
+				s.log(fmt.Sprintf("Sent to %s via %s, awaiting delivery report", task.Recipient, port), false)
 			} else {
-				s.log(fmt.Sprintf("Sent to %s", task.Recipient), false)
+				s.log(fmt.Sprintf("Sent to %s via %s", task.Recipient, port), false)
 			}
 
 			// Optional: Small delay between messages to be polite to the modem/network
-			time.Sleep(2 * time.Second)
+			select {
+			case <-s.stopChan:
+				s.log("SMS Queue Worker Stopped", false)
+				return
+			case <-time.After(2 * time.Second):
+			}
 		}
 	}
 }
@@ -0,0 +1,211 @@
+package monitor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"smallNfast/internal/db"
+	"smallNfast/internal/pdu"
+	"smallNfast/internal/serial"
+)
+
+// memStore is an in-memory Store.
+type memStore struct {
+	mu         sync.Mutex
+	alarms     []db.AlarmDetailDTO
+	recipients []string
+	deliveries []db.DeliveryModel
+	inbox      []db.InboxModel
+}
+
+func (m *memStore) MaxAlarmDate() (time.Time, error) {
+	return time.Now().Add(-time.Minute), nil
+}
+
+func (m *memStore) FetchAlarmDetails(since time.Time) ([]db.AlarmDetailDTO, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []db.AlarmDetailDTO
+	for _, a := range m.alarms {
+		if a.CreatedDate.After(since) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) FetchActiveRecipients() ([]string, error) {
+	return m.recipients, nil
+}
+
+func (m *memStore) AddInboxMessage(msg db.InboxModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inbox = append(m.inbox, msg)
+	return nil
+}
+
+func (m *memStore) AddDeliveries(rows []db.DeliveryModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, rows...)
+	return nil
+}
+
+func (m *memStore) ApplyStatusReport(port string, recipient string, ref int, status string, code int, at time.Time) (*db.DeliveryModel, error) {
+	return nil, nil
+}
+
+func (m *memStore) Now() (time.Time, error) {
+	return time.Now(), nil
+}
+
+func (m *memStore) Deliveries() []db.DeliveryModel {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]db.DeliveryModel(nil), m.deliveries...)
+}
+
+// newTestService returns a Service whose single modem is sim, with its
+// state files in a temporary directory.
+func newTestService(t *testing.T, sim *serial.Simulator, store Store) *Service {
+	return newPoolService(t, store, sim)
+}
+
+// newPoolService returns a Service with one modem per simulator, on ports
+// SIM0, SIM1 and so on in priority order.
+func newPoolService(t *testing.T, store Store, sims ...*serial.Simulator) *Service {
+	t.Helper()
+	dir := t.TempDir()
+	s := NewService(func(msg string) { t.Log(msg) })
+	s.Store = store
+	s.ConcatRefs = serial.NewConcatRefs(filepath.Join(dir, "concat_refs.properties"))
+	s.InventoryFile = filepath.Join(dir, "modem_inventory.properties")
+	bySlot := make(map[string]*serial.Simulator)
+	s.Settings.ModemPorts = nil
+	for i, sim := range sims {
+		port := fmt.Sprintf("SIM%d", i)
+		bySlot[port] = sim
+		s.Settings.ModemPorts = append(s.Settings.ModemPorts, port)
+	}
+	s.NewModem = func(port string, profile *serial.Profile, logFunc func(string, bool)) serial.Modem {
+		m := serial.NewGSMModem(port, logFunc)
+		m.ConcatRefs = s.ConcatRefs
+		m.OpenFunc = bySlot[port].Open
+		return m
+	}
+	t.Cleanup(func() {
+		s.SetModemPort("")
+		<-s.closed
+	})
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func TestAlarmIsSentAsPDU(t *testing.T) {
+	sim := serial.NewSimulator()
+	store := &memStore{
+		recipients: []string{"+8613800138000"},
+		alarms: []db.AlarmDetailDTO{{
+			AlarmHistorysID:     42,
+			AlarmSettingID:      7,
+			CreatedDate:         time.Now(),
+			AlarmStatus:         1,
+			Threshold:           30,
+			Direction:           0,
+			ChannelDescription:  "Temperature",
+			UnitInAscii:         "C",
+			MeasurementValue:    31.5,
+			Resolution:          1,
+			SensorDescription:   "Rack sensor",
+			LocationDescription: "Server room",
+		}},
+	}
+	s := newTestService(t, sim, store)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// Every part of the message is recorded once the send completed
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		rows := store.Deliveries()
+		if len(rows) > 0 && len(rows) == rows[0].Segments {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message not sent within 20s, deliveries: %+v", rows)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	rows := store.Deliveries()
+	for _, row := range rows {
+		if row.AlarmHistorysID != 42 || row.Port != "SIM0" || row.Status == "failed" {
+			t.Errorf("delivery = alarm %d via %q %s, want alarm 42 via SIM0", row.AlarmHistorysID, row.Port, row.Status)
+		}
+	}
+	sent := sim.SentPDUs()
+	if len(sent) != len(rows) {
+		t.Fatalf("sent %d PDUs, recorded %d parts", len(sent), len(rows))
+	}
+	var text strings.Builder
+	for _, hex := range sent {
+		submit, err := pdu.DecodeSubmit(hex)
+		if err != nil {
+			t.Fatalf("DecodeSubmit(%s): %v", hex, err)
+		}
+		if submit.Recipient != "+8613800138000" {
+			t.Errorf("recipient = %q, want +8613800138000", submit.Recipient)
+		}
+		text.WriteString(submit.Text)
+	}
+	for _, want := range []string{"Alarm triggered", "Server room", "Rack sensor", "Temperature", "31.5"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("message lacks %q:\n%s", want, text.String())
+		}
+	}
+}
+
+// stuckModem is a modem whose Close blocks until release is closed, like
+// one busy in a command that cannot be cancelled.
+type stuckModem struct {
+	serial.Modem
+	release chan struct{}
+}
+
+func (m stuckModem) Close() {
+	<-m.release
+	m.Modem.Close()
+}
+
+func TestRestartWithStuckModem(t *testing.T) {
+	s := newTestService(t, serial.NewSimulator(), &memStore{})
+	release := make(chan struct{})
+	newModem := s.NewModem
+	s.NewModem = func(port string, profile *serial.Profile, logFunc func(string, bool)) serial.Modem {
+		return stuckModem{newModem(port, profile, logFunc), release}
+	}
+	s.AddModem("SIM0", nil)
+
+	begin := time.Now()
+	s.SetModemPort("")
+	if d := time.Since(begin); d > time.Second {
+		t.Errorf("SetModemPort blocked %v on the busy modem", d)
+	}
+	if err := s.Start(); err == nil {
+		t.Fatal("Start succeeded while the old modem was still busy")
+	}
+	if time.Since(begin) > stopTimeout+5*time.Second {
+		t.Errorf("Start gave up after %v, want about %v", time.Since(begin), stopTimeout)
+	}
+
+	close(release)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start once the modem closed: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"smallNfast/internal/serial"
+)
+
+// noteModemError remembers whether err says the slot's SIM is locked so
+// the UI can ask for a PIN/PUK. The lock is logged once, not on every retry.
+func (s *Service) noteModemError(slot *modemSlot, err error) {
+	var lockErr *serial.SIMLockError
+	if !errors.As(err, &lockErr) {
+		return
+	}
+	slot.mu.Lock()
+	changed := slot.simLock == nil || slot.simLock.State != lockErr.State || slot.simLock.Reason != lockErr.Reason
+	slot.simLock = lockErr
+	slot.mu.Unlock()
+	if changed {
+		s.log(fmt.Sprintf("%s: %v", slot.port, lockErr), false)
+	}
+}
+
+// clearSIMLock is called once the modem talked to the network again.
+func (s *Service) clearSIMLock(slot *modemSlot) {
+	slot.mu.Lock()
+	slot.simLock = nil
+	slot.mu.Unlock()
+}
+
+// lockedSlot returns the first modem whose SIM is locked, or nil.
+func (s *Service) lockedSlot() *modemSlot {
+	for _, slot := range s.slotList() {
+		slot.mu.Lock()
+		locked := slot.simLock != nil
+		slot.mu.Unlock()
+		if locked {
+			return slot
+		}
+	}
+	return nil
+}
+
+// SIMLock returns the first SIM lock seen in the pool, or nil when every
+// SIM is usable.
+func (s *Service) SIMLock() *serial.SIMLockError {
+	slot := s.lockedSlot()
+	if slot == nil {
+		return nil
+	}
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	return slot.simLock
+}
+
+// UnlockSIM enters a PIN (or a PUK and new PIN) entered by the operator on
+// the modem on port. An empty port picks the first locked modem, else the
+// preferred one.
+func (s *Service) UnlockSIM(port string, code string, newPIN string) error {
+	code = strings.TrimSpace(code)
+	newPIN = strings.TrimSpace(newPIN)
+	if code == "" {
+		return fmt.Errorf("no code entered")
+	}
+	slot := s.slotFor(port)
+	if port == "" {
+		if locked := s.lockedSlot(); locked != nil {
+			slot = locked
+		}
+	}
+	if slot == nil {
+		return fmt.Errorf("no modem configured")
+	}
+
+	if err := slot.modem.UnlockSIM(code, newPIN); err != nil {
+		s.log(fmt.Sprintf("SIM unlock on %s failed: %v", slot.port, err), false)
+		if st, stErr := slot.modem.SIMStatus(); stErr == nil && st.State != serial.SIMReady {
+			s.noteModemError(slot, &serial.SIMLockError{State: st.State, Attempts: st.Attempts, Reason: "unlock failed"})
+		}
+		return err
+	}
+
+	s.clearSIMLock(slot)
+	s.signalInbox()
+	s.pollNetwork(slot)
+	return nil
+}
@@ -0,0 +1,76 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SMSC sources reported with the active service centre.
+const (
+	smscFromConfig = "config"
+	smscFromSIM    = "sim"
+)
+
+// refreshSMSC reads the SIM's service centre once per modem. It runs from
+// the network poll so it only touches a modem that answers.
+func (s *Service) refreshSMSC(slot *modemSlot) {
+	slot.mu.Lock()
+	known := slot.smscRead
+	slot.mu.Unlock()
+	if known {
+		return
+	}
+
+	addr, err := slot.modem.QuerySMSC()
+	if err != nil {
+		s.log(fmt.Sprintf("SMSC: %s: %v", slot.port, err), true)
+		return
+	}
+	slot.mu.Lock()
+	slot.simSMSC, slot.smscRead = addr, true
+	slot.mu.Unlock()
+
+	switch {
+	case s.Settings.SMSC != "":
+		s.log(fmt.Sprintf("SMSC: %s: using %s from settings (SIM has %q)", slot.port, s.Settings.SMSC, addr), false)
+	case addr == "":
+		s.log(fmt.Sprintf("SMSC: %s: the SIM has no service centre address, sends will fail until one is set", slot.port), false)
+	default:
+		s.log(fmt.Sprintf("SMSC: %s: %s (from SIM)", slot.port, addr), true)
+	}
+}
+
+// SMSC returns the service centre used for sending and where it comes
+// from: the sms.smsc override, else the address stored on the preferred
+// modem's SIM.
+func (s *Service) SMSC() (address string, source string) {
+	if s.Settings.SMSC != "" {
+		return s.Settings.SMSC, smscFromConfig
+	}
+	slot := s.slotFor("")
+	if slot == nil {
+		return "", smscFromSIM
+	}
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	return slot.simSMSC, smscFromSIM
+}
+
+// SetSMSC writes a new service centre address to the SIM of the modem on
+// port, or of the preferred modem when port is "".
+func (s *Service) SetSMSC(port string, number string) error {
+	number = strings.TrimSpace(number)
+	slot := s.slotFor(port)
+	if slot == nil {
+		return fmt.Errorf("no modem configured")
+	}
+	if err := slot.modem.SetSMSC(number); err != nil {
+		s.log(fmt.Sprintf("SMSC: %s: %v", slot.port, err), false)
+		return err
+	}
+	slot.mu.Lock()
+	slot.smscRead = false
+	slot.mu.Unlock()
+	s.refreshSMSC(slot)
+	return nil
+}
@@ -0,0 +1,189 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"smallNfast/internal/config"
+	"smallNfast/internal/serial"
+)
+
+const (
+	// storageStartDelay lets the modems come up before the first check.
+	storageStartDelay = time.Minute
+	// storageCheckInterval is how often storage usage is read and the
+	// cleanup policy applied.
+	storageCheckInterval = 15 * time.Minute
+)
+
+// StoragePolicy returns the message storage policy in effect.
+func (s *Service) StoragePolicy() config.StoragePolicy {
+	s.storageMu.Lock()
+	defer s.storageMu.Unlock()
+	return s.Settings.Storage
+}
+
+// SetStoragePolicy replaces the storage policy. A changed memory is
+// selected on every modem right away; the cleanup rules apply from the
+// next check.
+func (s *Service) SetStoragePolicy(p config.StoragePolicy) error {
+	p.Memory = strings.ToUpper(strings.TrimSpace(p.Memory))
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	s.storageMu.Lock()
+	changed := p.Memory != s.Settings.Storage.Memory
+	s.Settings.Storage = p
+	s.storageMu.Unlock()
+
+	if !changed || p.Memory == "" {
+		return nil
+	}
+	var lastErr error
+	for _, slot := range s.slotList() {
+		if err := slot.modem.SetStorage(p.Memory); err != nil {
+			s.log(fmt.Sprintf("Storage: %s: %v", slot.port, err), false)
+			lastErr = err
+			continue
+		}
+		s.log(fmt.Sprintf("Storage: %s: using %s", slot.port, p.Memory), false)
+		s.refreshStorage(slot)
+	}
+	return lastErr
+}
+
+func (s *Service) storageLoop() {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(storageStartDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-timer.C:
+			for _, slot := range s.slotList() {
+				// Like the inbox sweep, leave a missing modem closed
+				if slot.modem.IsConnected() {
+					s.cleanStorage(slot)
+				}
+			}
+			timer.Reset(storageCheckInterval)
+		}
+	}
+}
+
+// CleanStorage applies the cleanup policy to every modem now. It returns
+// the last error, if any.
+func (s *Service) CleanStorage() error {
+	if !s.HasModem() {
+		return fmt.Errorf("no modem configured")
+	}
+	var lastErr error
+	for _, slot := range s.slotList() {
+		if err := s.cleanStorage(slot); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// refreshStorage reads the slot's storage usage for the status view.
+func (s *Service) refreshStorage(slot *modemSlot) (*serial.StorageStatus, error) {
+	st, err := slot.modem.QueryStorage()
+	if err != nil {
+		s.log(fmt.Sprintf("Storage: %s: %v", slot.port, err), true)
+		return nil, err
+	}
+	slot.mu.Lock()
+	slot.storage = st
+	slot.mu.Unlock()
+	return st, nil
+}
+
+// cleanStorage deletes read messages and sent copies that are older than
+// storage.max_age_days, then the oldest of them while the storage is
+// fuller than storage.max_percent. Unread and unsent messages are kept,
+// and so are received parts the inbox may still be assembling.
+func (s *Service) cleanStorage(slot *modemSlot) error {
+	st, err := s.refreshStorage(slot)
+	if err != nil {
+		return err
+	}
+	policy := s.StoragePolicy()
+	usage := st.Read
+	full := policy.MaxPercent > 0 && usage.Percent() > policy.MaxPercent
+	if full {
+		s.log(fmt.Sprintf("WARNING: message storage on %s is %d%% full (%s)", slot.port, usage.Percent(), usage), false)
+	}
+	if usage.Used == 0 || (policy.MaxAgeDays == 0 && !full) {
+		return nil
+	}
+
+	stored, err := slot.modem.ListStored()
+	if err != nil {
+		s.log(fmt.Sprintf("Storage: %s: %v", slot.port, err), false)
+		return err
+	}
+	now := time.Now()
+	candidates := deletable(stored, now)
+
+	var doomed []serial.StoredMessage
+	if policy.MaxAgeDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.MaxAgeDays)
+		for len(candidates) > 0 && candidates[0].Timestamp.Before(cutoff) {
+			doomed, candidates = append(doomed, candidates[0]), candidates[1:]
+		}
+	}
+	if policy.MaxPercent > 0 && usage.Total > 0 {
+		limit := usage.Total * policy.MaxPercent / 100
+		for used := usage.Used - len(doomed); used > limit && len(candidates) > 0; used-- {
+			doomed, candidates = append(doomed, candidates[0]), candidates[1:]
+		}
+	}
+	if len(doomed) == 0 {
+		return nil
+	}
+
+	deleted := 0
+	for _, msg := range doomed {
+		if err := slot.modem.DeleteMessage(msg.Index); err != nil {
+			s.log(fmt.Sprintf("Storage: %s: %v", slot.port, err), false)
+			continue
+		}
+		deleted++
+	}
+	s.log(fmt.Sprintf("Storage: %s: deleted %d old message(s) from %s", slot.port, deleted, usage.Name), false)
+	s.refreshStorage(slot)
+	if deleted < len(doomed) {
+		return fmt.Errorf("%d of %d message(s) could not be deleted", len(doomed)-deleted, len(doomed))
+	}
+	return nil
+}
+
+// deletable returns the stored messages the cleanup may remove, oldest
+// first. Messages without a time stamp (sent copies) count as oldest.
+func deletable(stored []serial.StoredMessage, now time.Time) []serial.StoredMessage {
+	var list []serial.StoredMessage
+	for _, msg := range stored {
+		switch msg.Status {
+		case serial.StatSent:
+		case serial.StatRead:
+			// Listing marks new messages read; a recent part may still be
+			// waiting for the rest of its message.
+			if msg.Type == serial.PDUDeliver && now.Sub(msg.Timestamp) < 2*partialTimeout {
+				continue
+			}
+		default:
+			continue
+		}
+		list = append(list, msg)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Timestamp.Before(list[j].Timestamp)
+	})
+	return list
+}
@@ -0,0 +1,53 @@
+package monitor
+
+import (
+	"time"
+
+	"smallNfast/internal/db"
+)
+
+// Store is the persistence the monitor relies on. dbStore writes to the
+// S4M database; tests can supply an in-memory implementation.
+type Store interface {
+	// MaxAlarmDate is the newest alarm's createddate, where monitoring
+	// starts; FetchAlarmDetails returns the SMS alarms created after since.
+	MaxAlarmDate() (time.Time, error)
+	FetchAlarmDetails(since time.Time) ([]db.AlarmDetailDTO, error)
+	FetchActiveRecipients() ([]string, error)
+	AddInboxMessage(msg db.InboxModel) error
+	AddDeliveries(rows []db.DeliveryModel) error
+	ApplyStatusReport(port string, recipient string, ref int, status string, code int, at time.Time) (*db.DeliveryModel, error)
+	// Now reads the database clock, which stamps alarm createddate.
+	Now() (time.Time, error)
+}
+
+// dbStore is the Store backed by the package-level db connection.
+type dbStore struct{}
+
+func (dbStore) MaxAlarmDate() (time.Time, error) {
+	return db.GetMaxCreatedDate()
+}
+
+func (dbStore) FetchAlarmDetails(since time.Time) ([]db.AlarmDetailDTO, error) {
+	return db.FetchAlarmDetails(since)
+}
+
+func (dbStore) FetchActiveRecipients() ([]string, error) {
+	return db.FetchActiveRecipients()
+}
+
+func (dbStore) AddInboxMessage(msg db.InboxModel) error {
+	return db.AddInboxMessage(msg)
+}
+
+func (dbStore) AddDeliveries(rows []db.DeliveryModel) error {
+	return db.AddDeliveries(rows)
+}
+
+func (dbStore) ApplyStatusReport(port string, recipient string, ref int, status string, code int, at time.Time) (*db.DeliveryModel, error) {
+	return db.ApplyStatusReport(port, recipient, ref, status, code, at)
+}
+
+func (dbStore) Now() (time.Time, error) {
+	return db.Now()
+}
@@ -0,0 +1,168 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"smallNfast/internal/serial"
+)
+
+const (
+	// resetSettle gives a rebooting modem time to drop off the USB bus
+	// before its port is looked for again.
+	resetSettle = 5 * time.Second
+	// reenumTimeout bounds the wait for the modem's port to come back.
+	reenumTimeout = 90 * time.Second
+	// reenumPoll is how often the ports are listed while waiting.
+	reenumPoll = 2 * time.Second
+)
+
+// WatchdogStats counts the watchdog's checks and recoveries of a modem.
+type WatchdogStats struct {
+	Pings        int
+	PingFailures int // failed pings in total
+	Consecutive  int // failed pings since the last answer
+	Resets       int // AT+CFUN=1,1 issued
+	Rediscovered int // came back under another port
+	Recoveries   int // answered again after a reset
+	LastReset    time.Time
+}
+
+func (s *Service) watchdogLoop() {
+	defer s.wg.Done()
+
+	interval := s.Settings.WatchdogInterval
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			for _, slot := range s.slotList() {
+				s.watchdog(slot)
+			}
+		}
+	}
+}
+
+// watchdog pings one modem and recovers it once it failed
+// watchdog.failures pings in a row.
+func (s *Service) watchdog(slot *modemSlot) {
+	err := slot.modem.Ping()
+	slot.mu.Lock()
+	slot.watchdog.Pings++
+	if err == nil {
+		slot.watchdog.Consecutive = 0
+	} else {
+		slot.watchdog.PingFailures++
+		slot.watchdog.Consecutive++
+	}
+	failures := slot.watchdog.Consecutive
+	slot.mu.Unlock()
+
+	if err == nil {
+		s.log(fmt.Sprintf("Watchdog: %s: OK", slot.port), true)
+		return
+	}
+	slot.noteHealth(err)
+	s.log(fmt.Sprintf("Watchdog: %s: no answer (%d/%d): %v", slot.port, failures, s.Settings.WatchdogFailures, err), false)
+	if failures%s.Settings.WatchdogFailures == 0 {
+		s.recoverModem(slot)
+	}
+}
+
+// recoverModem resets a dead modem, waits for its USB device to come back
+// and reconnects, following it to a new port if it re-enumerated under
+// another name.
+func (s *Service) recoverModem(slot *modemSlot) {
+	s.log(fmt.Sprintf("Watchdog: %s: resetting modem", slot.port), false)
+	slot.mu.Lock()
+	slot.watchdog.Resets++
+	slot.watchdog.LastReset = time.Now()
+	slot.mu.Unlock()
+	if err := slot.modem.Reset(); err != nil {
+		// A wedged modem may not take the command; it can still have been
+		// replugged, so look for it anyway
+		s.log(fmt.Sprintf("Watchdog: %s: %v", slot.port, err), false)
+	}
+
+	port, ok := s.awaitModemPort(slot)
+	if !ok {
+		return
+	}
+	if port != slot.port {
+		s.log(fmt.Sprintf("Watchdog: %s re-enumerated as %s", slot.port, port), false)
+		if slot = s.replaceSlot(slot, port); slot == nil {
+			return // removed from the pool meanwhile
+		}
+		slot.mu.Lock()
+		slot.watchdog.Rediscovered++
+		slot.mu.Unlock()
+	}
+
+	if err := slot.modem.Ping(); err != nil {
+		slot.noteHealth(err)
+		s.log(fmt.Sprintf("Watchdog: %s: still not answering after reset: %v", slot.port, err), false)
+		return
+	}
+	slot.noteHealth(nil)
+	slot.mu.Lock()
+	slot.watchdog.Consecutive = 0
+	slot.watchdog.Recoveries++
+	slot.mu.Unlock()
+	s.log(fmt.Sprintf("Watchdog: %s: modem recovered", slot.port), false)
+	s.signalInbox()
+}
+
+// awaitModemPort waits for the slot's modem to show up again and returns
+// its port: the old one if it is back, else a new matching port that no
+// other modem of the pool uses. A modem behind a device server keeps its
+// endpoint, which the port scan does not list, so that one is retried
+// until it answers.
+func (s *Service) awaitModemPort(slot *modemSlot) (string, bool) {
+	network := serial.IsNetworkPort(slot.port)
+	var profiles []*serial.Profile
+	if slot.profile != nil {
+		profiles = append(profiles, slot.profile)
+	}
+	wait := resetSettle
+	for deadline := time.Now().Add(reenumTimeout); ; {
+		select {
+		case <-s.stopChan:
+			return "", false
+		case <-time.After(wait):
+		}
+		wait = reenumPoll
+
+		if network {
+			if slot.modem.Ping() == nil {
+				return slot.port, true
+			}
+		} else if found, err := s.FindModems(profiles...); err == nil {
+			inUse := make(map[string]bool)
+			for _, p := range s.Ports() {
+				inUse[p] = p != slot.port
+			}
+			var fresh string
+			for _, m := range found {
+				if m.Port.Path == slot.port {
+					return slot.port, true
+				}
+				if !inUse[m.Port.Path] && fresh == "" {
+					fresh = m.Port.Path
+				}
+			}
+			if fresh != "" {
+				return fresh, true
+			}
+		}
+		if time.Now().After(deadline) {
+			s.log(fmt.Sprintf("Watchdog: %s: modem did not come back within %v", slot.port, reenumTimeout), false)
+			return "", false
+		}
+	}
+}
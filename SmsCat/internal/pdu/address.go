@@ -0,0 +1,155 @@
+package pdu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type-of-address octets.
+const (
+	TOAUnknown       = 0x81
+	TOAInternational = 0x91
+	TOAAlphanumeric  = 0xD0
+)
+
+// NormalizeNumber strips formatting (spaces, dashes, parentheses) from a
+// phone number and returns its digits and whether it is international
+// (written with a leading +).
+func NormalizeNumber(number string) (digits string, international bool, err error) {
+	number = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(number)
+	if strings.HasPrefix(number, "+") {
+		number = number[1:]
+		international = true
+	}
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return "", false, fmt.Errorf("invalid characters in phone number: %s", number)
+		}
+	}
+	return number, international, nil
+}
+
+// isAlphanumeric reports whether addr is a sender name rather than a
+// number: anything with a letter in it.
+func isAlphanumeric(addr string) bool {
+	for _, r := range addr {
+		if (r < '0' || r > '9') && !strings.ContainsRune("+ -()*#", r) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeAddress encodes a TP address field. Numbers are stored as
+// semi-octets, international when they start with +; with alpha, names
+// such as "Bank" as packed GSM 7-bit (at most 11 characters).
+func encodeAddress(addr string, alpha bool) ([]byte, error) {
+	if alpha && isAlphanumeric(addr) {
+		septets, ok := EncodeGSM7(addr)
+		if !ok || len(septets) > 11 {
+			return nil, fmt.Errorf("invalid alphanumeric address %q", addr)
+		}
+		packed := PackSeptets(septets, 0)
+		// The length counts the semi-octets actually used
+		return append([]byte{byte((len(septets)*7 + 3) / 4), TOAAlphanumeric}, packed...), nil
+	}
+	digits, international, err := NormalizeNumber(addr)
+	if err != nil {
+		return nil, err
+	}
+	toa := byte(TOAUnknown)
+	if international {
+		toa = TOAInternational
+	}
+	return append([]byte{byte(len(digits)), toa}, semiOctets(digits)...), nil
+}
+
+// encodeSMSC encodes the SMSC information. An empty number yields a zero
+// length, which makes the modem use the SMSC stored on the SIM.
+func encodeSMSC(number string) ([]byte, error) {
+	if strings.TrimSpace(number) == "" {
+		return []byte{0}, nil
+	}
+	digits, international, err := NormalizeNumber(number)
+	if err != nil {
+		return nil, fmt.Errorf("SMSC: %w", err)
+	}
+	toa := byte(TOAUnknown)
+	if international {
+		toa = TOAInternational
+	}
+	b := semiOctets(digits)
+	// The length counts octets, including the type-of-address octet
+	return append([]byte{byte(1 + len(b)), toa}, b...), nil
+}
+
+// decodeAddress decodes a TP address of digits semi-octets.
+func decodeAddress(digits int, toa byte, raw []byte) string {
+	if toa&0x70 == 0x50 {
+		// Alphanumeric: the semi-octets hold packed GSM 7-bit
+		return DecodeGSM7(UnpackSeptets(raw, digits*4/7))
+	}
+	number := decodeSemiOctets(raw, digits)
+	if toa&0x70 == 0x10 {
+		number = "+" + number
+	}
+	return number
+}
+
+// decodeSMSC decodes the SMSC information, whose length counts octets.
+func decodeSMSC(b []byte) string {
+	if len(b) < 2 {
+		return ""
+	}
+	return decodeAddress((len(b)-1)*2, b[0], b[1:])
+}
+
+// semiOctets encodes digits as nibble-swapped BCD, padding odd lengths
+// with F.
+func semiOctets(digits string) []byte {
+	if len(digits)%2 != 0 {
+		digits += "F"
+	}
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		out = append(out, nibble(digits[i+1])<<4|nibble(digits[i]))
+	}
+	return out
+}
+
+func nibble(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c == '*':
+		return 0x0A
+	case c == '#':
+		return 0x0B
+	case c >= 'a' && c <= 'c':
+		return c - 'a' + 0x0C
+	}
+	return 0x0F
+}
+
+// decodeSemiOctets reverses the nibble-swapped BCD used for numbers.
+func decodeSemiOctets(b []byte, digits int) string {
+	var sb strings.Builder
+	for _, o := range b {
+		for _, n := range []byte{o & 0x0F, o >> 4} {
+			if sb.Len() >= digits || n == 0x0F {
+				break
+			}
+			switch {
+			case n <= 9:
+				sb.WriteByte('0' + n)
+			case n == 0x0A:
+				sb.WriteByte('*')
+			case n == 0x0B:
+				sb.WriteByte('#')
+			default:
+				sb.WriteByte('a' + n - 0x0C)
+			}
+		}
+	}
+	return sb.String()
+}
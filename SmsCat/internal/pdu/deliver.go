@@ -0,0 +1,100 @@
+package pdu
+
+import (
+	"fmt"
+	"time"
+)
+
+// Deliver is an SMS-DELIVER: a message received from the SMSC.
+type Deliver struct {
+	SMSC                   string
+	MoreMessages           bool // the SMSC has more messages waiting
+	ReplyPath              bool
+	StatusReportIndication bool
+	Sender                 string // number, or a name for alphanumeric senders
+	PID                    byte
+	Timestamp              time.Time // service centre time stamp
+	UserData
+}
+
+// Encode returns the hex PDU as a modem would list it. Alphanumeric
+// senders are encoded as such.
+func (d *Deliver) Encode() (string, error) {
+	smsc, err := encodeSMSC(d.SMSC)
+	if err != nil {
+		return "", err
+	}
+	addr, err := encodeAddress(d.Sender, true)
+	if err != nil {
+		return "", err
+	}
+	udl, ud, udhi, err := d.UserData.encode()
+	if err != nil {
+		return "", err
+	}
+
+	first := byte(0x00)
+	if !d.MoreMessages {
+		first |= 0x04
+	}
+	if d.StatusReportIndication {
+		first |= 0x20
+	}
+	if udhi {
+		first |= 0x40
+	}
+	if d.ReplyPath {
+		first |= 0x80
+	}
+
+	tpdu := append([]byte{first}, addr...)
+	tpdu = append(tpdu, d.PID, d.DCS)
+	tpdu = append(tpdu, encodeTimestamp(d.Timestamp)...)
+	tpdu = append(tpdu, udl)
+	tpdu = append(tpdu, ud...)
+	pduHex, _ := encoded{smsc: smsc, tpdu: tpdu}.hex()
+	return pduHex, nil
+}
+
+// DecodeDeliver parses a hex SMS-DELIVER as listed by AT+CMGL/CMGR,
+// including the leading SMSC information.
+func DecodeDeliver(pduHex string) (*Deliver, error) {
+	r, err := newReader(pduHex)
+	if err != nil {
+		return nil, err
+	}
+	d := &Deliver{}
+	if d.SMSC, err = r.smsc(); err != nil {
+		return nil, err
+	}
+	first, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if first&0x03 != 0x00 {
+		return nil, fmt.Errorf("not an SMS-DELIVER PDU (first octet %02X)", first)
+	}
+	d.MoreMessages = first&0x04 == 0
+	d.StatusReportIndication = first&0x20 != 0
+	d.ReplyPath = first&0x80 != 0
+
+	if d.Sender, err = r.address(); err != nil {
+		return nil, err
+	}
+	if d.PID, err = r.byte(); err != nil {
+		return nil, err
+	}
+	if d.DCS, err = r.byte(); err != nil {
+		return nil, err
+	}
+	scts, err := r.bytes(7)
+	if err != nil {
+		return nil, err
+	}
+	d.Timestamp = decodeTimestamp(scts)
+	udl, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	return d, d.UserData.decode(first&0x40 != 0, int(udl), r.rest())
+}
@@ -0,0 +1,126 @@
+package pdu
+
+import "strings"
+
+// gsm7Escape switches to the extension table for the following septet.
+const gsm7Escape = 0x1B
+
+// gsm7Basic is the GSM 03.38 default alphabet, indexed by septet value.
+var gsm7Basic = [128]rune{
+	'@', '£', '$', '¥', 'è', 'é', 'ù', 'ì', 'ò', 'Ç', '\n', 'Ø', 'ø', '\r', 'Å', 'å',
+	'Δ', '_', 'Φ', 'Γ', 'Λ', 'Ω', 'Π', 'Ψ', 'Σ', 'Θ', 'Ξ', 0x1B, 'Æ', 'æ', 'ß', 'É',
+	' ', '!', '"', '#', '¤', '%', '&', '\'', '(', ')', '*', '+', ',', '-', '.', '/',
+	'0', '1', '2', '3', '4', '5', '6', '7', '8', '9', ':', ';', '<', '=', '>', '?',
+	'¡', 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O',
+	'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z', 'Ä', 'Ö', 'Ñ', 'Ü', '§',
+	'¿', 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm', 'n', 'o',
+	'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z', 'ä', 'ö', 'ñ', 'ü', 'à',
+}
+
+// gsm7Extension maps septets following an escape to their characters.
+var gsm7Extension = map[byte]rune{
+	0x0A: '\f',
+	0x14: '^',
+	0x28: '{',
+	0x29: '}',
+	0x2F: '\\',
+	0x3C: '[',
+	0x3D: '~',
+	0x3E: ']',
+	0x40: '|',
+	0x65: '€',
+}
+
+// UnpackSeptets extracts count 7-bit septets from packed octets.
+func UnpackSeptets(data []byte, count int) []byte {
+	septets := make([]byte, 0, count)
+	for i := 0; i < count; i++ {
+		bit := i * 7
+		idx := bit / 8
+		shift := uint(bit % 8)
+		if idx >= len(data) {
+			break
+		}
+		v := uint16(data[idx]) >> shift
+		if shift > 1 && idx+1 < len(data) {
+			v |= uint16(data[idx+1]) << (8 - shift)
+		}
+		septets = append(septets, byte(v&0x7F))
+	}
+	return septets
+}
+
+// DecodeGSM7 converts septets in the default alphabet to a string. Only
+// the low 7 bits of each byte are used. An escape with nothing to extend,
+// at the end or before another escape, reads as a space (3GPP 23.038).
+func DecodeGSM7(septets []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(septets); i++ {
+		c := septets[i] & 0x7F
+		if c != gsm7Escape {
+			sb.WriteRune(gsm7Basic[c])
+			continue
+		}
+		if i+1 == len(septets) {
+			sb.WriteByte(' ')
+			break
+		}
+		i++
+		c = septets[i] & 0x7F
+		if r, ok := gsm7Extension[c]; ok {
+			sb.WriteRune(r)
+		} else if c == gsm7Escape {
+			sb.WriteByte(' ')
+		} else {
+			// Unknown extension: fall back to the basic table per 03.38
+			sb.WriteRune(gsm7Basic[c])
+		}
+	}
+	return sb.String()
+}
+
+// gsm7Reverse maps characters to their septets; extension characters map
+// to the escape followed by their extension septet.
+var gsm7Reverse = func() map[rune][]byte {
+	m := make(map[rune][]byte, len(gsm7Basic)+len(gsm7Extension))
+	for i, r := range gsm7Basic {
+		if i == gsm7Escape {
+			continue
+		}
+		m[r] = []byte{byte(i)}
+	}
+	for b, r := range gsm7Extension {
+		m[r] = []byte{gsm7Escape, b}
+	}
+	return m
+}()
+
+// EncodeGSM7 converts text to default-alphabet septets. ok is false when
+// any character has no GSM 03.38 representation.
+func EncodeGSM7(text string) (septets []byte, ok bool) {
+	for _, r := range text {
+		s, found := gsm7Reverse[r]
+		if !found {
+			return nil, false
+		}
+		septets = append(septets, s...)
+	}
+	return septets, true
+}
+
+// PackSeptets packs septets into octets, leaving fillBits zero bits in
+// front so the text starts on a septet boundary after a UDH.
+func PackSeptets(septets []byte, fillBits int) []byte {
+	totalBits := fillBits + len(septets)*7
+	out := make([]byte, (totalBits+7)/8)
+	for i, s := range septets {
+		bit := fillBits + i*7
+		idx := bit / 8
+		shift := uint(bit % 8)
+		out[idx] |= (s & 0x7F) << shift
+		if shift > 1 {
+			out[idx+1] |= (s & 0x7F) >> (8 - shift)
+		}
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package pdu
+
+import "testing"
+
+func TestDecodeGSM7(t *testing.T) {
+	tests := []struct {
+		name    string
+		septets []byte
+		want    string
+	}{
+		{"basic", []byte{0x48, 0x69, 0x20, 0x40}, "Hi ¡"},
+		{"extension", []byte{0x1B, 0x65, 0x31, 0x1B, 0x28, 0x1B, 0x29}, "€1{}"},
+		{"unknown extension", []byte{0x1B, 0x41}, "A"},
+		{"trailing escape", []byte{0x41, 0x1B}, "A "},
+		{"trailing escape high bit", []byte{0x41, 0x9B}, "A "},
+		{"escape before escape", []byte{0x1B, 0x1B, 0x41}, " A"},
+		{"high bit ignored", []byte{0xC1, 0x9B, 0xE5}, "A€"},
+		{"high bit after escape", []byte{0x1B, 0xFF}, "à"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DecodeGSM7(tt.septets); got != tt.want {
+				t.Errorf("DecodeGSM7(% X) = %q, want %q", tt.septets, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGSM7RoundTrip(t *testing.T) {
+	for _, text := range []string{"", "@£$¥", "Temp 23.8 C", "[a]{b}~|^€\\", "1234567"} {
+		septets, ok := EncodeGSM7(text)
+		if !ok {
+			t.Fatalf("EncodeGSM7(%q) not ok", text)
+		}
+		got := DecodeGSM7(UnpackSeptets(PackSeptets(septets, 0), len(septets)))
+		if got != text {
+			t.Errorf("round trip of %q = %q", text, got)
+		}
+	}
+	if _, ok := EncodeGSM7("23.8°C"); ok {
+		t.Error(`EncodeGSM7("23.8°C") ok, want the degree sign refused`)
+	}
+}
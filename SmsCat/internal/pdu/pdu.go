@@ -0,0 +1,151 @@
+// Package pdu encodes and decodes SMS transfer layer PDUs (3GPP TS 23.040)
+// in the hex form modems use in PDU mode: SMS-SUBMIT, SMS-DELIVER and
+// SMS-STATUS-REPORT, each preceded by the SMSC information.
+package pdu
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Message types, from TP-MTI in the first octet.
+const (
+	TypeDeliver      = "SMS-DELIVER"
+	TypeSubmit       = "SMS-SUBMIT"
+	TypeStatusReport = "SMS-STATUS-REPORT"
+)
+
+// Type returns the message type of a hex PDU including the SMSC
+// information, as far as the first octet tells.
+func Type(pduHex string) (string, error) {
+	r, err := newReader(pduHex)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.smsc(); err != nil {
+		return "", err
+	}
+	first, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+	switch first & 0x03 {
+	case 0x00:
+		return TypeDeliver, nil
+	case 0x01:
+		return TypeSubmit, nil
+	case 0x02:
+		return TypeStatusReport, nil
+	}
+	return "", fmt.Errorf("reserved message type (first octet %02X)", first)
+}
+
+// Decode parses a hex PDU of any supported type. The result is a
+// *Deliver, *Submit or *StatusReport.
+func Decode(pduHex string) (interface{}, error) {
+	t, err := Type(pduHex)
+	if err != nil {
+		return nil, err
+	}
+	// A failed decode yields a nil interface rather than a typed nil
+	var msg interface{}
+	switch t {
+	case TypeDeliver:
+		var d *Deliver
+		if d, err = DecodeDeliver(pduHex); d != nil {
+			msg = d
+		}
+	case TypeSubmit:
+		var s *Submit
+		if s, err = DecodeSubmit(pduHex); s != nil {
+			msg = s
+		}
+	default:
+		var r *StatusReport
+		if r, err = DecodeStatusReport(pduHex); r != nil {
+			msg = r
+		}
+	}
+	return msg, err
+}
+
+// reader walks a PDU octet by octet.
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func newReader(pduHex string) (*reader, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(pduHex))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PDU hex: %w", err)
+	}
+	return &reader{data: data}, nil
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, fmt.Errorf("PDU truncated at octet %d", r.pos)
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, fmt.Errorf("PDU truncated at octet %d (need %d)", r.pos, n)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// rest returns the octets not read yet.
+func (r *reader) rest() []byte {
+	return r.data[r.pos:]
+}
+
+// smsc reads the SMSC information that precedes the TPDU.
+func (r *reader) smsc() (string, error) {
+	n, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(int(n))
+	if err != nil {
+		return "", err
+	}
+	return decodeSMSC(b), nil
+}
+
+// address reads a TP address field (originating, destination or
+// recipient address).
+func (r *reader) address() (string, error) {
+	digits, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+	toa, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+	raw, err := r.bytes((int(digits) + 1) / 2)
+	if err != nil {
+		return "", err
+	}
+	return decodeAddress(int(digits), toa, raw), nil
+}
+
+// encoded is the TPDU being built, with the SMSC information kept apart
+// because AT+CMGS counts only the TPDU.
+type encoded struct {
+	smsc []byte
+	tpdu []byte
+}
+
+// hex returns the PDU as sent to the modem and the AT+CMGS length.
+func (e encoded) hex() (string, int) {
+	return strings.ToUpper(hex.EncodeToString(e.smsc) + hex.EncodeToString(e.tpdu)), len(e.tpdu)
+}
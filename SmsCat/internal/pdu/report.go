@@ -0,0 +1,129 @@
+package pdu
+
+import (
+	"fmt"
+	"time"
+)
+
+// Delivery states derived from TP-Status.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// StatusReport is an SMS-STATUS-REPORT: the SMSC's answer about a message
+// sent with TP-SRR.
+type StatusReport struct {
+	SMSC       string
+	MessageRef int
+	Recipient  string
+	SubmitTime time.Time // service centre time stamp of the original submit
+	Discharge  time.Time // time of delivery or of the last attempt
+	Status     int       // TP-Status
+	// PID and UserData are only present when the SMSC sends them
+	// (TP-Parameter-Indicator).
+	PID byte
+	UserData
+}
+
+// State maps TP-Status (3GPP TS 23.040 9.2.3.15) to delivered, pending or failed.
+func (r *StatusReport) State() string {
+	switch {
+	case r.Status <= 0x1F:
+		return DeliveryDelivered // short message transaction completed
+	case r.Status <= 0x3F:
+		return DeliveryPending // temporary error, SC still trying
+	default:
+		return DeliveryFailed // permanent error, or temporary error with SC no longer trying
+	}
+}
+
+// Encode returns the hex PDU as a +CDS URC would carry it. Only the
+// mandatory fields are encoded.
+func (r *StatusReport) Encode() (string, error) {
+	smsc, err := encodeSMSC(r.SMSC)
+	if err != nil {
+		return "", err
+	}
+	addr, err := encodeAddress(r.Recipient, false)
+	if err != nil {
+		return "", err
+	}
+	tpdu := append([]byte{0x06, byte(r.MessageRef)}, addr...)
+	tpdu = append(tpdu, encodeTimestamp(r.SubmitTime)...)
+	tpdu = append(tpdu, encodeTimestamp(r.Discharge)...)
+	tpdu = append(tpdu, byte(r.Status))
+	pduHex, _ := encoded{smsc: smsc, tpdu: tpdu}.hex()
+	return pduHex, nil
+}
+
+// DecodeStatusReport parses a hex SMS-STATUS-REPORT as delivered in a
+// +CDS URC, including the leading SMSC information.
+func DecodeStatusReport(pduHex string) (*StatusReport, error) {
+	r, err := newReader(pduHex)
+	if err != nil {
+		return nil, err
+	}
+	report := &StatusReport{}
+	if report.SMSC, err = r.smsc(); err != nil {
+		return nil, err
+	}
+	first, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if first&0x03 != 0x02 {
+		return nil, fmt.Errorf("not an SMS-STATUS-REPORT PDU (first octet %02X)", first)
+	}
+
+	mr, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	report.MessageRef = int(mr)
+	if report.Recipient, err = r.address(); err != nil {
+		return nil, err
+	}
+	scts, err := r.bytes(7)
+	if err != nil {
+		return nil, err
+	}
+	report.SubmitTime = decodeTimestamp(scts)
+	dt, err := r.bytes(7)
+	if err != nil {
+		return nil, err
+	}
+	report.Discharge = decodeTimestamp(dt)
+	st, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	report.Status = int(st)
+
+	// Optional parameters; a report cut short after the status is valid
+	pi, err := r.byte()
+	if err != nil || pi&0x80 != 0 {
+		return report, nil // extension octets are not supported
+	}
+	if pi&0x01 != 0 {
+		if report.PID, err = r.byte(); err != nil {
+			return report, nil
+		}
+	}
+	if pi&0x02 != 0 {
+		if report.DCS, err = r.byte(); err != nil {
+			return report, nil
+		}
+	}
+	if pi&0x04 != 0 {
+		udl, err := r.byte()
+		if err != nil {
+			return report, nil
+		}
+		if err := report.UserData.decode(first&0x40 != 0, int(udl), r.rest()); err != nil {
+			return report, nil
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,77 @@
+package pdu
+
+import "unicode/utf16"
+
+// Single-part capacities: 160 septets or 70 UCS2 units fit in one SMS.
+const (
+	maxUserData       = 140 // octets
+	gsm7SingleSeptets = 160
+	ucs2SingleUnits   = 70
+)
+
+// Concat configures the header SplitText puts on the parts of a long
+// message.
+type Concat struct {
+	// Ref returns the reference shared by the parts. It is called once,
+	// and only when the text needs more than one part; nil uses 0.
+	Ref func() int
+	// Wide uses the 16-bit reference element (IEI 08) instead of the
+	// 8-bit one (IEI 00), at the cost of one character per part.
+	Wide bool
+}
+
+// header returns the element for part seq of total.
+func (c Concat) header(ref, total, seq int) InformationElement {
+	if c.Wide {
+		return Concat16(ref, total, seq)
+	}
+	return Concat8(ref, total, seq)
+}
+
+// headerOctets is the size of the user data header, UDHL included.
+func (c Concat) headerOctets() int {
+	return len(UDH{c.header(0, 0, 0)}.encode())
+}
+
+// SplitText picks the alphabet for text and cuts it into the user data of
+// the parts needed to send it. GSM 7-bit is used when every character is
+// in the default alphabet or its extension table, UCS2 otherwise. Parts
+// are only cut between characters, so an escaped GSM character or a UTF-16
+// surrogate pair is never split across two parts.
+func SplitText(text string, c Concat) []UserData {
+	hdr := c.headerOctets()
+	dcs, single, part := byte(DCSUCS2), ucs2SingleUnits, (maxUserData-hdr)/2
+	size := func(r rune) int { return len(utf16.Encode([]rune{r})) }
+	if septets, ok := EncodeGSM7(text); ok {
+		// The header is padded to a whole number of septets
+		dcs, single, part = DCSGSM7, gsm7SingleSeptets, gsm7SingleSeptets-(hdr*8+6)/7
+		size = func(r rune) int { return len(gsm7Reverse[r]) }
+		if len(septets) <= single {
+			return []UserData{{DCS: dcs, Text: text}}
+		}
+	} else if len(utf16.Encode([]rune(text))) <= single {
+		return []UserData{{DCS: dcs, Text: text}}
+	}
+
+	var chunks []string
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		end, used := start, 0
+		for end < len(runes) && used+size(runes[end]) <= part {
+			used += size(runes[end])
+			end++
+		}
+		chunks = append(chunks, string(runes[start:end]))
+		start = end
+	}
+
+	ref := 0
+	if c.Ref != nil {
+		ref = c.Ref()
+	}
+	parts := make([]UserData, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = UserData{DCS: dcs, UDH: UDH{c.header(ref, len(chunks), i+1)}, Text: chunk}
+	}
+	return parts
+}
@@ -0,0 +1,144 @@
+package pdu
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitText(t *testing.T) {
+	a := func(n int) string { return strings.Repeat("a", n) }
+	zh := func(n int) string { return strings.Repeat("中", n) }
+
+	tests := []struct {
+		name  string
+		text  string
+		wide  bool
+		dcs   byte
+		parts []int // characters per part
+	}{
+		{"gsm7 160 fits", a(160), false, DCSGSM7, []int{160}},
+		{"gsm7 161 splits", a(161), false, DCSGSM7, []int{153, 8}},
+		{"gsm7 161 wide", a(161), true, DCSGSM7, []int{152, 9}},
+		{"gsm7 306 two full parts", a(306), false, DCSGSM7, []int{153, 153}},
+		{"gsm7 307 three parts", a(307), false, DCSGSM7, []int{153, 153, 1}},
+		{"gsm7 escape counts double", a(159) + "€", false, DCSGSM7, []int{153, 7}},
+		{"gsm7 escape fits single", a(158) + "€", false, DCSGSM7, []int{159}},
+		{"gsm7 escape not split", a(152) + "€" + a(10), false, DCSGSM7, []int{152, 11}},
+		{"gsm7 escape at part end", a(151) + "€" + a(10), false, DCSGSM7, []int{152, 10}},
+		{"ucs2 70 fits", zh(70), false, DCSUCS2, []int{70}},
+		{"ucs2 71 splits", zh(71), false, DCSUCS2, []int{67, 4}},
+		{"ucs2 71 wide", zh(71), true, DCSUCS2, []int{66, 5}},
+		{"ucs2 surrogate fits single", zh(68) + "😀", false, DCSUCS2, []int{69}},
+		{"ucs2 surrogate counts double", zh(69) + "😀", false, DCSUCS2, []int{67, 3}},
+		{"ucs2 surrogate not split", zh(66) + "😀" + zh(5), false, DCSUCS2, []int{66, 6}},
+		{"ucs2 surrogate at part end", zh(65) + "😀" + zh(5), false, DCSUCS2, []int{66, 5}},
+		{"one non-gsm char forces ucs2", a(70) + "中", false, DCSUCS2, []int{67, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			c := Concat{Wide: tt.wide, Ref: func() int { calls++; return 0x1234 }}
+			parts := SplitText(tt.text, c)
+
+			var got []int
+			var joined strings.Builder
+			for _, p := range parts {
+				got = append(got, utf8.RuneCountInString(p.Text))
+				joined.WriteString(p.Text)
+				if p.DCS != tt.dcs {
+					t.Errorf("DCS = %02X, want %02X", p.DCS, tt.dcs)
+				}
+			}
+			if !equalInts(got, tt.parts) {
+				t.Fatalf("part sizes = %v, want %v", got, tt.parts)
+			}
+			if joined.String() != tt.text {
+				t.Errorf("parts do not add up to the text")
+			}
+			wantCalls := 0
+			if len(parts) > 1 {
+				wantCalls = 1
+			}
+			if calls != wantCalls {
+				t.Errorf("Ref called %d times, want %d", calls, wantCalls)
+			}
+
+			// Every part must encode within 140 octets and decode back
+			for i, p := range parts {
+				s := &Submit{Recipient: "+8613800138000", UserData: p}
+				hex, _, err := s.Encode()
+				if err != nil {
+					t.Fatalf("part %d: Encode: %v", i+1, err)
+				}
+				back, err := DecodeSubmit(hex)
+				if err != nil {
+					t.Fatalf("part %d: DecodeSubmit: %v", i+1, err)
+				}
+				if back.Text != p.Text {
+					t.Errorf("part %d: decoded %q, want %q", i+1, back.Text, p.Text)
+				}
+				if len(parts) == 1 {
+					if back.UDH != nil {
+						t.Errorf("single part has a header %v", back.UDH)
+					}
+					continue
+				}
+				ref, total, seq, ok := back.UDH.Concat()
+				if !ok || total != len(parts) || seq != i+1 {
+					t.Errorf("part %d: concat = %d/%d ok=%v, want %d/%d", i+1, seq, total, ok, i+1, len(parts))
+				}
+				want := 0x34 // the 8-bit element keeps the low byte
+				if tt.wide {
+					want = 0x1234
+				}
+				if ref != want {
+					t.Errorf("part %d: ref = %#x, want %#x", i+1, ref, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitTextReferenceElement(t *testing.T) {
+	tests := []struct {
+		wide bool
+		id   byte
+		size int // element data octets
+	}{
+		{false, IEConcat8, 3},
+		{true, IEConcat16, 4},
+	}
+	for _, tt := range tests {
+		parts := SplitText(strings.Repeat("x", 200), Concat{Wide: tt.wide, Ref: func() int { return 300 }})
+		if len(parts) != 2 {
+			t.Fatalf("wide=%v: %d parts, want 2", tt.wide, len(parts))
+		}
+		for _, p := range parts {
+			if len(p.UDH) != 1 || p.UDH[0].ID != tt.id || len(p.UDH[0].Data) != tt.size {
+				t.Errorf("wide=%v: header %+v, want one element %02X of %d octets", tt.wide, p.UDH, tt.id, tt.size)
+			}
+		}
+	}
+}
+
+func TestSplitTextNilRef(t *testing.T) {
+	parts := SplitText(strings.Repeat("x", 200), Concat{})
+	for _, p := range parts {
+		if ref, _, _, _ := p.UDH.Concat(); ref != 0 {
+			t.Errorf("ref = %d, want 0 without Ref", ref)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
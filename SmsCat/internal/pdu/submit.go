@@ -0,0 +1,117 @@
+package pdu
+
+import "fmt"
+
+// Protocol identifiers (TP-PID). A message sent with one of the replace
+// types replaces the earlier one with the same type and sender on the
+// handset instead of being shown next to it.
+const (
+	PIDDefault      = 0x00
+	PIDReplaceType1 = 0x41
+	PIDReplaceType2 = 0x42
+	PIDReplaceType3 = 0x43
+	PIDReplaceType4 = 0x44
+	PIDReplaceType5 = 0x45
+	PIDReplaceType6 = 0x46
+	PIDReplaceType7 = 0x47
+)
+
+// Submit is an SMS-SUBMIT: a message sent to the SMSC.
+type Submit struct {
+	SMSC                string // empty uses the SMSC stored on the SIM
+	RejectDuplicates    bool
+	ReplyPath           bool
+	StatusReportRequest bool
+	MessageRef          byte // 0 lets the modem assign one
+	Recipient           string
+	PID                 byte
+	Validity            ValidityPeriod
+	UserData
+}
+
+// Encode returns the hex PDU and the TPDU length for AT+CMGS.
+func (s *Submit) Encode() (string, int, error) {
+	smsc, err := encodeSMSC(s.SMSC)
+	if err != nil {
+		return "", 0, err
+	}
+	addr, err := encodeAddress(s.Recipient, false)
+	if err != nil {
+		return "", 0, err
+	}
+	vp, err := s.Validity.encode()
+	if err != nil {
+		return "", 0, err
+	}
+	udl, ud, udhi, err := s.UserData.encode()
+	if err != nil {
+		return "", 0, err
+	}
+
+	first := byte(0x01) | s.Validity.Format<<3
+	if s.RejectDuplicates {
+		first |= 0x04
+	}
+	if s.StatusReportRequest {
+		first |= 0x20
+	}
+	if udhi {
+		first |= 0x40
+	}
+	if s.ReplyPath {
+		first |= 0x80
+	}
+
+	tpdu := []byte{first, s.MessageRef}
+	tpdu = append(tpdu, addr...)
+	tpdu = append(tpdu, s.PID, s.DCS)
+	tpdu = append(tpdu, vp...)
+	tpdu = append(tpdu, udl)
+	tpdu = append(tpdu, ud...)
+	pduHex, length := encoded{smsc: smsc, tpdu: tpdu}.hex()
+	return pduHex, length, nil
+}
+
+// DecodeSubmit parses a hex SMS-SUBMIT, such as one logged before sending
+// or read back from storage, including the leading SMSC information.
+func DecodeSubmit(pduHex string) (*Submit, error) {
+	r, err := newReader(pduHex)
+	if err != nil {
+		return nil, err
+	}
+	s := &Submit{}
+	if s.SMSC, err = r.smsc(); err != nil {
+		return nil, err
+	}
+	first, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if first&0x03 != 0x01 {
+		return nil, fmt.Errorf("not an SMS-SUBMIT PDU (first octet %02X)", first)
+	}
+	s.RejectDuplicates = first&0x04 != 0
+	s.StatusReportRequest = first&0x20 != 0
+	s.ReplyPath = first&0x80 != 0
+
+	if s.MessageRef, err = r.byte(); err != nil {
+		return nil, err
+	}
+	if s.Recipient, err = r.address(); err != nil {
+		return nil, err
+	}
+	if s.PID, err = r.byte(); err != nil {
+		return nil, err
+	}
+	if s.DCS, err = r.byte(); err != nil {
+		return nil, err
+	}
+	if s.Validity, err = readValidity(r, first>>3&0x03); err != nil {
+		return nil, err
+	}
+	udl, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	return s, s.UserData.decode(first&0x40 != 0, int(udl), r.rest())
+}
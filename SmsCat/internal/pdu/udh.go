@@ -0,0 +1,81 @@
+package pdu
+
+import "fmt"
+
+// Information element identifiers used by SMSCat.
+const (
+	IEConcat8  = 0x00 // concatenated message, 8-bit reference
+	IEConcat16 = 0x08 // concatenated message, 16-bit reference
+)
+
+// InformationElement is one element of a user data header.
+type InformationElement struct {
+	ID   byte
+	Data []byte
+}
+
+// UDH is a user data header: the information elements in order.
+type UDH []InformationElement
+
+// Concat8 returns the element for part seq of total with an 8-bit
+// reference.
+func Concat8(ref, total, seq int) InformationElement {
+	return InformationElement{ID: IEConcat8, Data: []byte{byte(ref), byte(total), byte(seq)}}
+}
+
+// Concat16 returns the element for part seq of total with a 16-bit
+// reference.
+func Concat16(ref, total, seq int) InformationElement {
+	return InformationElement{ID: IEConcat16, Data: []byte{byte(ref >> 8), byte(ref), byte(total), byte(seq)}}
+}
+
+// Concat returns the concatenation reference, part count and part number.
+// ok is false for a message that is not part of a concatenated one.
+func (h UDH) Concat() (ref, total, seq int, ok bool) {
+	for _, ie := range h {
+		switch {
+		case ie.ID == IEConcat8 && len(ie.Data) == 3:
+			return int(ie.Data[0]), int(ie.Data[1]), int(ie.Data[2]), true
+		case ie.ID == IEConcat16 && len(ie.Data) == 4:
+			return int(ie.Data[0])<<8 | int(ie.Data[1]), int(ie.Data[2]), int(ie.Data[3]), true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// encode returns the header with its UDHL octet, or nil when empty.
+func (h UDH) encode() []byte {
+	if len(h) == 0 {
+		return nil
+	}
+	out := []byte{0}
+	for _, ie := range h {
+		out = append(out, ie.ID, byte(len(ie.Data)))
+		out = append(out, ie.Data...)
+	}
+	out[0] = byte(len(out) - 1)
+	return out
+}
+
+// parseUDH reads the header at the start of ud and returns it with its
+// length in octets, UDHL included.
+func parseUDH(ud []byte) (UDH, int, error) {
+	if len(ud) == 0 {
+		return nil, 0, fmt.Errorf("UDHI set but user data is empty")
+	}
+	udhl := int(ud[0])
+	if udhl+1 > len(ud) {
+		return nil, 0, fmt.Errorf("UDH length %d exceeds user data", udhl)
+	}
+	h := UDH{}
+	ies := ud[1 : udhl+1]
+	for i := 0; i+1 < len(ies); {
+		iei, iel := ies[i], int(ies[i+1])
+		if i+2+iel > len(ies) {
+			return h, udhl + 1, fmt.Errorf("UDH element %02X truncated", iei)
+		}
+		h = append(h, InformationElement{ID: iei, Data: append([]byte(nil), ies[i+2:i+2+iel]...)})
+		i += 2 + iel
+	}
+	return h, udhl + 1, nil
+}
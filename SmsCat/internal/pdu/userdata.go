@@ -0,0 +1,171 @@
+package pdu
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Alphabets signalled by the data coding scheme.
+const (
+	AlphabetGSM7 = "gsm7"
+	Alphabet8Bit = "8bit"
+	AlphabetUCS2 = "ucs2"
+)
+
+// Data coding schemes for plain messages of each alphabet.
+const (
+	DCSGSM7 = 0x00
+	DCS8Bit = 0x04
+	DCSUCS2 = 0x08
+)
+
+// Alphabet returns the alphabet signalled by a TP-DCS octet.
+func Alphabet(dcs byte) (string, error) {
+	switch {
+	case dcs&0xC0 == 0x00, dcs&0xC0 == 0x40: // general data coding / auto-deletion
+		if dcs&0x20 != 0 {
+			return "", fmt.Errorf("compressed user data is not supported (DCS %02X)", dcs)
+		}
+		switch (dcs >> 2) & 0x03 {
+		case 0x01:
+			return Alphabet8Bit, nil
+		case 0x02:
+			return AlphabetUCS2, nil
+		default:
+			return AlphabetGSM7, nil
+		}
+	case dcs&0xF0 == 0xC0, dcs&0xF0 == 0xD0: // message waiting, GSM7
+		return AlphabetGSM7, nil
+	case dcs&0xF0 == 0xE0: // message waiting, UCS2
+		return AlphabetUCS2, nil
+	case dcs&0xF0 == 0xF0: // data coding / message class
+		if dcs&0x04 != 0 {
+			return Alphabet8Bit, nil
+		}
+		return AlphabetGSM7, nil
+	}
+	return "", fmt.Errorf("unsupported DCS %02X", dcs)
+}
+
+// MessageClass returns the class (0-3) a TP-DCS octet asks for, or -1.
+// Class 0 is a flash message shown without being stored.
+func MessageClass(dcs byte) int {
+	switch {
+	case dcs&0xC0 == 0x00 && dcs&0x10 != 0, dcs&0xF0 == 0xF0:
+		return int(dcs & 0x03)
+	}
+	return -1
+}
+
+// UserData is the message body of a PDU: its coding, optional header and
+// text.
+type UserData struct {
+	DCS byte
+	UDH UDH // nil when TP-UDHI is not set
+	// Text is the decoded body. 8-bit data that is not valid UTF-8 is
+	// shown as hex.
+	Text string
+	// Data holds the raw body of 8-bit messages; when set it is sent
+	// instead of Text.
+	Data []byte
+}
+
+// Alphabet returns the alphabet of the data coding scheme, GSM 7-bit when
+// the DCS is not understood.
+func (u *UserData) Alphabet() string {
+	a, err := Alphabet(u.DCS)
+	if err != nil {
+		return AlphabetGSM7
+	}
+	return a
+}
+
+// SetClass marks the data coding scheme with message class 0-3, keeping
+// the alphabet. Class 0 makes a flash message.
+func (u *UserData) SetClass(class int) {
+	u.DCS = u.DCS&0x0C | 0x10 | byte(class&0x03)
+}
+
+// encode returns TP-UDL and TP-UD, and whether TP-UDHI must be set.
+func (u *UserData) encode() (udl byte, ud []byte, udhi bool, err error) {
+	header := u.UDH.encode()
+	switch u.Alphabet() {
+	case AlphabetGSM7:
+		septets, ok := EncodeGSM7(u.Text)
+		if !ok {
+			return 0, nil, false, fmt.Errorf("text cannot be encoded in the GSM 7-bit alphabet")
+		}
+		// The header plus fill bits occupy a whole number of septets
+		hdrSeptets := (len(header)*8 + 6) / 7
+		fill := hdrSeptets*7 - len(header)*8
+		total := hdrSeptets + len(septets)
+		if total > 160 {
+			return 0, nil, false, fmt.Errorf("user data of %d septets exceeds 160", total)
+		}
+		packed := PackSeptets(septets, fill)
+		return byte(total), append(header, packed...), len(header) > 0, nil
+	case AlphabetUCS2:
+		ud = header
+		for _, v := range utf16.Encode([]rune(u.Text)) {
+			ud = append(ud, byte(v>>8), byte(v))
+		}
+	default:
+		body := u.Data
+		if body == nil {
+			body = []byte(u.Text)
+		}
+		ud = append(header, body...)
+	}
+	if len(ud) > 140 {
+		return 0, nil, false, fmt.Errorf("user data of %d octets exceeds 140", len(ud))
+	}
+	return byte(len(ud)), ud, len(header) > 0, nil
+}
+
+// decode fills u from TP-UDL and the octets that follow it.
+func (u *UserData) decode(udhi bool, udl int, ud []byte) error {
+	alphabet, err := Alphabet(u.DCS)
+	if err != nil {
+		return err
+	}
+	hdrLen := 0
+	if udhi {
+		if u.UDH, hdrLen, err = parseUDH(ud); err != nil {
+			return err
+		}
+	}
+
+	switch alphabet {
+	case AlphabetGSM7:
+		hdrSeptets := (hdrLen*8 + 6) / 7
+		septets := UnpackSeptets(ud, udl)
+		if hdrSeptets > len(septets) {
+			return fmt.Errorf("UDH longer than user data")
+		}
+		u.Text = DecodeGSM7(septets[hdrSeptets:])
+	case AlphabetUCS2:
+		if udl > len(ud) || hdrLen > udl {
+			return fmt.Errorf("user data length %d exceeds PDU", udl)
+		}
+		body := ud[hdrLen:udl]
+		units := make([]uint16, 0, len(body)/2)
+		for i := 0; i+1 < len(body); i += 2 {
+			units = append(units, uint16(body[i])<<8|uint16(body[i+1]))
+		}
+		u.Text = string(utf16.Decode(units))
+	default:
+		if udl > len(ud) || hdrLen > udl {
+			return fmt.Errorf("user data length %d exceeds PDU", udl)
+		}
+		u.Data = append([]byte(nil), ud[hdrLen:udl]...)
+		if utf8.Valid(u.Data) {
+			u.Text = string(u.Data)
+		} else {
+			u.Text = strings.ToUpper(hex.EncodeToString(u.Data))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package pdu
+
+import (
+	"fmt"
+	"time"
+)
+
+// Validity period formats (TP-VPF).
+const (
+	VPNone     = 0x00
+	VPEnhanced = 0x01
+	VPRelative = 0x02
+	VPAbsolute = 0x03
+)
+
+// ValidityPeriod is how long the SMSC keeps trying to deliver a message.
+type ValidityPeriod struct {
+	Format   byte          // VPNone, VPRelative, VPAbsolute or VPEnhanced
+	Relative time.Duration // VPRelative, and VPEnhanced when it holds a duration
+	Absolute time.Time     // VPAbsolute
+	Enhanced [7]byte       // VPEnhanced, as sent
+}
+
+// RelativeValidity returns a relative validity period of at least d, up
+// to the maximum of 63 weeks.
+func RelativeValidity(d time.Duration) ValidityPeriod {
+	return ValidityPeriod{Format: VPRelative, Relative: relativeDuration(relativeOctet(d))}
+}
+
+// AbsoluteValidity returns a validity period ending at t.
+func AbsoluteValidity(t time.Time) ValidityPeriod {
+	return ValidityPeriod{Format: VPAbsolute, Absolute: t}
+}
+
+func (v ValidityPeriod) String() string {
+	switch v.Format {
+	case VPRelative:
+		return formatDuration(v.Relative)
+	case VPAbsolute:
+		return "until " + v.Absolute.Format("2006-01-02 15:04:05 -07:00")
+	case VPEnhanced:
+		if v.Relative > 0 {
+			return formatDuration(v.Relative) + " (enhanced)"
+		}
+		return fmt.Sprintf("enhanced % X", v.Enhanced[:])
+	}
+	return "none"
+}
+
+// formatDuration prints whole days as such, else as time.Duration does.
+func formatDuration(d time.Duration) string {
+	const day = 24 * time.Hour
+	if d >= day && d%day == 0 {
+		return fmt.Sprintf("%d days", d/day)
+	}
+	return d.String()
+}
+
+// encode returns the TP-VP octets for the format.
+func (v ValidityPeriod) encode() ([]byte, error) {
+	switch v.Format {
+	case VPNone:
+		return nil, nil
+	case VPRelative:
+		return []byte{relativeOctet(v.Relative)}, nil
+	case VPAbsolute:
+		return encodeTimestamp(v.Absolute), nil
+	case VPEnhanced:
+		return v.Enhanced[:], nil
+	}
+	return nil, fmt.Errorf("invalid validity period format %d", v.Format)
+}
+
+// readValidity reads the TP-VP field of format from r.
+func readValidity(r *reader, format byte) (ValidityPeriod, error) {
+	v := ValidityPeriod{Format: format}
+	switch format {
+	case VPRelative:
+		b, err := r.byte()
+		if err != nil {
+			return v, err
+		}
+		v.Relative = relativeDuration(b)
+	case VPAbsolute:
+		b, err := r.bytes(7)
+		if err != nil {
+			return v, err
+		}
+		v.Absolute = decodeTimestamp(b)
+	case VPEnhanced:
+		b, err := r.bytes(7)
+		if err != nil {
+			return v, err
+		}
+		copy(v.Enhanced[:], b)
+		v.Relative = enhancedDuration(b)
+	}
+	return v, nil
+}
+
+// relativeDuration decodes a relative TP-VP octet (3GPP TS 23.040
+// 9.2.3.12.1).
+func relativeDuration(b byte) time.Duration {
+	n := time.Duration(b)
+	switch {
+	case b <= 143:
+		return (n + 1) * 5 * time.Minute
+	case b <= 167:
+		return 12*time.Hour + (n-143)*30*time.Minute
+	case b <= 196:
+		return (n - 166) * 24 * time.Hour
+	default:
+		return (n - 192) * 7 * 24 * time.Hour
+	}
+}
+
+// relativeOctet returns the smallest relative TP-VP octet covering d.
+func relativeOctet(d time.Duration) byte {
+	for b := 0; b < 255; b++ {
+		if relativeDuration(byte(b)) >= d {
+			return byte(b)
+		}
+	}
+	return 255
+}
+
+// enhancedDuration decodes the relative forms of an enhanced validity
+// period, or returns 0.
+func enhancedDuration(b []byte) time.Duration {
+	switch b[0] & 0x07 {
+	case 0x01:
+		return relativeDuration(b[1])
+	case 0x02:
+		return time.Duration(b[1]) * time.Second
+	case 0x03:
+		return time.Duration(swappedBCD(b[1]))*time.Hour +
+			time.Duration(swappedBCD(b[2]))*time.Minute +
+			time.Duration(swappedBCD(b[3]))*time.Second
+	}
+	return 0
+}
+
+// swappedBCD decodes one nibble-swapped decimal octet.
+func swappedBCD(b byte) int {
+	return int(b&0x0F)*10 + int(b>>4)
+}
+
+// toSwappedBCD encodes 0-99 as a nibble-swapped decimal octet.
+func toSwappedBCD(n int) byte {
+	return byte(n%10)<<4 | byte(n/10%10)
+}
+
+// decodeTimestamp decodes a 7-octet service centre time stamp.
+func decodeTimestamp(b []byte) time.Time {
+	tzByte := b[6]
+	quarters := int(tzByte&0x07)*10 + int(tzByte>>4)
+	offset := quarters * 15 * 60
+	if tzByte&0x08 != 0 {
+		offset = -offset
+	}
+	loc := time.FixedZone("", offset)
+	return time.Date(2000+swappedBCD(b[0]), time.Month(swappedBCD(b[1])), swappedBCD(b[2]),
+		swappedBCD(b[3]), swappedBCD(b[4]), swappedBCD(b[5]), 0, loc)
+}
+
+// encodeTimestamp encodes t as a 7-octet time stamp in its own zone.
+func encodeTimestamp(t time.Time) []byte {
+	_, offset := t.Zone()
+	tz := byte(0)
+	if offset < 0 {
+		tz, offset = 0x08, -offset
+	}
+	tz |= toSwappedBCD(offset / (15 * 60))
+	return []byte{
+		toSwappedBCD(t.Year() % 100), toSwappedBCD(int(t.Month())), toSwappedBCD(t.Day()),
+		toSwappedBCD(t.Hour()), toSwappedBCD(t.Minute()), toSwappedBCD(t.Second()), tz,
+	}
+}
@@ -0,0 +1,468 @@
+package serial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// promptLine is the token the reader emits when the modem sends the "> "
+// prompt of AT+CMGS, which is not terminated by a line break.
+const promptLine = "> "
+
+// DefaultCommandTimeout bounds a command that has no entry in commandTimeouts.
+const DefaultCommandTimeout = 3 * time.Second
+
+// commandTimeouts lists commands that legitimately take longer than
+// DefaultCommandTimeout to produce their final result code.
+var commandTimeouts = map[string]time.Duration{
+	"AT+CMGS": 30 * time.Second,
+	"AT+CMGL": 10 * time.Second,
+	"AT+COPS": 30 * time.Second,
+	"AT+CPIN": 10 * time.Second,
+	"AT+CFUN": 15 * time.Second,
+}
+
+// Response is the outcome of a single AT command.
+type Response struct {
+	Command string
+	Lines   []string // information lines, without echo and final result code
+	Final   string   // OK, ERROR, +CME ERROR: <n> or +CMS ERROR: <n>
+}
+
+// OK reports whether the command completed with OK.
+func (r *Response) OK() bool {
+	return r.Final == "OK"
+}
+
+// Line returns the first information line starting with prefix, or "".
+func (r *Response) Line(prefix string) string {
+	for _, l := range r.Lines {
+		if strings.HasPrefix(l, prefix) {
+			return l
+		}
+	}
+	return ""
+}
+
+// ATError is returned when a command ends with anything other than OK.
+type ATError struct {
+	Command string
+	Final   string
+}
+
+func (e *ATError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Command, e.Final)
+}
+
+// ErrTimeout is wrapped by errors returned when no final result code
+// arrives within the command timeout.
+var ErrTimeout = errors.New("timeout waiting for modem response")
+
+// ErrSubmitted is wrapped by errors of a prompted command whose data was
+// already written: the modem may have acted on it, e.g. the SMSC accepted
+// the message, so repeating it can deliver it twice.
+var ErrSubmitted = errors.New("submitted, but the result is unknown")
+
+// URC is an unsolicited result code. Body holds the line that follows
+// the header for URCs registered with a body (e.g. the PDU after +CMT).
+type URC struct {
+	Line string
+	Body string
+}
+
+// URCHandler receives unsolicited result codes. Handlers run on the reader
+// goroutine, so they must return quickly and must not issue AT commands.
+type URCHandler func(URC)
+
+type urcEntry struct {
+	prefix   string
+	withBody bool
+	handler  URCHandler
+}
+
+// knownURCs are recognised as unsolicited even without a handler so they
+// never leak into command responses.
+var knownURCs = []string{
+	"RING", "RDY", "+CMTI:", "+CMT:", "+CDS:", "+CDSI:", "+CREG:", "+CEREG:",
+	"+CGREG:", "+CUSD:", "+CLIP:", "+QIND:", "+CPIN:", "+CFUN:",
+}
+
+// isFinalResult reports whether line terminates a command response.
+func isFinalResult(line string) bool {
+	switch line {
+	case "OK", "ERROR", "NO CARRIER", "BUSY", "NO ANSWER", "NO DIALTONE":
+		return true
+	}
+	return strings.HasPrefix(line, "+CME ERROR:") || strings.HasPrefix(line, "+CMS ERROR:")
+}
+
+// commandPrefix returns the information-line prefix a command claims,
+// e.g. "AT+CREG?" -> "+CREG:". Lines with that prefix are treated as part of
+// the response while the command is pending, even if they look like URCs.
+func commandPrefix(cmd string) string {
+	cmd = strings.ToUpper(strings.TrimSpace(cmd))
+	if !strings.HasPrefix(cmd, "AT") || len(cmd) < 3 {
+		return ""
+	}
+	name := cmd[2:]
+	if i := strings.IndexAny(name, "=?"); i >= 0 {
+		name = name[:i]
+	}
+	if name == "" || (name[0] != '+' && name[0] != '^') {
+		return ""
+	}
+	return name + ":"
+}
+
+// Redact hides the codes of commands that carry secrets (AT+CPIN=<pin>,
+// also as part of a ';'-chained command line) so they never reach the
+// logs, error messages or the AT console transcript.
+func Redact(cmd string) string {
+	const secret = "+CPIN="
+	for i := 0; i+len(secret) <= len(cmd); i++ {
+		if strings.EqualFold(cmd[i:i+len(secret)], secret) {
+			return cmd[:i+len(secret)] + "****"
+		}
+	}
+	return cmd
+}
+
+// timeoutFor returns the response timeout for cmd.
+func timeoutFor(cmd string) time.Duration {
+	upper := strings.ToUpper(cmd)
+	for prefix, d := range commandTimeouts {
+		if strings.HasPrefix(upper, prefix) {
+			return d
+		}
+	}
+	return DefaultCommandTimeout
+}
+
+// atChannel owns the reader goroutine for one open port.
+type atChannel struct {
+	port  io.ReadWriteCloser
+	lines chan string
+	done  chan struct{}
+	once  sync.Once
+
+	mu      sync.Mutex
+	pending string     // prefix claimed by the in-flight command
+	call    *callWatch // voice call in progress, if any
+}
+
+// OnURC registers a handler for unsolicited lines starting with prefix.
+// When withBody is true the line following the header is delivered as
+// URC.Body. Registering the same prefix again replaces the handler.
+func (g *GSMModem) OnURC(prefix string, withBody bool, h URCHandler) {
+	g.urcMu.Lock()
+	defer g.urcMu.Unlock()
+	for i, e := range g.urcs {
+		if e.prefix == prefix {
+			g.urcs[i] = urcEntry{prefix: prefix, withBody: withBody, handler: h}
+			return
+		}
+	}
+	g.urcs = append(g.urcs, urcEntry{prefix: prefix, withBody: withBody, handler: h})
+}
+
+// matchURC finds the registered or known URC for line.
+func (g *GSMModem) matchURC(line string) (urcEntry, bool) {
+	g.urcMu.Lock()
+	defer g.urcMu.Unlock()
+	for _, e := range g.urcs {
+		if strings.HasPrefix(line, e.prefix) {
+			return e, true
+		}
+	}
+	for _, p := range knownURCs {
+		if strings.HasPrefix(line, p) {
+			return urcEntry{prefix: p}, true
+		}
+	}
+	for _, p := range g.profile().URCs {
+		if strings.HasPrefix(line, p) {
+			return urcEntry{prefix: p}, true
+		}
+	}
+	return urcEntry{}, false
+}
+
+// startReader attaches a reader goroutine to the freshly opened port.
+func (g *GSMModem) startReader(port io.ReadWriteCloser) *atChannel {
+	ch := &atChannel{
+		port:  port,
+		lines: make(chan string, 64),
+		done:  make(chan struct{}),
+	}
+	go g.readLoop(ch)
+	return ch
+}
+
+// stop makes the reader goroutine exit on its next read.
+func (ch *atChannel) stop() {
+	ch.once.Do(func() { close(ch.done) })
+}
+
+func (ch *atChannel) stopped() bool {
+	select {
+	case <-ch.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// readLoop splits the serial stream into lines and routes each one either
+// to the pending command or to a URC handler.
+func (g *GSMModem) readLoop(ch *atChannel) {
+	buf := make([]byte, 512)
+	var partial strings.Builder
+	var bodyFor *urcEntry
+	var bodyHeader string
+
+	for {
+		n, err := ch.port.Read(buf)
+		if ch.stopped() {
+			return
+		}
+		for _, b := range buf[:n] {
+			if b != '\r' && b != '\n' {
+				partial.WriteByte(b)
+				continue
+			}
+			line := strings.TrimSpace(partial.String())
+			partial.Reset()
+			if line == "" {
+				continue
+			}
+			if bodyFor != nil {
+				e := *bodyFor
+				bodyFor = nil
+				g.dispatchURC(e, URC{Line: bodyHeader, Body: line})
+				continue
+			}
+			if ch.callEvent(line) {
+				continue
+			}
+			if e, ok := g.routeURC(ch, line); ok {
+				if e.withBody {
+					bodyFor = &e
+					bodyHeader = line
+					continue
+				}
+				g.dispatchURC(e, URC{Line: line})
+				continue
+			}
+			g.deliver(ch, line)
+		}
+		// The CMGS prompt is not followed by a line break
+		if p := strings.TrimSpace(partial.String()); p == ">" {
+			partial.Reset()
+			g.deliver(ch, promptLine)
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				// Serial drivers report a read timeout as EOF on some platforms
+				time.Sleep(20 * time.Millisecond)
+				continue
+			}
+			g.log(fmt.Sprintf("Serial read error: %v", err), true)
+			ch.stop()
+			return
+		}
+	}
+}
+
+// routeURC decides whether line is unsolicited. Lines claimed by the
+// pending command (e.g. +CREG: while AT+CREG? is in flight) are not.
+func (g *GSMModem) routeURC(ch *atChannel, line string) (urcEntry, bool) {
+	e, ok := g.matchURC(line)
+	if !ok {
+		return e, false
+	}
+	ch.mu.Lock()
+	pending := ch.pending
+	ch.mu.Unlock()
+	if pending != "" && strings.HasPrefix(line, pending) {
+		return e, false
+	}
+	return e, true
+}
+
+func (g *GSMModem) dispatchURC(e urcEntry, u URC) {
+	if e.handler == nil {
+		g.log(fmt.Sprintf("URC: %s", u.Line), true)
+		return
+	}
+	e.handler(u)
+}
+
+func (g *GSMModem) deliver(ch *atChannel, line string) {
+	select {
+	case ch.lines <- line:
+	default:
+		g.log(fmt.Sprintf("Dropping unread modem line: %s", line), true)
+	}
+}
+
+// drain discards lines left over from an earlier, abandoned exchange.
+func (ch *atChannel) drain() {
+	for {
+		select {
+		case <-ch.lines:
+		default:
+			return
+		}
+	}
+}
+
+func (ch *atChannel) setPending(cmd string) {
+	ch.mu.Lock()
+	ch.pending = commandPrefix(cmd)
+	ch.mu.Unlock()
+}
+
+func (ch *atChannel) clearPending() {
+	ch.setPending("")
+}
+
+// exec writes cmd and collects lines until its final result code. It does
+// not take g.mu; callers hold it.
+func (g *GSMModem) exec(cmd string) (*Response, error) {
+	return g.execTimeout(cmd, g.timeoutFor(cmd))
+}
+
+func (g *GSMModem) execTimeout(cmd string, timeout time.Duration) (*Response, error) {
+	return g.execContext(context.Background(), cmd, timeout)
+}
+
+// execContext is execTimeout that also gives up when ctx is done.
+func (g *GSMModem) execContext(ctx context.Context, cmd string, timeout time.Duration) (*Response, error) {
+	ch := g.at
+	if ch == nil || g.port == nil {
+		return nil, fmt.Errorf("port not open")
+	}
+	ch.drain()
+	ch.setPending(cmd)
+	defer ch.clearPending()
+
+	g.log(fmt.Sprintf("CMD: %s", Redact(cmd)), true)
+	if _, err := g.port.Write([]byte(cmd + "\r")); err != nil {
+		g.log(fmt.Sprintf("Write Error: %v", err), false)
+		return nil, err
+	}
+
+	resp, err := g.collect(ctx, ch, cmd, timeout, false)
+	if err != nil {
+		return resp, err
+	}
+	g.logResponse(resp)
+	if !resp.OK() {
+		return resp, &ATError{Command: Redact(cmd), Final: resp.Final}
+	}
+	return resp, nil
+}
+
+// execPrompt runs a two-stage command such as AT+CMGS: it waits for the
+// "> " prompt, writes data terminated by Ctrl+Z, then waits for the final
+// result code. Giving up before the data is sent, on a timeout or when ctx
+// is done, cancels the prompt with ESC.
+func (g *GSMModem) execPrompt(ctx context.Context, cmd string, data string, promptTimeout, timeout time.Duration) (*Response, error) {
+	ch := g.at
+	if ch == nil || g.port == nil {
+		return nil, fmt.Errorf("port not open")
+	}
+	ch.drain()
+	ch.setPending(cmd)
+	defer ch.clearPending()
+
+	g.log(fmt.Sprintf("CMD: %s", Redact(cmd)), true)
+	if _, err := g.port.Write([]byte(cmd + "\r")); err != nil {
+		return nil, fmt.Errorf("write %s failed: %w", Redact(cmd), err)
+	}
+
+	resp, err := g.collect(ctx, ch, cmd, promptTimeout, true)
+	if err == nil && resp.Final == promptLine {
+		err = ctx.Err() // cancelled just as the prompt arrived
+	}
+	if err != nil {
+		// A prompt arriving late would take the next command for PDU data
+		g.port.Write([]byte{27})
+		return resp, fmt.Errorf("waiting for '>' prompt: %w", err)
+	}
+	if resp.Final != promptLine {
+		g.logResponse(resp)
+		return resp, &ATError{Command: Redact(cmd), Final: resp.Final}
+	}
+
+	if _, err := g.port.Write([]byte(data)); err != nil {
+		return nil, fmt.Errorf("write data failed: %w", err)
+	}
+	if _, err := g.port.Write([]byte{26}); err != nil {
+		return nil, fmt.Errorf("write Ctrl+Z failed: %w", err)
+	}
+
+	resp, err = g.collect(ctx, ch, cmd, timeout, false)
+	if err != nil {
+		return resp, fmt.Errorf("%w: %w", ErrSubmitted, err)
+	}
+	g.logResponse(resp)
+	if !resp.OK() {
+		return resp, &ATError{Command: Redact(cmd), Final: resp.Final}
+	}
+	return resp, nil
+}
+
+// collect gathers lines for cmd until a final result code (or the prompt,
+// when wantPrompt is set) arrives, the timeout expires or ctx is done.
+func (g *GSMModem) collect(ctx context.Context, ch *atChannel, cmd string, timeout time.Duration, wantPrompt bool) (*Response, error) {
+	resp := &Response{Command: Redact(cmd)}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case line := <-ch.lines:
+			if line == promptLine {
+				if wantPrompt {
+					resp.Final = promptLine
+					return resp, nil
+				}
+				continue
+			}
+			if line == strings.TrimSpace(cmd) {
+				continue // echo
+			}
+			if isFinalResult(line) {
+				resp.Final = line
+				return resp, nil
+			}
+			resp.Lines = append(resp.Lines, line)
+		case <-ch.done:
+			return resp, fmt.Errorf("%s: port closed", Redact(cmd))
+		case <-ctx.Done():
+			return resp, fmt.Errorf("%s: %w", Redact(cmd), ctx.Err())
+		case <-timer.C:
+			g.logResponse(resp)
+			return resp, fmt.Errorf("%s: %w after %v", Redact(cmd), ErrTimeout, timeout)
+		}
+	}
+}
+
+func (g *GSMModem) logResponse(resp *Response) {
+	parts := append([]string(nil), resp.Lines...)
+	if resp.Final != "" {
+		parts = append(parts, resp.Final)
+	}
+	logResp := strings.Join(parts, " ")
+	if len(logResp) > 100 {
+		logResp = logResp[:100] + "..."
+	}
+	g.log(fmt.Sprintf("RESP: %s", logResp), true)
+}
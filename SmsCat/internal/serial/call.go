@@ -0,0 +1,321 @@
+package serial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"smallNfast/internal/pdu"
+)
+
+// Call outcomes reported in CallResult.Outcome.
+const (
+	CallConfirmed = "confirmed" // answered and a DTMF key was pressed
+	CallAnswered  = "answered"
+	CallNoAnswer  = "no answer"
+	CallBusy      = "busy"
+	CallRejected  = "no carrier" // declined, unreachable or dropped before answering
+)
+
+const (
+	// DefaultRingTime is how long a call rings before it is hung up.
+	DefaultRingTime = 30 * time.Second
+	// DefaultConfirmTime is how long an answered call waits for a key.
+	DefaultConfirmTime = 30 * time.Second
+	// callPollInterval is how often AT+CLCC is polled while ringing.
+	callPollInterval = time.Second
+)
+
+// DTMFDetect is the vendor command that reports keys pressed by the callee
+// as unsolicited Prefix lines.
+type DTMFDetect struct {
+	Command string
+	Prefix  string
+	// ASCII is set when the URC carries the key's ASCII code (49 for "1").
+	ASCII bool
+}
+
+// key extracts the pressed key from a DTMF URC.
+func (d DTMFDetect) key(line string) string {
+	v := strings.TrimSpace(strings.TrimPrefix(line, d.Prefix))
+	if d.ASCII {
+		if n, err := strconv.Atoi(v); err == nil && n > 32 && n < 127 {
+			return string(rune(n))
+		}
+	}
+	return v
+}
+
+// CallOptions controls a voice call placed with Call.
+type CallOptions struct {
+	// RingTime hangs up a call that is not answered in time
+	// (DefaultRingTime when zero).
+	RingTime time.Duration
+	// Confirm keeps an answered call open until the callee presses a key
+	// or ConfirmTime (DefaultConfirmTime when zero) has passed.
+	Confirm     bool
+	ConfirmTime time.Duration
+}
+
+// CallResult is how a voice call ended.
+type CallResult struct {
+	Outcome string
+	Key     string // DTMF key pressed by the callee, "" if none
+}
+
+// callWatch routes call-progress lines to a voice call in progress.
+type callWatch struct {
+	events     chan string
+	dtmfPrefix string
+}
+
+func (w *callWatch) matches(line string) bool {
+	return callEndOutcome(line) != "" || (w.dtmfPrefix != "" && strings.HasPrefix(line, w.dtmfPrefix))
+}
+
+// callEndOutcome maps the result codes that end a call to an outcome.
+func callEndOutcome(line string) string {
+	switch line {
+	case "NO CARRIER":
+		return CallRejected
+	case "BUSY":
+		return CallBusy
+	case "NO ANSWER":
+		return CallNoAnswer
+	}
+	return ""
+}
+
+// watchCall starts routing call-progress lines to a new watch. Lines that
+// arrived between the ATD result and the watch are picked up as well.
+func (ch *atChannel) watchCall(dtmfPrefix string) *callWatch {
+	w := &callWatch{events: make(chan string, 8), dtmfPrefix: dtmfPrefix}
+	ch.mu.Lock()
+	ch.call = w
+	ch.mu.Unlock()
+	for {
+		select {
+		case line := <-ch.lines:
+			ch.callEvent(line)
+		default:
+			return w
+		}
+	}
+}
+
+func (ch *atChannel) unwatchCall() {
+	ch.mu.Lock()
+	ch.call = nil
+	ch.mu.Unlock()
+}
+
+// callEvent hands line to the active call watch. It reports false when no
+// call is being watched or the line is not call progress.
+func (ch *atChannel) callEvent(line string) bool {
+	ch.mu.Lock()
+	w := ch.call
+	ch.mu.Unlock()
+	if w == nil || !w.matches(line) {
+		return false
+	}
+	select {
+	case w.events <- line:
+	default:
+	}
+	return true
+}
+
+// Call places a voice call to number (ATD<number>;) and hangs up with ATH
+// once it was answered, after opts.RingTime without an answer, or after
+// the callee confirmed with a key when opts.Confirm is set. Busy, declined
+// and unanswered calls are outcomes, not errors. When ctx is done the call
+// is hung up and ctx's error returned; an answered call awaiting its key
+// ends as CallAnswered.
+func (g *GSMModem) Call(ctx context.Context, number string, opts CallOptions) (*CallResult, error) {
+	digits, international, err := pdu.NormalizeNumber(number)
+	if err != nil {
+		return nil, fmt.Errorf("call: %w", err)
+	}
+	if digits == "" {
+		return nil, fmt.Errorf("call: empty number")
+	}
+	if international {
+		digits = "+" + digits
+	}
+	if opts.RingTime <= 0 {
+		opts.RingTime = DefaultRingTime
+	}
+	if opts.ConfirmTime <= 0 {
+		opts.ConfirmTime = DefaultConfirmTime
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("call: %w", err)
+	}
+	if err := g.connect(); err != nil {
+		return nil, err
+	}
+	cancelled := func() (*CallResult, error) {
+		g.hangUp()
+		g.log("Call: cancelled", false)
+		return nil, fmt.Errorf("call: %w", ctx.Err())
+	}
+	fail := func(err error) error {
+		g.log(fmt.Sprintf("CALL FAILED: %v", err), false)
+		g.close()
+		return err
+	}
+
+	dtmf := g.profile().DTMF
+	answerConfirms := false // confirmation asked for but keys cannot be read
+	if opts.Confirm {
+		if dtmf.Command == "" {
+			g.log(fmt.Sprintf("Call: %s cannot detect DTMF, an answer counts as confirmation", g.profile()), false)
+			opts.Confirm, answerConfirms = false, true
+		} else if _, err := g.exec(dtmf.Command); err != nil {
+			g.log(fmt.Sprintf("Warning: %s failed, an answer counts as confirmation", dtmf.Command), false)
+			opts.Confirm, answerConfirms = false, true
+		}
+	}
+
+	g.log(fmt.Sprintf("Call → %s (ringing up to %v)", digits, opts.RingTime), false)
+	if _, err := g.execContext(ctx, "ATD"+digits+";", opts.RingTime); err != nil {
+		if ctx.Err() != nil {
+			return cancelled()
+		}
+		var atErr *ATError
+		if errors.As(err, &atErr) {
+			// Modems that wait for the call to connect end ATD with the outcome
+			if outcome := callEndOutcome(atErr.Final); outcome != "" {
+				g.log(fmt.Sprintf("Call: %s", outcome), false)
+				return &CallResult{Outcome: outcome}, nil
+			}
+			return nil, fmt.Errorf("modem rejected call: %s", atErr.Final)
+		}
+		if errors.Is(err, ErrTimeout) {
+			g.hangUp()
+			g.log("Call: no answer", false)
+			return &CallResult{Outcome: CallNoAnswer}, nil
+		}
+		return nil, fail(err)
+	}
+
+	ch := g.at
+	w := ch.watchCall(dtmf.Prefix)
+	defer ch.unwatchCall()
+
+	ringing := time.NewTimer(opts.RingTime)
+	defer ringing.Stop()
+	poll := time.NewTicker(callPollInterval)
+	defer poll.Stop()
+
+	var key string // pressed before the answer was polled
+	for answered := false; !answered; {
+		select {
+		case line := <-w.events:
+			if outcome := callEndOutcome(line); outcome != "" {
+				g.log(fmt.Sprintf("Call: %s", outcome), false)
+				return &CallResult{Outcome: outcome}, nil
+			}
+			key, answered = dtmf.key(line), true
+		case <-poll.C:
+			stat, err := g.callState()
+			if err != nil {
+				g.hangUp()
+				return nil, fail(err)
+			}
+			switch stat {
+			case 0:
+				answered = true
+			case -1:
+				g.log("Call: dropped", false)
+				return &CallResult{Outcome: CallRejected}, nil
+			}
+		case <-ringing.C:
+			g.hangUp()
+			g.log("Call: no answer", false)
+			return &CallResult{Outcome: CallNoAnswer}, nil
+		case <-ch.done:
+			return nil, fail(fmt.Errorf("call: port closed"))
+		case <-ctx.Done():
+			return cancelled()
+		}
+	}
+
+	result := &CallResult{Outcome: CallAnswered}
+	switch {
+	case opts.Confirm && key != "":
+		result = &CallResult{Outcome: CallConfirmed, Key: key}
+	case opts.Confirm:
+		g.log(fmt.Sprintf("Call: answered, waiting up to %v for a key", opts.ConfirmTime), false)
+		result = g.awaitKey(ctx, w, dtmf, opts.ConfirmTime)
+	}
+	if result.Outcome != CallRejected {
+		g.hangUp()
+	} else {
+		result.Outcome = CallAnswered // the callee hung up
+	}
+	if answerConfirms {
+		result.Outcome = CallConfirmed
+	}
+	g.log(fmt.Sprintf("Call: %s", result.Outcome), false)
+	return result, nil
+}
+
+// awaitKey waits on an answered call for a DTMF key. The outcome is
+// CallRejected when the callee hung up first, and CallAnswered when no key
+// came before the timeout or ctx was done.
+func (g *GSMModem) awaitKey(ctx context.Context, w *callWatch, dtmf DTMFDetect, timeout time.Duration) *CallResult {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case line := <-w.events:
+			if callEndOutcome(line) != "" {
+				return &CallResult{Outcome: CallRejected}
+			}
+			if key := dtmf.key(line); key != "" {
+				return &CallResult{Outcome: CallConfirmed, Key: key}
+			}
+		case <-timer.C:
+			return &CallResult{Outcome: CallAnswered}
+		case <-g.at.done:
+			return &CallResult{Outcome: CallAnswered}
+		case <-ctx.Done():
+			return &CallResult{Outcome: CallAnswered}
+		}
+	}
+}
+
+// callState returns the <stat> of our outgoing voice call from AT+CLCC
+// (0 active, 2 dialing, 3 alerting), or -1 when there is none.
+func (g *GSMModem) callState() (int, error) {
+	resp, err := g.exec("AT+CLCC")
+	if err != nil {
+		return -1, err
+	}
+	for _, l := range resp.Lines {
+		fields := splitInfoLine(l, "+CLCC:")
+		if len(fields) < 4 || fields[1] != "0" || fields[3] != "0" {
+			continue // not mobile-originated voice
+		}
+		if stat, err := strconv.Atoi(fields[2]); err == nil {
+			return stat, nil
+		}
+	}
+	return -1, nil
+}
+
+// hangUp ends the current call. Errors are only logged: the call may
+// already be gone.
+func (g *GSMModem) hangUp() {
+	if _, err := g.exec("ATH"); err != nil {
+		g.log(fmt.Sprintf("Warning: ATH failed: %v", err), false)
+	}
+}
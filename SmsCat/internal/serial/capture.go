@@ -0,0 +1,172 @@
+package serial
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A capture file holds the raw traffic of one port session, one chunk per
+// line: the offset in seconds since the port was opened, W for bytes
+// written to the modem or R for bytes read from it, and the bytes as a Go
+// string literal:
+//
+//	# SMSCat serial capture of COM5, opened 2026-10-18T06:34:17.848Z
+//	0.000113 W "AT\r"
+//	0.012406 R "\r\nOK\r\n"
+
+// CaptureEvent is one chunk of recorded traffic.
+type CaptureEvent struct {
+	Offset time.Duration // since the port was opened
+	Write  bool          // sent to the modem; false for bytes read from it
+	Data   []byte
+}
+
+// Capture is the recorded traffic of one port session.
+type Capture struct {
+	Port   string
+	Opened time.Time
+	Events []CaptureEvent
+}
+
+const captureHeader = "# SMSCat serial capture of "
+
+// LoadCapture reads a capture file.
+func LoadCapture(path string) (*Capture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCapture(f)
+}
+
+// ReadCapture parses a capture. Comment lines other than the header are
+// ignored.
+func ReadCapture(r io.Reader) (*Capture, error) {
+	c := &Capture{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, captureHeader) {
+			head := strings.TrimPrefix(line, captureHeader)
+			if i := strings.LastIndex(head, ", opened "); i >= 0 {
+				c.Port = head[:i]
+				c.Opened, _ = time.Parse(time.RFC3339Nano, head[i+len(", opened "):])
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 || (fields[1] != "W" && fields[1] != "R") {
+			return nil, fmt.Errorf("capture line %d: malformed", lineNo)
+		}
+		secs, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("capture line %d: bad offset: %w", lineNo, err)
+		}
+		data, err := strconv.Unquote(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("capture line %d: bad data: %w", lineNo, err)
+		}
+		c.Events = append(c.Events, CaptureEvent{
+			Offset: time.Duration(secs * float64(time.Second)),
+			Write:  fields[1] == "W",
+			Data:   []byte(data),
+		})
+	}
+	return c, scanner.Err()
+}
+
+// recorder passes traffic through to port and appends it to a capture.
+type recorder struct {
+	port  io.ReadWriteCloser
+	mu    sync.Mutex
+	out   io.WriteCloser
+	start time.Time
+}
+
+// NewRecorder returns port with every byte read and written also recorded
+// to out in the capture format. Closing it closes both.
+func NewRecorder(port io.ReadWriteCloser, out io.WriteCloser, name string) io.ReadWriteCloser {
+	r := &recorder{port: port, out: out, start: time.Now()}
+	fmt.Fprintf(out, "%s%s, opened %s\n", captureHeader, name, r.start.UTC().Format(time.RFC3339Nano))
+	return r
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.port.Read(p)
+	if n > 0 {
+		r.record("R", p[:n])
+	}
+	return n, err
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	n, err := r.port.Write(p)
+	if n > 0 {
+		r.record("W", p[:n])
+	}
+	return n, err
+}
+
+func (r *recorder) Close() error {
+	err := r.port.Close()
+	r.mu.Lock()
+	r.out.Close()
+	r.mu.Unlock()
+	return err
+}
+
+// record appends one event. A capture that cannot be written must not
+// disturb the modem, so errors are dropped.
+func (r *recorder) record(dir string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.out, "%.6f %s %s\n", time.Since(r.start).Seconds(), dir, strconv.Quote(string(data)))
+}
+
+// RecordTo wraps open so that every port it opens is recorded to a new
+// capture file in dir, named after the port and the time. When the file
+// cannot be created the port is used unrecorded and logf is told why.
+func RecordTo(open func(name string, baud int) (io.ReadWriteCloser, error), dir string, logf func(string, bool)) func(name string, baud int) (io.ReadWriteCloser, error) {
+	return func(name string, baud int) (io.ReadWriteCloser, error) {
+		port, err := open(name, baud)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%s-%s.cap", captureName(name), time.Now().Format("20060102-150405.000")))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			logf(fmt.Sprintf("Capture disabled: %v", err), false)
+			return port, nil
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			logf(fmt.Sprintf("Capture disabled: %v", err), false)
+			return port, nil
+		}
+		logf(fmt.Sprintf("Recording serial traffic to %s", path), true)
+		return NewRecorder(port, f, name), nil
+	}
+}
+
+// captureName turns a port name such as /dev/ttyUSB2 into a file name part.
+func captureName(port string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.TrimPrefix(port, "/dev/"))
+}
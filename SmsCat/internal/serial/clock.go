@@ -0,0 +1,80 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrClockNotSet means the modem's clock still runs from its power-on
+// default: the network has not sent it the time (NITZ).
+var ErrClockNotSet = errors.New("modem clock not set by the network")
+
+// Clock reads the modem's real-time clock (AT+CCLK?). With AT+CTZU=1 in
+// the init script the network keeps it and its time zone up to date. A
+// clock without a zone is taken to be in the host's local time.
+func (g *GSMModem) Clock() (time.Time, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.connect(); err != nil {
+		return time.Time{}, err
+	}
+	resp, err := g.exec("AT+CCLK?")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read clock: %w", err)
+	}
+	value := strings.TrimSpace(strings.TrimPrefix(resp.Line("+CCLK:"), "+CCLK:"))
+	t, err := parseCCLK(g.decodeString(strings.Trim(value, "\"")))
+	if err != nil {
+		return time.Time{}, err
+	}
+	// Modems fall back to 1980, 2000 or their firmware date
+	if t.Year() < 2020 {
+		return t, ErrClockNotSet
+	}
+	return t, nil
+}
+
+// parseCCLK parses "yy/MM/dd,hh:mm:ss±zz", where zz is the offset from
+// UTC in quarter hours and may be missing.
+func parseCCLK(s string) (time.Time, error) {
+	bad := fmt.Errorf("invalid clock %q", s)
+	date, clock, ok := strings.Cut(s, ",")
+	if !ok {
+		return time.Time{}, bad
+	}
+	loc := time.Local
+	if i := strings.IndexAny(clock, "+-"); i >= 0 {
+		q, err := strconv.Atoi(clock[i+1:])
+		if err != nil {
+			return time.Time{}, bad
+		}
+		offset := q * 15 * 60
+		if clock[i] == '-' {
+			offset = -offset
+		}
+		loc = time.FixedZone("", offset)
+		clock = clock[:i]
+	}
+	var n []int
+	for _, part := range append(strings.Split(date, "/"), strings.Split(clock, ":")...) {
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, bad
+		}
+		n = append(n, v)
+	}
+	if len(n) != 6 {
+		return time.Time{}, bad
+	}
+	// Two-digit years: power-on defaults such as 80/01/06 are 1980
+	if n[0] < 70 {
+		n[0] += 2000
+	} else if n[0] < 100 {
+		n[0] += 1900
+	}
+	return time.Date(n[0], time.Month(n[1]), n[2], n[3], n[4], n[5], 0, loc), nil
+}
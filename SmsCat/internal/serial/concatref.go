@@ -0,0 +1,103 @@
+package serial
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"smallNfast/internal/pdu"
+)
+
+// ConcatRefs hands out the reference numbers of concatenated SMS, one
+// counter per recipient. Two long messages to the same number therefore
+// never share a reference (which would make the handset merge them) until
+// the counter wraps. With a path, the counters survive a restart.
+type ConcatRefs struct {
+	mu   sync.Mutex
+	path string
+	next map[string]int // 16-bit; the 8-bit element uses the low byte
+}
+
+// defaultConcatRefs serves modems without their own ConcatRefs.
+var defaultConcatRefs = NewConcatRefs("")
+
+// NewConcatRefs returns counters saved to path, loading the ones saved
+// there before. An empty path keeps them in memory only; so does a file
+// that cannot be read.
+func NewConcatRefs(path string) *ConcatRefs {
+	c := &ConcatRefs{path: path, next: make(map[string]int)}
+	if path == "" {
+		return c
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return c
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+			c.next[strings.TrimSpace(parts[0])] = n & 0xFFFF
+		}
+	}
+	return c
+}
+
+// Next returns the reference for the next concatenated message to number.
+// A recipient seen for the first time starts at a clock-derived value, so
+// references still differ from the ones used before the counter existed.
+// The reference is valid even when saving the counters fails.
+func (c *ConcatRefs) Next(number string) (int, error) {
+	key := number
+	if digits, intl, err := pdu.NormalizeNumber(number); err == nil {
+		key = digits
+		if intl {
+			key = "+" + digits
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ref, ok := c.next[key]
+	if !ok {
+		ref = int(time.Now().UnixNano()/int64(time.Millisecond)) & 0xFFFF
+	}
+	c.next[key] = (ref + 1) & 0xFFFF
+	return ref, c.save()
+}
+
+// save writes every counter to the file, sorted by number.
+func (c *ConcatRefs) save() error {
+	if c.path == "" {
+		return nil
+	}
+	keys := make([]string, 0, len(c.next))
+	for k := range c.next {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("# Next concatenated SMS reference per recipient, maintained by SMSCat\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%d\n", k, c.next[k])
+	}
+	// Write a temporary file first so a crash cannot leave it truncated
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
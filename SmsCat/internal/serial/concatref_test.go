@@ -0,0 +1,120 @@
+package serial
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smallNfast/internal/pdu"
+)
+
+func TestConcatRefsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concat_refs.properties")
+	refs := NewConcatRefs(path)
+
+	first, err := refs.Next("+86 138-0013-8000")
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	second, err := refs.Next("+8613800138000")
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if second != (first+1)&0xFFFF {
+		t.Errorf("second reference = %d, want %d: one counter per normalized number", second, (first+1)&0xFFFF)
+	}
+	if _, err := refs.Next("10086"); err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("counters not saved: %v", err)
+	}
+	if !strings.Contains(string(data), "+8613800138000=") || !strings.Contains(string(data), "10086=") {
+		t.Errorf("saved counters lack a recipient:\n%s", data)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	// A restart continues where the counters left off
+	reloaded := NewConcatRefs(path)
+	third, err := reloaded.Next("+8613800138000")
+	if err != nil {
+		t.Fatalf("Next after reload: %v", err)
+	}
+	if third != (second+1)&0xFFFF {
+		t.Errorf("reference after reload = %d, want %d", third, (second+1)&0xFFFF)
+	}
+}
+
+func TestConcatRefsWrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concat_refs.properties")
+	if err := os.WriteFile(path, []byte("# counters\n+8613800138000=65535\nbroken line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	refs := NewConcatRefs(path)
+	for _, want := range []int{65535, 0, 1} {
+		got, err := refs.Next("+8613800138000")
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if got != want {
+			t.Errorf("Next = %d, want %d", got, want)
+		}
+	}
+}
+
+func TestConcatRefsUnwritable(t *testing.T) {
+	// A directory in the way: the reference is still handed out
+	path := t.TempDir()
+	refs := NewConcatRefs(path)
+	if _, err := refs.Next("+8613800138000"); err == nil {
+		t.Error("Next saved into a directory, want an error")
+	}
+	a, _ := refs.Next("+8613800138000")
+	b, _ := refs.Next("+8613800138000")
+	if b != (a+1)&0xFFFF {
+		t.Errorf("references %d, %d do not count up without a file", a, b)
+	}
+}
+
+func TestSendSMSReferenceElement(t *testing.T) {
+	tests := []struct {
+		concat16 bool
+		id       byte
+	}{
+		{false, pdu.IEConcat8},
+		{true, pdu.IEConcat16},
+	}
+	for _, tt := range tests {
+		sim := NewSimulator()
+		g := newTestModem(t, sim)
+		g.Concat16 = tt.concat16
+		if _, err := g.SendSMS(context.Background(), "+8613800138000", strings.Repeat("x", 200), SendOptions{}); err != nil {
+			t.Fatalf("Concat16=%v: SendSMS: %v", tt.concat16, err)
+		}
+		sent := sim.SentPDUs()
+		if len(sent) != 2 {
+			t.Fatalf("Concat16=%v: sent %d parts, want 2", tt.concat16, len(sent))
+		}
+		refs := map[int]bool{}
+		for _, hex := range sent {
+			s, err := pdu.DecodeSubmit(hex)
+			if err != nil {
+				t.Fatalf("DecodeSubmit: %v", err)
+			}
+			if len(s.UDH) != 1 || s.UDH[0].ID != tt.id {
+				t.Errorf("Concat16=%v: header %+v, want element %02X", tt.concat16, s.UDH, tt.id)
+			}
+			ref, _, _, _ := s.UDH.Concat()
+			refs[ref] = true
+		}
+		if len(refs) != 1 {
+			t.Errorf("Concat16=%v: parts carry references %v, want one", tt.concat16, refs)
+		}
+	}
+}
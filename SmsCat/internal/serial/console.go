@@ -0,0 +1,15 @@
+package serial
+
+// RunCommand sends a command typed by an operator, under the modem mutex
+// so it never interleaves with SMSCat's own traffic, and returns the full
+// response. A final result other than OK is returned together with its
+// response as an *ATError. The port is opened first if needed.
+func (g *GSMModem) RunCommand(cmd string) (*Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.connect(); err != nil {
+		return nil, err
+	}
+	return g.exec(cmd)
+}
@@ -0,0 +1,65 @@
+package serial
+
+import (
+	"context"
+	"time"
+)
+
+// Modem is the behaviour the monitor and the app bindings rely on.
+// GSMModem is the production implementation; tests can drive a GSMModem
+// over a Simulator or provide their own fake.
+type Modem interface {
+	// Connect opens the port and runs the init sequence. It is a no-op
+	// when the modem is already connected.
+	Connect() error
+	// SendSMS sends a text message, splitting it into segments as needed,
+	// and returns the message reference of each segment sent. It gives up
+	// when ctx is done.
+	SendSMS(ctx context.Context, number string, text string, opts SendOptions) ([]int, error)
+	// Close releases the port. The next SendSMS reconnects.
+	Close()
+	// Ping checks that the modem answers AT, connecting if needed.
+	Ping() error
+	// Reset reboots the modem (AT+CFUN=1,1) and closes the port.
+	Reset() error
+	// IsConnected reports whether the port is currently open.
+	IsConnected() bool
+	// Port returns the port name the modem is bound to.
+	Port() string
+	// Info returns the modem and SIM identity read at the last Connect,
+	// or nil before the first one.
+	Info() *ModemInfo
+	// ListMessages reads and decodes all messages in SIM storage.
+	ListMessages() ([]InboundMessage, error)
+	// DeleteMessage removes a message from SIM storage by index.
+	DeleteMessage(index int) error
+	// ListStored lists every stored message with its status and age.
+	ListStored() ([]StoredMessage, error)
+	// QueryStorage reports the selected message storages and fill levels.
+	QueryStorage() (*StorageStatus, error)
+	// SetStorage selects the message storage used from now on.
+	SetStorage(mem string) error
+	// SIMStatus reports the SIM lock state and remaining attempts.
+	SIMStatus() (*SIMStatus, error)
+	// UnlockSIM enters a PIN, or a PUK and new PIN, then connects.
+	UnlockSIM(code string, newPIN string) error
+	// QuerySMSC reads the service centre address stored on the SIM.
+	QuerySMSC() (string, error)
+	// SetSMSC stores a service centre address on the SIM.
+	SetSMSC(number string) error
+	// Call places a voice call and reports how it ended. It hangs up
+	// when ctx is done.
+	Call(ctx context.Context, number string, opts CallOptions) (*CallResult, error)
+	// USSD sends a USSD request and returns the network's answer.
+	USSD(code string) (*USSDReply, error)
+	// QueryNetwork samples signal quality, registration and operator.
+	QueryNetwork() (*NetworkStatus, error)
+	// Clock reads the modem's network-synchronised clock.
+	Clock() (time.Time, error)
+	// RunCommand sends an operator's command and returns the response.
+	RunCommand(cmd string) (*Response, error)
+	// OnURC registers a handler for unsolicited result codes.
+	OnURC(prefix string, withBody bool, h URCHandler)
+}
+
+var _ Modem = (*GSMModem)(nil)
@@ -0,0 +1,95 @@
+package serial
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ModemInfo identifies a modem and the SIM in it. Values the modem does
+// not report are empty.
+type ModemInfo struct {
+	Port     string
+	Model    string // AT+CGMM
+	Revision string // firmware revision, AT+CGMR
+	IMEI     string // AT+CGSN
+	IMSI     string // AT+CIMI
+	ICCID    string // SIM card serial number, AT+QCCID/AT+CCID
+	Number   string // own number stored on the SIM (AT+CNUM), often empty
+	Time     time.Time
+}
+
+func (i *ModemInfo) String() string {
+	return fmt.Sprintf("model %s, firmware %s, IMEI %s, IMSI %s, ICCID %s, number %s",
+		orUnknown(i.Model), orUnknown(i.Revision), orUnknown(i.IMEI),
+		orUnknown(i.IMSI), orUnknown(i.ICCID), orUnknown(i.Number))
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "?"
+	}
+	return s
+}
+
+// Info returns the inventory read at the last Connect, or nil when the
+// modem has not been connected yet. It does not talk to the modem.
+func (g *GSMModem) Info() *ModemInfo {
+	g.infoMu.Lock()
+	defer g.infoMu.Unlock()
+	if g.info == nil {
+		return nil
+	}
+	info := *g.info
+	return &info
+}
+
+// readInfo queries the identification commands and caches the result.
+// Commands the modem rejects leave their field empty.
+func (g *GSMModem) readInfo() {
+	info := &ModemInfo{Port: g.PortName, Time: time.Now()}
+	info.Model = g.queryValue("AT+CGMM")
+	info.Revision = g.queryValue("AT+CGMR")
+	info.IMEI = g.queryValue("AT+CGSN")
+	info.IMSI = g.queryValue("AT+CIMI")
+
+	// The vendor command first: not every modem implements AT+CCID
+	commands := []string{"AT+CCID"}
+	if cmd := g.profile().ICCIDCommand; cmd != "" {
+		commands = []string{cmd, "AT+CCID"}
+	}
+	for _, cmd := range commands {
+		if iccid := g.queryValue(cmd); iccid != "" {
+			// Some modems pad the 19-digit ICCID with F to 20 nibbles
+			info.ICCID = strings.TrimRight(iccid, "Ff")
+			break
+		}
+	}
+
+	// +CNUM: "<alpha>","<number>",<type>; one line per stored number
+	if resp, err := g.exec("AT+CNUM"); err == nil {
+		if fields := splitInfoLine(resp.Line("+CNUM:"), "+CNUM:"); len(fields) >= 2 {
+			info.Number = g.decodeString(strings.Trim(fields[1], "\""))
+		}
+	}
+
+	g.infoMu.Lock()
+	g.info = info
+	g.infoMu.Unlock()
+	g.log(fmt.Sprintf("Modem info: %s", info), true)
+}
+
+// queryValue runs an identification command and returns its single value,
+// without a "+CGSN:" style label or quotes. It returns "" on error.
+func (g *GSMModem) queryValue(cmd string) string {
+	resp, err := g.exec(cmd)
+	if err != nil || len(resp.Lines) == 0 {
+		return ""
+	}
+	value := strings.TrimSpace(resp.Lines[0])
+	// "+QCCID: 8986...", "^ICCID: ...", "Revision: EC25EFAR06A03M4G"
+	if i := strings.Index(value, ":"); i > 0 && !strings.Contains(value[:i], " ") {
+		value = strings.TrimSpace(value[i+1:])
+	}
+	return strings.Trim(value, "\"")
+}
@@ -0,0 +1,153 @@
+package serial
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// DefaultReadTimeout bounds a read from a physical port with no data.
+const DefaultReadTimeout = 3 * time.Second
+
+// autobaudRates are tried in order when the modem does not answer at
+// BaudRate: the USB default first, then what external RS-232 modems
+// commonly ship with.
+var autobaudRates = []int{115200, 9600, 57600, 38400, 19200, 4800, 2400, 230400, 460800, 921600}
+
+// LineConfig is the serial line setup of a physical port. The zero value
+// is 8N1 without flow control and the default timeouts.
+type LineConfig struct {
+	DataBits    int    // 5 to 8; 0 means 8
+	Parity      string // N, E, O, M or S; "" means N
+	StopBits    string // "1", "1.5" or "2"; "" means 1
+	FlowControl string // "none", "rtscts" or "xonxoff"; "" means none
+	// ReadTimeout bounds a read with no data; 0 means DefaultReadTimeout.
+	ReadTimeout time.Duration
+	// CommandTimeout raises the response timeout of every command to at
+	// least this, for slow lines. 0 keeps the per-command timeouts.
+	CommandTimeout time.Duration
+}
+
+func (l LineConfig) String() string {
+	bits, parity, stop := l.DataBits, l.Parity, l.StopBits
+	if bits == 0 {
+		bits = 8
+	}
+	if parity == "" {
+		parity = "N"
+	}
+	if stop == "" {
+		stop = "1"
+	}
+	s := fmt.Sprintf("%d%s%s", bits, strings.ToUpper(parity), stop)
+	if l.FlowControl != "" && l.FlowControl != "none" {
+		s += " " + l.FlowControl
+	}
+	return s
+}
+
+// config translates l into the options of the serial driver.
+func (l LineConfig) config(name string, baud int) (*serial.Config, error) {
+	c := &serial.Config{Name: name, Baud: baud, ReadTimeout: l.ReadTimeout, Size: byte(l.DataBits)}
+	if c.ReadTimeout <= 0 {
+		c.ReadTimeout = DefaultReadTimeout
+	}
+	if l.DataBits != 0 && (l.DataBits < 5 || l.DataBits > 8) {
+		return nil, fmt.Errorf("unsupported data bits %d", l.DataBits)
+	}
+	switch strings.ToUpper(l.Parity) {
+	case "", "N":
+		c.Parity = serial.ParityNone
+	case "E":
+		c.Parity = serial.ParityEven
+	case "O":
+		c.Parity = serial.ParityOdd
+	case "M":
+		c.Parity = serial.ParityMark
+	case "S":
+		c.Parity = serial.ParitySpace
+	default:
+		return nil, fmt.Errorf("unsupported parity %q", l.Parity)
+	}
+	switch l.StopBits {
+	case "", "1":
+		c.StopBits = serial.Stop1
+	case "1.5":
+		c.StopBits = serial.Stop1Half
+	case "2":
+		c.StopBits = serial.Stop2
+	default:
+		return nil, fmt.Errorf("unsupported stop bits %q", l.StopBits)
+	}
+	switch l.FlowControl {
+	case "", "none", "rtscts", "xonxoff":
+	default:
+		return nil, fmt.Errorf("unsupported flow control %q", l.FlowControl)
+	}
+	return c, nil
+}
+
+// openSerialPort is the default OpenFunc: it opens a local serial port,
+// or connects to a tcp:// or rfc2217:// device server, with the modem's
+// LineConfig.
+func (g *GSMModem) openSerialPort(name string, baud int) (io.ReadWriteCloser, error) {
+	if IsNetworkPort(name) {
+		return openNetPort(name, baud, g.Line)
+	}
+	c, err := g.Line.config(name, baud)
+	if err != nil {
+		return nil, err
+	}
+	p, err := serial.OpenPort(c)
+	if err != nil {
+		return nil, err
+	}
+	// The driver only knows 8N1-style options; flow control is set on
+	// the open port
+	if flow := g.Line.FlowControl; flow != "" && flow != "none" {
+		if err := setFlowControl(p, name, flow); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("flow control %s: %w", flow, err)
+		}
+	}
+	return p, nil
+}
+
+// timeoutFor returns the response timeout for cmd on this modem's line.
+func (g *GSMModem) timeoutFor(cmd string) time.Duration {
+	d := timeoutFor(cmd)
+	if d < g.Line.CommandTimeout {
+		d = g.Line.CommandTimeout
+	}
+	return d
+}
+
+// autobaud looks for the rate the modem answers at, after it stayed silent
+// at BaudRate. Every rate gets two AT: a modem detecting the rate itself
+// may swallow the first. On success the port stays open at the new rate,
+// which is kept in BaudRate for the next connect.
+func (g *GSMModem) autobaud() bool {
+	tried := g.BaudRate
+	for _, rate := range autobaudRates {
+		if rate == tried {
+			continue
+		}
+		g.detach()
+		if err := g.attach(rate); err != nil {
+			g.log(fmt.Sprintf("Autobaud: cannot open at %d: %v", rate, err), true)
+			continue
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := g.execTimeout("AT", 500*time.Millisecond); err == nil {
+				g.log(fmt.Sprintf("Autobaud: modem answers at %d baud", rate), false)
+				g.BaudRate = rate
+				return true
+			}
+		}
+	}
+	g.detach()
+	return false
+}
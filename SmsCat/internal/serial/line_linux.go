@@ -0,0 +1,35 @@
+//go:build linux
+
+package serial
+
+import (
+	"os"
+
+	"github.com/tarm/serial"
+	"golang.org/x/sys/unix"
+)
+
+// setFlowControl enables flow on the tty behind name. Termios settings
+// belong to the device, not the descriptor, so a second descriptor can
+// change them for the port the driver opened.
+func setFlowControl(_ *serial.Port, name string, flow string) error {
+	f, err := os.OpenFile(name, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fd := int(f.Fd())
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	switch flow {
+	case "rtscts":
+		t.Cflag |= unix.CRTSCTS
+	case "xonxoff":
+		t.Iflag |= unix.IXON | unix.IXOFF
+		t.Cc[unix.VSTART] = 0x11
+		t.Cc[unix.VSTOP] = 0x13
+	}
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
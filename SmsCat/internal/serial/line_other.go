@@ -0,0 +1,15 @@
+//go:build !windows && !linux
+
+package serial
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/tarm/serial"
+)
+
+// setFlowControl is not implemented on this platform.
+func setFlowControl(_ *serial.Port, _ string, flow string) error {
+	return fmt.Errorf("not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,46 @@
+//go:build windows
+
+package serial
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/tarm/serial"
+	"golang.org/x/sys/windows"
+)
+
+// DCB flag bits not named in x/sys/windows.
+const (
+	dcbOutxCtsFlow = 0x0004
+	dcbOutX        = 0x0100
+	dcbInX         = 0x0200
+	dcbRtsControl  = 0x3000
+)
+
+// setFlowControl enables flow on the open port. A COM port cannot be
+// opened twice, so the handle is taken from the driver's Port, which does
+// not export it.
+func setFlowControl(p *serial.Port, _ string, flow string) error {
+	fd := reflect.ValueOf(p).Elem().FieldByName("fd")
+	if !fd.IsValid() {
+		return fmt.Errorf("serial driver does not expose its handle")
+	}
+	h := windows.Handle(fd.Uint())
+
+	var dcb windows.DCB
+	if err := windows.GetCommState(h, &dcb); err != nil {
+		return err
+	}
+	switch flow {
+	case "rtscts":
+		dcb.Flags |= dcbOutxCtsFlow
+		dcb.Flags = dcb.Flags&^dcbRtsControl | windows.RTS_CONTROL_HANDSHAKE
+	case "xonxoff":
+		// The driver sets up 64-byte queues; pause well before they fill
+		dcb.Flags |= dcbOutX | dcbInX
+		dcb.XonChar, dcb.XoffChar = 0x11, 0x13
+		dcb.XonLim, dcb.XoffLim = 16, 16
+	}
+	return windows.SetCommState(h, &dcb)
+}
@@ -1,35 +1,95 @@
 package serial
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
-	"unicode/utf16"
 
-	"github.com/tarm/serial"
-	"golang.org/x/sys/windows/registry"
-)
-
-const (
-	QuectelVID = "VID_2C7C"
+	"smallNfast/internal/pdu"
 )
 
 type GSMModem struct {
 	PortName string
 	BaudRate int
-	port     *serial.Port
+	port     io.ReadWriteCloser
+	at       *atChannel
 	mu       sync.Mutex
 	LogFunc  func(string, bool)
+
+	// connected mirrors port != nil for IsConnected, which must not wait
+	// for a command holding mu
+	connected atomic.Bool
+
+	urcMu sync.Mutex
+	urcs  []urcEntry
+
+	// ConcatRefs numbers the parts of long messages per recipient. Nil
+	// shares a counter kept in memory.
+	ConcatRefs *ConcatRefs
+	// Concat16 uses the 16-bit concatenation reference (IEI 08) instead
+	// of the 8-bit one.
+	Concat16 bool
+
+	// PIN is entered when the SIM asks for one. A PIN the SIM rejected is
+	// not retried until it changes or UnlockSIM succeeds.
+	PIN         string
+	rejectedPIN string
+
+	// charset is the TE character set selected by the init script
+	// (AT+CSCS); string parameters are encoded in it.
+	charset string
+
+	// SMSC overrides the service centre stored on the SIM by encoding it
+	// into every PDU. Empty uses the SIM's AT+CSCA setting.
+	SMSC string
+
+	// Profile selects the init script and send quirks. Nil means
+	// QuectelProfile.
+	Profile *Profile
+
+	// Storage is the message storage (SM, ME, MT) selected with AT+CPMS
+	// after init. Empty keeps the modem's default.
+	Storage string
+
+	// info is the inventory read at the last connect
+	infoMu sync.Mutex
+	info   *ModemInfo
+
+	// Line sets data bits, parity, stop bits, flow control and timeouts
+	// of a physical port.
+	Line LineConfig
+	// AutoBaud tries the common rates when the modem does not answer at
+	// BaudRate.
+	AutoBaud bool
+
+	// OpenFunc opens the underlying transport. It defaults to opening a
+	// local serial port with Line; tests replace it to plug in a Simulator.
+	OpenFunc func(name string, baud int) (io.ReadWriteCloser, error)
 }
 
 func NewGSMModem(port string, logFunc func(string, bool)) *GSMModem {
-	return &GSMModem{
+	g := &GSMModem{
 		PortName: port,
 		BaudRate: 115200,
 		LogFunc:  logFunc,
 	}
+	g.OpenFunc = g.openSerialPort
+	return g
+}
+
+// profile returns the active profile, defaulting to Quectel.
+func (g *GSMModem) profile() *Profile {
+	if g.Profile == nil {
+		return QuectelProfile
+	}
+	return g.Profile
 }
 
 func (g *GSMModem) log(msg string, verbose bool) {
@@ -42,169 +102,179 @@ func (g *GSMModem) log(msg string, verbose bool) {
 
 // Connect opens the serial port and initializes the modem
 func (g *GSMModem) Connect() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.connect()
+}
+
+func (g *GSMModem) connect() error {
+	g.dropLost()
 	// If already open, do nothing
 	if g.port != nil {
 		return nil
 	}
+	if err := g.open(); err != nil {
+		return err
+	}
 
-	g.log(fmt.Sprintf("Connecting to port %s...", g.PortName), false)
-	c := &serial.Config{Name: g.PortName, Baud: g.BaudRate, ReadTimeout: time.Second * 3}
-	s, err := serial.OpenPort(c)
-	if err != nil {
+	// 4. Check SIM, entering the configured PIN if asked for
+	if err := g.unlockSIM(); err != nil {
+		g.close()
+		return err
+	}
+	// 5. Vendor-specific setup
+	profile := g.profile()
+	g.log(fmt.Sprintf("Initializing as %s modem", profile), true)
+	for _, step := range profile.Init {
+		_, err := g.exec(step.Command)
+		if err == nil && strings.HasPrefix(step.Command, "AT+CSCS=") {
+			g.charset = strings.Trim(strings.TrimPrefix(step.Command, "AT+CSCS="), "\"")
+		}
+		if err != nil {
+			if step.Required {
+				g.close()
+				return fmt.Errorf("init %s failed: %w", step.Command, err)
+			}
+			g.log(fmt.Sprintf("Warning: %s failed", strings.TrimPrefix(strings.TrimPrefix(step.Command, "AT"), "+")), true)
+		}
+	}
+	// 6. Preferred message storage
+	if err := g.selectStorage(); err != nil {
+		g.log(fmt.Sprintf("Warning: %v", err), false)
+	}
+	// 7. Which stick and SIM this is
+	g.readInfo()
+
+	return nil
+}
+
+// open opens the port and runs the handshake that works regardless of
+// the SIM state.
+func (g *GSMModem) open() error {
+	g.log(fmt.Sprintf("Connecting to port %s (%d baud, %s)...", g.PortName, g.BaudRate, g.Line), false)
+	if err := g.attach(g.BaudRate); err != nil {
 		g.log(fmt.Sprintf("Failed to open port: %v", err), false)
 		return fmt.Errorf("failed to open port %s: %w", g.PortName, err)
 	}
-	g.port = s
 	g.log("Port opened successfully.", false)
 
 	// Initialization Sequence (Aligned with auto_test.py)
 	// 1. Simple Handshake
-	if err := g.sendCommand("AT", "OK"); err != nil {
-		g.Close()
-		return fmt.Errorf("modem check failed: %w", err)
+	if _, err := g.exec("AT"); err != nil {
+		if !g.AutoBaud || !g.autobaud() {
+			g.close()
+			return fmt.Errorf("modem check failed: %w", err)
+		}
 	}
 	// 2. Disable Echo
-	if err := g.sendCommand("ATE0", "OK"); err != nil {
+	if _, err := g.exec("ATE0"); err != nil {
 		g.log("Warning: ATE0 failed", true)
 	}
 	// 3. Verbose Errors
-	if err := g.sendCommand("AT+CMEE=2", "OK"); err != nil {
+	if _, err := g.exec("AT+CMEE=2"); err != nil {
 		g.log("Warning: CMEE=2 failed", true)
 	}
-	// 4. Check SIM
-	if err := g.sendCommand("AT+CPIN?", "READY"); err != nil {
-		g.Close()
-		return fmt.Errorf("SIM not ready: %w", err)
-	}
-	// 5. Text Mode
-	if err := g.sendCommand("AT+CMGF=1", "OK"); err != nil {
-		g.Close()
-		return fmt.Errorf("failed to set text mode: %w", err)
-	}
-	// 6. Character Set
-	if err := g.sendCommand("AT+CSCS=\"UCS2\"", "OK"); err != nil {
-		g.log("Warning: CSCS=UCS2 failed", true)
-	}
-	// 7. Text Mode Parameters (Unicode/Class 0)
-	if err := g.sendCommand("AT+CSMP=17,167,0,8", "OK"); err != nil {
-		g.log("Warning: CSMP=17,167,0,8 failed", true)
+	return nil
+}
+
+// attach opens the transport at baud and starts its reader.
+func (g *GSMModem) attach(baud int) error {
+	open := g.OpenFunc
+	if open == nil {
+		open = g.openSerialPort
 	}
-	// 8. Prefer Packet Domain
-	if err := g.sendCommand("AT+CGSMS=2", "OK"); err != nil {
-		g.log("Warning: CGSMS=2 failed", true)
+	s, err := open(g.PortName, baud)
+	if err != nil {
+		return err
 	}
-
+	g.port = s
+	g.connected.Store(true)
+	g.at = g.startReader(s)
+	g.charset = ""
 	return nil
 }
 
 // Close closes the serial port
 func (g *GSMModem) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.close()
+}
+
+func (g *GSMModem) close() {
 	if g.port != nil {
 		g.log("Closing modem port.", false)
-		g.port.Close()
-		g.port = nil
+		g.detach()
 	}
 }
 
-// flushInput reads everything currently in the buffer to avoid stale data
-func (g *GSMModem) flushInput() {
+// detach stops the reader and closes the transport.
+func (g *GSMModem) detach() {
 	if g.port == nil {
 		return
 	}
-	// "flush" is implicit in robust reading loops now
-}
-
-// sendCommand helper
-func (g *GSMModem) sendCommand(cmd string, expect string) error {
-	if g.port == nil {
-		return fmt.Errorf("port not open")
-	}
-
-	g.log(fmt.Sprintf("CMD: %s", cmd), true)
-	_, err := g.port.Write([]byte(cmd + "\r"))
-	if err != nil {
-		g.log(fmt.Sprintf("Write Error: %v", err), false)
-		return err
-	}
-	time.Sleep(200 * time.Millisecond)
-
-	buf := make([]byte, 1024)
-	n, err := g.port.Read(buf)
-	if err != nil {
-		// Log but don't fail immediately, check if what we got matches
-		g.log(fmt.Sprintf("Read Error (or timeout): %v", err), true)
-	}
-
-	// Read Loop (Simple version for commands: just one read is usually enough after sleep)
-	// For SendSMS we will do robust loop. Here we keep it simple for Init.
-	response := string(buf[:n])
-
-	// Log simplified
-	logResp := strings.ReplaceAll(response, "\r", "")
-	logResp = strings.ReplaceAll(logResp, "\n", " ")
-	if len(logResp) > 100 {
-		logResp = logResp[:100] + "..."
-	}
-	g.log(fmt.Sprintf("RESP: %s", logResp), true)
-
-	if !strings.Contains(response, expect) {
-		return fmt.Errorf("unexpected response: %s", response)
+	if g.at != nil {
+		g.at.stop()
+		g.at = nil
 	}
-	return nil
+	g.port.Close()
+	g.port = nil
+	g.connected.Store(false)
 }
 
-// encodeUCS2 converts a string to UCS2 Hex string (Big Endian)
-func encodeUCS2(s string) string {
-	runes := []rune(s)
-	var sb strings.Builder
-	for _, r := range runes {
-		sb.WriteString(fmt.Sprintf("%04X", r))
+// dropLost closes the port when its reader ended on a transport error,
+// e.g. a device server dropping the connection, so that the caller's
+// usual "open if closed" reconnects.
+func (g *GSMModem) dropLost() {
+	if g.port != nil && g.at != nil && g.at.stopped() {
+		g.log("Connection to the modem lost, reconnecting...", false)
+		g.close()
 	}
-	return sb.String()
 }
 
-// encodeAddress formats phone number for GSM PDU destination address field.
-func encodeAddress(number string) (string, error) {
-	// Strip spaces, dashes, parentheses
-	number = strings.ReplaceAll(number, " ", "")
-	number = strings.ReplaceAll(number, "-", "")
-	number = strings.ReplaceAll(number, "(", "")
-	number = strings.ReplaceAll(number, ")", "")
-
-	isInternational := strings.HasPrefix(number, "+")
-	if isInternational {
-		number = number[1:]
-	}
-
-	// Ensure all characters are digits
-	for _, r := range number {
-		if r < '0' || r > '9' {
-			return "", fmt.Errorf("invalid characters in phone number: %s", number)
-		}
-	}
-
-	length := len(number)
-	lengthHex := fmt.Sprintf("%02X", length)
+// IsConnected reports whether the modem port is currently open.
+func (g *GSMModem) IsConnected() bool {
+	return g.connected.Load()
+}
 
-	typeOfAddress := "81"
-	if isInternational {
-		typeOfAddress = "91"
-	}
+// Port returns the name of the port the modem is bound to.
+func (g *GSMModem) Port() string {
+	return g.PortName
+}
 
-	// Pad if odd length
-	padded := number
-	if length%2 != 0 {
-		padded += "F"
-	}
+// defaultValidity keeps undelivered messages at the SMSC for 27 days
+// (relative TP-VP C1).
+var defaultValidity = pdu.RelativeValidity(27 * 24 * time.Hour)
+
+// SendOptions controls one message sent with SendSMS. The zero value sends
+// a normal message kept by the SMSC for 27 days, without status reports.
+type SendOptions struct {
+	// Validity is how long the SMSC keeps trying to deliver the message
+	// (rounded up to the next relative TP-VP step, at most 63 weeks).
+	// ValidUntil, when set, gives an absolute end instead.
+	Validity   time.Duration
+	ValidUntil time.Time
+	// Flash sends a class 0 message, shown at once without being stored.
+	Flash bool
+	// StatusReport sets TP-SRR so the SMSC answers every segment with an
+	// SMS-STATUS-REPORT (+CDS).
+	StatusReport bool
+	// ReplyPath asks the recipient's reply to go through our SMSC.
+	ReplyPath bool
+	// PID is the protocol identifier, e.g. pdu.PIDReplaceType1 to have the
+	// message replace the previous one of that type on the handset.
+	PID byte
+}
 
-	// Swap nibbles
-	var sb strings.Builder
-	for i := 0; i < len(padded); i += 2 {
-		sb.WriteByte(padded[i+1])
-		sb.WriteByte(padded[i])
+// validity returns the TP-VP for the options.
+func (o SendOptions) validity() pdu.ValidityPeriod {
+	switch {
+	case !o.ValidUntil.IsZero():
+		return pdu.AbsoluteValidity(o.ValidUntil)
+	case o.Validity > 0:
+		return pdu.RelativeValidity(o.Validity)
 	}
-
-	return lengthHex + typeOfAddress + sb.String(), nil
+	return defaultValidity
 }
 
 type pduSegment struct {
@@ -212,107 +282,121 @@ type pduSegment struct {
 	length    int
 }
 
-// textToPDUSegments prepares raw PDU segments for sending long or short SMS using UCS2.
-// It uses First Octet 11 (single) / 51 (concatenated) with Relative Validity Period C1 (27 days).
-func textToPDUSegments(number string, text string) ([]pduSegment, error) {
-	addrPDU, err := encodeAddress(number)
-	if err != nil {
-		return nil, err
-	}
-
-	utf16Vals := utf16.Encode([]rune(text))
-	totalRunes := len(utf16Vals)
-
-	// If it fits in a single SMS (<= 70 characters)
-	if totalRunes <= 70 {
-		var udStrings []string
-		for _, val := range utf16Vals {
-			udStrings = append(udStrings, fmt.Sprintf("%04X", val))
-		}
-		udHex := strings.Join(udStrings, "")
-		udl := len(udHex) / 2 // number of bytes
-
-		// PDU = SMSC(00) + FirstOctet(11) + MR(00) + DestAddr + PID(00) + DCS(08) + VP(C1) + UDL(hex) + UD(hex)
-		pdu := fmt.Sprintf("001100%s0008C1%02X%s", addrPDU, udl, udHex)
-		atLength := (len(pdu) / 2) - 1
-
-		return []pduSegment{{pduString: pdu, length: atLength}}, nil
-	}
-
-	// Concatenated SMS: max 67 characters per segment (leaves 6 bytes for UDH in the 140 bytes limit)
+// textToPDUSegments prepares the SMS-SUBMIT PDUs for sending text to number,
+// one per part, concatenated as configured by concat when the text does
+// not fit one SMS. A non-empty smsc is encoded into each PDU instead of
+// using the SMSC stored on the SIM.
+func textToPDUSegments(smsc string, number string, text string, concat pdu.Concat, opts SendOptions) ([]pduSegment, error) {
 	var segments []pduSegment
-	refNum := uint8(time.Now().Unix() & 0xFF) // Random reference number between 0 and 255
-
-	chunkSize := 67
-	var chunks [][]uint16
-	for i := 0; i < len(utf16Vals); i += chunkSize {
-		end := i + chunkSize
-		if end > len(utf16Vals) {
-			end = len(utf16Vals)
+	for _, part := range pdu.SplitText(text, concat) {
+		if opts.Flash {
+			part.SetClass(0)
 		}
-		chunks = append(chunks, utf16Vals[i:end])
-	}
-
-	totalParts := len(chunks)
-
-	for idx, chunk := range chunks {
-		partNum := idx + 1
-		// Construct UDH: 05 00 03 XX YY ZZ
-		// XX = refNum, YY = totalParts, ZZ = partNum
-		udh := fmt.Sprintf("050003%02X%02X%02X", refNum, totalParts, partNum)
-
-		var udStrings []string
-		for _, val := range chunk {
-			udStrings = append(udStrings, fmt.Sprintf("%04X", val))
+		submit := pdu.Submit{
+			SMSC:                smsc,
+			ReplyPath:           opts.ReplyPath,
+			StatusReportRequest: opts.StatusReport,
+			Recipient:           number,
+			PID:                 opts.PID,
+			Validity:            opts.validity(),
+			UserData:            part,
 		}
-		udHex := udh + strings.Join(udStrings, "")
-		udl := len(udHex) / 2 // number of bytes, including UDH
-
-		// PDU = SMSC(00) + FirstOctet(51) [with TP-UDHI set] + MR(00) + DestAddr + PID(00) + DCS(08) + VP(C1) + UDL(hex) + UD(hex)
-		pdu := fmt.Sprintf("005100%s0008C1%02X%s", addrPDU, udl, udHex)
-		atLength := (len(pdu) / 2) - 1
-
-		segments = append(segments, pduSegment{pduString: pdu, length: atLength})
+		pduHex, length, err := submit.Encode()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, pduSegment{pduString: pduHex, length: length})
 	}
-
 	return segments, nil
 }
 
+// concat returns the concatenation settings for a message to number,
+// drawing its reference from the modem's counters.
+func (g *GSMModem) concat(number string) pdu.Concat {
+	refs := g.ConcatRefs
+	if refs == nil {
+		refs = defaultConcatRefs
+	}
+	return pdu.Concat{
+		Wide: g.Concat16,
+		Ref: func() int {
+			ref, err := refs.Next(number)
+			if err != nil {
+				g.log(fmt.Sprintf("SMS: could not save concatenation reference: %v", err), false)
+			}
+			return ref
+		},
+	}
+}
+
 // SendSMS sends a text message to the specified number.
 // It automatically handles message encoding and splitting/concatenation via PDU Mode.
-func (g *GSMModem) SendSMS(number string, text string) error {
+// It returns the TP-Message-Reference of every segment accepted by the SMSC;
+// on failure the references of the segments sent so far are returned too.
+// Cancelling ctx abandons the segment in flight: one still waiting for its
+// prompt is cancelled with ESC, one already submitted has an unknown result
+// and its error wraps ErrSubmitted, as after a timeout waiting for +CMGS.
+func (g *GSMModem) SendSMS(ctx context.Context, number string, text string, opts SendOptions) ([]int, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Ensure connected
+	g.dropLost()
 	if g.port == nil {
 		g.log("SMS: modem not connected, connecting...", false)
-		if err := g.Connect(); err != nil {
-			return err
+		if err := g.connect(); err != nil {
+			return nil, err
 		}
 	}
 
-	// Fail helper — closes port so next call reconnects fresh
+	// reject reports a failure that left the channel in sync: an error
+	// reply from the modem, or one found before anything was sent
+	reject := func(err error) error {
+		g.log(fmt.Sprintf("SMS FAILED: %v", err), false)
+		return err
+	}
+	// fail also closes the port, after a transport error or a timeout,
+	// so the next call reconnects fresh
 	fail := func(err error) error {
+		var atErr *ATError
+		if errors.As(err, &atErr) {
+			return reject(err)
+		}
 		g.log(fmt.Sprintf("SMS FAILED: %v", err), false)
-		g.Close()
+		g.close()
 		return err
 	}
 
 	// Prepare PDU segments (concatenated or single)
-	segments, err := textToPDUSegments(number, text)
+	segments, err := textToPDUSegments(g.SMSC, number, text, g.concat(number), opts)
 	if err != nil {
-		return fail(fmt.Errorf("failed to prepare PDU: %w", err))
+		return nil, reject(fmt.Errorf("failed to prepare PDU: %w", err))
 	}
 
 	msgLen := len([]rune(text))
-	g.log(fmt.Sprintf("SMS → %s | %d chars | %d PDU segment(s)", number, msgLen, len(segments)), false)
+	alphabet := pdu.AlphabetUCS2
+	if _, ok := pdu.EncodeGSM7(text); ok {
+		alphabet = pdu.AlphabetGSM7
+	}
+	flash := ""
+	if opts.Flash {
+		flash = " | flash"
+	}
+	g.log(fmt.Sprintf("SMS → %s | %d chars | %s | %d PDU segment(s) | valid %s%s", number, msgLen, alphabet, len(segments), opts.validity(), flash), false)
 
 	// Set modem to PDU mode (0)
-	if err := g.sendCommand("AT+CMGF=0", "OK"); err != nil {
-		return fail(fmt.Errorf("failed to set PDU mode: %w", err))
+	if _, err := g.exec("AT+CMGF=0"); err != nil {
+		return nil, fail(fmt.Errorf("failed to set PDU mode: %w", err))
 	}
 
+	quirks := g.profile().Quirks
+	if quirks.PromptTimeout == 0 {
+		quirks.PromptTimeout = 5 * time.Second
+	}
+	var refs []int
 	for i, seg := range segments {
 		partLabel := ""
 		if len(segments) > 1 {
@@ -321,164 +405,45 @@ func (g *GSMModem) SendSMS(number string, text string) error {
 
 		g.log(fmt.Sprintf("SMS%s: PDU hex = %s (Length parameter = %d)", partLabel, seg.pduString, seg.length), false)
 
-		// Send CMGS command with length in octets excluding SMSC byte
+		// Send CMGS command with length in octets excluding SMSC byte,
+		// then the PDU once the '>' prompt arrives
 		cmd := fmt.Sprintf("AT+CMGS=%d", seg.length)
-		g.log(fmt.Sprintf("CMD: %s", cmd), true)
-		if _, err := g.port.Write([]byte(cmd + "\r")); err != nil {
-			return fail(fmt.Errorf("write CMGS%s failed: %w", partLabel, err))
-		}
-
-		// Wait for '>' prompt
-		gotPrompt := false
-		start := time.Now()
-		for time.Since(start) < 5*time.Second {
-			buf := make([]byte, 128)
-			n, _ := g.port.Read(buf)
-			if n > 0 {
-				chunk := string(buf[:n])
-				if strings.Contains(chunk, ">") {
-					gotPrompt = true
-					g.log(fmt.Sprintf("SMS%s: got '>' prompt, sending PDU data...", partLabel), false)
-					break
-				}
-				if strings.Contains(chunk, "ERROR") {
-					return fail(fmt.Errorf("error before prompt%s: %s", partLabel, chunk))
-				}
-			}
-			time.Sleep(100 * time.Millisecond)
-		}
-		if !gotPrompt {
-			return fail(fmt.Errorf("timeout waiting for '>' prompt%s", partLabel))
-		}
-
-		// Send PDU hex string
-		if _, err := g.port.Write([]byte(seg.pduString)); err != nil {
-			return fail(fmt.Errorf("write PDU data%s failed: %w", partLabel, err))
-		}
-		// Send Ctrl+Z
-		if _, err := g.port.Write([]byte{26}); err != nil {
-			return fail(fmt.Errorf("write Ctrl+Z%s failed: %w", partLabel, err))
+		sendTimeout := quirks.SendTimeout
+		if sendTimeout == 0 {
+			sendTimeout = g.timeoutFor(cmd)
 		}
-
-		// Wait for +CMGS confirmation
-		g.log(fmt.Sprintf("SMS%s: waiting for network confirmation...", partLabel), false)
-		startWait := time.Now()
-		sentOk := false
-		for time.Since(startWait) < 30*time.Second {
-			buf := make([]byte, 256)
-			n, _ := g.port.Read(buf)
-			if n > 0 {
-				resp := string(buf[:n])
-				logResp := strings.ReplaceAll(strings.ReplaceAll(resp, "\r", ""), "\n", " ")
-				g.log(fmt.Sprintf("SMS RX%s: %s", partLabel, logResp), false)
-				if strings.Contains(resp, "+CMGS:") {
-					g.log(fmt.Sprintf("SMS%s: sent OK ✓", partLabel), false)
-					sentOk = true
-					break
-				}
-				if strings.Contains(resp, "ERROR") {
-					return fail(fmt.Errorf("modem rejected SMS%s: %s", partLabel, resp))
-				}
+		resp, err := g.execPrompt(ctx, cmd, seg.pduString, quirks.PromptTimeout, sendTimeout)
+		if err != nil {
+			var atErr *ATError
+			if errors.As(err, &atErr) {
+				return refs, reject(fmt.Errorf("modem rejected SMS%s: %w%s", partLabel, atErr, cmsHint(atErr.Final)))
 			}
-			time.Sleep(200 * time.Millisecond)
-		}
-
-		if !sentOk {
-			return fail(fmt.Errorf("timeout waiting for SMS confirmation%s", partLabel))
+			return refs, fail(fmt.Errorf("SMS%s: %w", partLabel, err))
 		}
+		mr := parseMessageRef(resp.Line("+CMGS:"))
+		refs = append(refs, mr)
+		g.log(fmt.Sprintf("SMS%s: sent OK ✓ (message reference %d)", partLabel, mr), false)
 
 		// Delay between segments
-		if i < len(segments)-1 {
-			g.log("SMS: waiting 2s before next part...", false)
-			time.Sleep(2 * time.Second)
+		if i < len(segments)-1 && quirks.SegmentDelay > 0 {
+			g.log(fmt.Sprintf("SMS: waiting %v before next part...", quirks.SegmentDelay), false)
+			select {
+			case <-ctx.Done():
+				return refs, reject(fmt.Errorf("SMS cancelled after part %d/%d: %w", i+1, len(segments), ctx.Err()))
+			case <-time.After(quirks.SegmentDelay):
+			}
 		}
 	}
 
-	return nil
-}
-
-// CheckAvailablePorts scans COM1-COM20 to find available serial ports
-// Note: This is a basic scanner.
-func CheckAvailablePorts() []string {
-	var available []string
-	for i := 1; i <= 20; i++ {
-		port := fmt.Sprintf("COM%d", i)
-		// Try to open
-		c := &serial.Config{Name: port, Baud: 115200, ReadTimeout: time.Millisecond * 100}
-		s, err := serial.OpenPort(c)
-		if err == nil {
-			available = append(available, port)
-			s.Close()
-		}
-	}
-	return available
+	return refs, nil
 }
 
-// FindModemPort attempts to locate the Quectel modem in the Windows Registry
-// looking for USB\VID_2C7C&PID_6002... and extracting the PortName.
-func FindModemPort() (string, error) {
-	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Enum\USB`, registry.ENUMERATE_SUB_KEYS|registry.READ)
-	if err != nil {
-		return "", fmt.Errorf("failed to open USB enum key: %w", err)
-	}
-	defer k.Close()
-
-	// 1. List all USB devices (looking for our VID/PID)
-	devices, err := k.ReadSubKeyNames(-1)
+// parseMessageRef extracts <mr> from "+CMGS: <mr>[,<scts>]", or -1.
+func parseMessageRef(line string) int {
+	fields := strings.Split(strings.TrimSpace(strings.TrimPrefix(line, "+CMGS:")), ",")
+	mr, err := strconv.Atoi(strings.TrimSpace(fields[0]))
 	if err != nil {
-		return "", err
-	}
-
-	for _, device := range devices {
-		// Check for our VID/PID
-		if strings.Contains(strings.ToUpper(device), QuectelVID) {
-
-			// Filter for MI_03 interface (AT command interface)
-			// The "MI_03" part is in the Device ID (Key Name), NOT the Instance ID.
-			// e.g. "VID_2C7C&PID_6002&MI_03"
-			if !strings.Contains(strings.ToUpper(device), "MI_03") {
-				continue
-			}
-
-			// Found the device type key, now look for instances
-			// Path: USB\<VID&PID>
-			deviceKeyPath := fmt.Sprintf(`SYSTEM\CurrentControlSet\Enum\USB\%s`, device)
-			dk, errKey := registry.OpenKey(registry.LOCAL_MACHINE, deviceKeyPath, registry.ENUMERATE_SUB_KEYS|registry.READ)
-			if errKey != nil {
-				continue
-			}
-			defer dk.Close()
-
-			instances, errInst := dk.ReadSubKeyNames(-1)
-			if errInst != nil {
-				continue
-			}
-
-			// We iterate all instances to find one with a PortName
-			for _, instance := range instances {
-				// Path: USB\<VID&PID>\<Instance>\Device Parameters
-				paramPath := fmt.Sprintf(`%s\%s\Device Parameters`, deviceKeyPath, instance)
-				pk, errPk := registry.OpenKey(registry.LOCAL_MACHINE, paramPath, registry.QUERY_VALUE)
-				if errPk != nil {
-					continue
-				}
-				portName, _, errVal := pk.GetStringValue("PortName")
-				pk.Close()
-
-				if errVal == nil && portName != "" {
-					// VERIFY: Attempt to open the port. If it fails (e.g. unplugged), skip it.
-					// This prevents selecting stale registry entries.
-					c := &serial.Config{Name: portName, Baud: 115200, ReadTimeout: time.Millisecond * 100}
-					s, _ := serial.OpenPort(c)
-					if s != nil {
-						s.Close()
-						return portName, nil
-					}
-					// Check failed (likely device unplugged but registry entry stuck), continue searching
-				}
-			}
-		}
+		return -1
 	}
-
-	return "", fmt.Errorf("quectel modem (MI_03) not active or found")
+	return mr
 }
@@ -0,0 +1,77 @@
+package serial
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestModem returns a GSMModem talking to sim, numbering long messages
+// with counters in a temporary directory.
+func newTestModem(t *testing.T, sim *Simulator) *GSMModem {
+	t.Helper()
+	g := NewGSMModem("SIM0", func(msg string, verbose bool) { t.Log(msg) })
+	g.OpenFunc = sim.Open
+	g.ConcatRefs = NewConcatRefs(filepath.Join(t.TempDir(), "concat_refs.properties"))
+	t.Cleanup(g.Close)
+	return g
+}
+
+// count returns how many received command lines equal cmd.
+func count(lines []string, cmd string) int {
+	n := 0
+	for _, l := range lines {
+		if l == cmd {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSendSMSKeepsPortOnErrorReply(t *testing.T) {
+	sim := NewSimulator()
+	g := newTestModem(t, sim)
+	sim.FailSends(500)
+
+	_, err := g.SendSMS(context.Background(), "+8613800138000", "first", SendOptions{})
+	if err == nil || !strings.Contains(err.Error(), "+CMS ERROR: 500") {
+		t.Fatalf("first send: err = %v, want +CMS ERROR: 500", err)
+	}
+	refs, err := g.SendSMS(context.Background(), "+8613800138000", "second", SendOptions{})
+	if err != nil {
+		t.Fatalf("second send: %v", err)
+	}
+	if len(refs) != 1 {
+		t.Errorf("second send: refs = %v, want one", refs)
+	}
+	if n := count(sim.Received(), "ATE0"); n != 1 {
+		t.Errorf("modem initialised %d times, want once", n)
+	}
+}
+
+// IsConnected is polled by the monitor's workers while a send connects
+// and closes the port; run with -race.
+func TestIsConnectedDuringSend(t *testing.T) {
+	sim := NewSimulator()
+	g := newTestModem(t, sim)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			g.SendSMS(context.Background(), "+8613800138000", "hello", SendOptions{})
+			g.Close()
+		}
+	}()
+	for polling := true; polling; {
+		select {
+		case <-done:
+			polling = false
+		default:
+			g.IsConnected()
+		}
+	}
+	if g.IsConnected() {
+		t.Error("connected after Close")
+	}
+}
@@ -0,0 +1,252 @@
+package serial
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Port names with these schemes reach the modem through a serial device
+// server instead of a local port: tcp:// is a raw socket, rfc2217:// a
+// Telnet connection with COM port control (RFC 2217), which also carries
+// the baud rate and LineConfig to the server.
+const (
+	schemeTCP     = "tcp://"
+	schemeRFC2217 = "rfc2217://"
+)
+
+// netDialTimeout bounds connecting to a device server.
+const netDialTimeout = 10 * time.Second
+
+// errConnClosed ends the reader when the server drops the connection;
+// io.EOF would be taken for a read timeout.
+var errConnClosed = errors.New("connection closed by the device server")
+
+// IsNetworkPort reports whether name is a tcp:// or rfc2217:// endpoint.
+func IsNetworkPort(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, schemeTCP) || strings.HasPrefix(lower, schemeRFC2217)
+}
+
+// openNetPort connects to the device server in name.
+func openNetPort(name string, baud int, line LineConfig) (io.ReadWriteCloser, error) {
+	telnet := strings.HasPrefix(strings.ToLower(name), schemeRFC2217)
+	addr := name[strings.Index(name, "://")+3:]
+	addr = strings.TrimSuffix(addr, "/")
+	if addr == "" {
+		return nil, fmt.Errorf("no address in %s", name)
+	}
+	conn, err := net.DialTimeout("tcp", addr, netDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		// Notice a server that vanished without closing the connection
+		tcp.SetKeepAlive(true)
+		tcp.SetKeepAlivePeriod(30 * time.Second)
+	}
+	p := &netPort{conn: conn, timeout: line.ReadTimeout, telnet: telnet}
+	if p.timeout <= 0 {
+		p.timeout = DefaultReadTimeout
+	}
+	if telnet {
+		if err := p.negotiate(baud, line); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Telnet bytes (RFC 854) and the COM port option (RFC 2217).
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	optBinary  = 0
+	optSGA     = 3
+	optComPort = 44
+
+	comSetBaud     = 1
+	comSetDataSize = 2
+	comSetParity   = 3
+	comSetStopSize = 4
+	comSetControl  = 5
+)
+
+// netPort is a modem connection through a device server.
+type netPort struct {
+	conn    net.Conn
+	timeout time.Duration
+	telnet  bool
+
+	wmu sync.Mutex // Write and the Telnet replies sent from Read
+
+	// Telnet parser state, carried over between reads
+	state byte // 0 data, else the command byte being parsed
+	inSB  bool
+}
+
+// negotiate asks for a binary, character-at-a-time session and sets the
+// line of the server's serial port.
+func (p *netPort) negotiate(baud int, line LineConfig) error {
+	c, err := line.config("", baud)
+	if err != nil {
+		return err
+	}
+	msg := []byte{
+		telnetIAC, telnetWILL, optBinary, telnetIAC, telnetDO, optBinary,
+		telnetIAC, telnetWILL, optSGA, telnetIAC, telnetDO, optSGA,
+		telnetIAC, telnetWILL, optComPort,
+	}
+	rate := make([]byte, 4)
+	binary.BigEndian.PutUint32(rate, uint32(baud))
+	msg = append(msg, comPortCommand(comSetBaud, rate...)...)
+
+	size := byte(c.Size)
+	if size == 0 {
+		size = 8
+	}
+	msg = append(msg, comPortCommand(comSetDataSize, size)...)
+	// RFC 2217 numbers: 1 none, 2 odd, 3 even, 4 mark, 5 space
+	parity := map[byte]byte{'N': 1, 'O': 2, 'E': 3, 'M': 4, 'S': 5}[byte(c.Parity)]
+	msg = append(msg, comPortCommand(comSetParity, parity)...)
+	// 1 one, 2 two, 3 one and a half
+	stop := map[byte]byte{1: 1, 2: 2, 15: 3}[byte(c.StopBits)]
+	msg = append(msg, comPortCommand(comSetStopSize, stop)...)
+	// 1 no flow control, 2 XON/XOFF, 3 RTS/CTS
+	control := map[string]byte{"rtscts": 3, "xonxoff": 2}[line.FlowControl]
+	if control == 0 {
+		control = 1
+	}
+	msg = append(msg, comPortCommand(comSetControl, control)...)
+	return p.writeRaw(msg)
+}
+
+// comPortCommand frames a COM-PORT-OPTION subnegotiation.
+func comPortCommand(cmd byte, value ...byte) []byte {
+	b := []byte{telnetIAC, telnetSB, optComPort, cmd}
+	for _, v := range value {
+		b = append(b, v)
+		if v == telnetIAC {
+			b = append(b, telnetIAC)
+		}
+	}
+	return append(b, telnetIAC, telnetSE)
+}
+
+func (p *netPort) writeRaw(b []byte) error {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	_, err := p.conn.Write(b)
+	return err
+}
+
+// Read implements io.Reader. Like a serial port with a read timeout it
+// returns (0, nil) when no data arrives in time.
+func (p *netPort) Read(b []byte) (int, error) {
+	p.conn.SetReadDeadline(time.Now().Add(p.timeout))
+	n, err := p.conn.Read(b)
+	if p.telnet {
+		n = p.filter(b[:n])
+	}
+	if err != nil {
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return n, nil
+		}
+		if err == io.EOF {
+			return n, errConnClosed
+		}
+	}
+	return n, err
+}
+
+// filter strips Telnet commands from b in place, answers option requests
+// and returns the number of data bytes left.
+func (p *netPort) filter(b []byte) int {
+	out := 0
+	for _, c := range b {
+		switch {
+		case p.state == telnetIAC:
+			p.state = 0
+			switch c {
+			case telnetIAC:
+				if !p.inSB {
+					b[out] = c
+					out++
+				}
+			case telnetSB:
+				p.inSB = true
+			case telnetSE:
+				p.inSB = false
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				p.state = c
+			}
+		case p.state != 0:
+			p.answer(p.state, c)
+			p.state = 0
+		case c == telnetIAC:
+			p.state = telnetIAC
+		case p.inSB:
+			// Server notifications and acknowledgements are not used
+		default:
+			b[out] = c
+			out++
+		}
+	}
+	return out
+}
+
+// answer accepts the options negotiate asked for and refuses the rest.
+func (p *netPort) answer(verb, opt byte) {
+	wanted := opt == optBinary || opt == optSGA || opt == optComPort
+	var reply byte
+	switch verb {
+	case telnetDO:
+		if !wanted {
+			reply = telnetWONT
+		}
+	case telnetWILL:
+		if !wanted || opt == optComPort {
+			reply = telnetDONT
+		}
+	}
+	if reply != 0 {
+		p.writeRaw([]byte{telnetIAC, reply, opt})
+	}
+}
+
+// Write implements io.Writer, doubling IAC bytes on a Telnet connection.
+func (p *netPort) Write(b []byte) (int, error) {
+	data := b
+	if p.telnet && bytes.IndexByte(b, telnetIAC) >= 0 {
+		data = make([]byte, 0, len(b)+1)
+		for _, c := range b {
+			data = append(data, c)
+			if c == telnetIAC {
+				data = append(data, telnetIAC)
+			}
+		}
+	}
+	if err := p.writeRaw(data); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close implements io.Closer.
+func (p *netPort) Close() error {
+	return p.conn.Close()
+}
@@ -0,0 +1,262 @@
+package serial
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// listen starts a device server on a free local port and returns it with
+// its address.
+func listen(t *testing.T) (net.Listener, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, l.Addr().String()
+}
+
+// accept waits for the next connection to l.
+func accept(t *testing.T, l net.Listener) net.Conn {
+	t.Helper()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+// readN reads n bytes from conn.
+func readN(t *testing.T, conn net.Conn, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatalf("server read: %v (got % X)", err, b)
+	}
+	return b
+}
+
+// readData reads from p until n data bytes arrived.
+func readData(t *testing.T, p io.Reader, n int) []byte {
+	t.Helper()
+	var got []byte
+	buf := make([]byte, 64)
+	for deadline := time.Now().Add(5 * time.Second); len(got) < n; {
+		k, err := p.Read(buf)
+		got = append(got, buf[:k]...)
+		if err != nil {
+			t.Fatalf("client read: %v (got % X)", err, got)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("client read % X, want %d bytes", got, n)
+		}
+	}
+	return got
+}
+
+func TestNetPortRawTCP(t *testing.T) {
+	l, addr := listen(t)
+	p, err := openNetPort("tcp://"+addr+"/", 115200, LineConfig{ReadTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("openNetPort: %v", err)
+	}
+	defer p.Close()
+	conn := accept(t, l)
+
+	// A raw socket passes every byte through, IAC included
+	if _, err := p.Write([]byte("AT\xff\r")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got := readN(t, conn, 4); !bytes.Equal(got, []byte("AT\xff\r")) {
+		t.Errorf("server got % X, want AT FF CR", got)
+	}
+	conn.Write([]byte("\r\nOK\xff\r\n"))
+	if got := readData(t, p, 7); !bytes.Equal(got, []byte("\r\nOK\xff\r\n")) {
+		t.Errorf("client got %q", got)
+	}
+
+	// No data within the read timeout is not an error
+	if n, err := p.Read(make([]byte, 8)); n != 0 || err != nil {
+		t.Errorf("idle Read = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestNetPortRFC2217Negotiation(t *testing.T) {
+	l, addr := listen(t)
+	type result struct {
+		p   io.ReadWriteCloser
+		err error
+	}
+	opened := make(chan result, 1)
+	go func() {
+		p, err := openNetPort("rfc2217://"+addr, 115200, LineConfig{DataBits: 7, Parity: "E", StopBits: "2", FlowControl: "rtscts", ReadTimeout: 100 * time.Millisecond})
+		opened <- result{p, err}
+	}()
+	conn := accept(t, l)
+	r := <-opened
+	if r.err != nil {
+		t.Fatalf("openNetPort: %v", r.err)
+	}
+	p := r.p
+	defer p.Close()
+
+	want := []byte{
+		telnetIAC, telnetWILL, optBinary, telnetIAC, telnetDO, optBinary,
+		telnetIAC, telnetWILL, optSGA, telnetIAC, telnetDO, optSGA,
+		telnetIAC, telnetWILL, optComPort,
+		telnetIAC, telnetSB, optComPort, comSetBaud, 0x00, 0x01, 0xC2, 0x00, telnetIAC, telnetSE,
+		telnetIAC, telnetSB, optComPort, comSetDataSize, 7, telnetIAC, telnetSE,
+		telnetIAC, telnetSB, optComPort, comSetParity, 3, telnetIAC, telnetSE,
+		telnetIAC, telnetSB, optComPort, comSetStopSize, 2, telnetIAC, telnetSE,
+		telnetIAC, telnetSB, optComPort, comSetControl, 3, telnetIAC, telnetSE,
+	}
+	if got := readN(t, conn, len(want)); !bytes.Equal(got, want) {
+		t.Errorf("negotiation\n got % X\nwant % X", got, want)
+	}
+
+	// The server agrees to binary, asks for an unsupported option and
+	// acknowledges the baud rate; only the data reaches the caller
+	conn.Write([]byte{
+		telnetIAC, telnetDO, optBinary,
+		telnetIAC, telnetDO, 24, // terminal type
+		telnetIAC, telnetSB, optComPort, 100 + comSetBaud, 0x00, 0x01, 0xC2, 0x00, telnetIAC, telnetSE,
+		'O', 'K', '\r', '\n',
+	})
+	if got := readData(t, p, 4); string(got) != "OK\r\n" {
+		t.Errorf("client got %q, want OK", got)
+	}
+	if got := readN(t, conn, 3); !bytes.Equal(got, []byte{telnetIAC, telnetWONT, 24}) {
+		t.Errorf("answer to DO 24 = % X, want IAC WONT 24", got)
+	}
+}
+
+func TestNetPortRFC2217Escaping(t *testing.T) {
+	l, addr := listen(t)
+	opened := make(chan io.ReadWriteCloser, 1)
+	go func() {
+		p, err := openNetPort("rfc2217://"+addr, 255, LineConfig{ReadTimeout: 100 * time.Millisecond})
+		if err != nil {
+			t.Errorf("openNetPort: %v", err)
+		}
+		opened <- p
+	}()
+	conn := accept(t, l)
+	p := <-opened
+	if p == nil {
+		return
+	}
+	defer p.Close()
+
+	// A baud rate of 255 puts an IAC into the subnegotiation
+	readN(t, conn, 15)
+	if got := readN(t, conn, 11); !bytes.Equal(got, []byte{telnetIAC, telnetSB, optComPort, comSetBaud, 0, 0, 0, telnetIAC, telnetIAC, telnetIAC, telnetSE}) {
+		t.Errorf("baud command = % X, want the 0xFF doubled", got)
+	}
+	readN(t, conn, 28)
+
+	if n, err := p.Write([]byte{'A', 0xFF, 'B'}); n != 3 || err != nil {
+		t.Fatalf("Write = %d, %v, want 3, nil", n, err)
+	}
+	if got := readN(t, conn, 4); !bytes.Equal(got, []byte{'A', telnetIAC, telnetIAC, 'B'}) {
+		t.Errorf("server got % X, want 41 FF FF 42", got)
+	}
+	conn.Write([]byte{'C', telnetIAC, telnetIAC, 'D'})
+	if got := readData(t, p, 3); !bytes.Equal(got, []byte{'C', 0xFF, 'D'}) {
+		t.Errorf("client got % X, want 43 FF 44", got)
+	}
+}
+
+// simServer serves sim on l, one connection at a time, and lets the test
+// drop the current connection.
+type simServer struct {
+	mu       sync.Mutex
+	conn     net.Conn
+	accepted int
+}
+
+func serveSimulator(l net.Listener, sim *Simulator) *simServer {
+	srv := &simServer{}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			srv.mu.Lock()
+			srv.conn = conn
+			srv.accepted++
+			srv.mu.Unlock()
+
+			port, _ := sim.Open("", 0)
+			copied := make(chan struct{})
+			go func() {
+				defer close(copied)
+				buf := make([]byte, 256)
+				for {
+					n, err := port.Read(buf)
+					if err != nil {
+						return
+					}
+					if n > 0 {
+						if _, err := conn.Write(buf[:n]); err != nil {
+							return
+						}
+					}
+				}
+			}()
+			io.Copy(port, conn)
+			port.Close()
+			conn.Close()
+			<-copied
+		}
+	}()
+	return srv
+}
+
+func (srv *simServer) drop() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.conn.Close()
+}
+
+func (srv *simServer) connections() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.accepted
+}
+
+func TestNetPortReconnects(t *testing.T) {
+	l, addr := listen(t)
+	srv := serveSimulator(l, NewSimulator())
+	g := NewGSMModem("tcp://"+addr, func(msg string, verbose bool) { t.Log(msg) })
+	g.Line.ReadTimeout = 100 * time.Millisecond
+	defer g.Close()
+
+	if err := g.Ping(); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	srv.drop()
+
+	// The lost connection is noticed and the next use dials again
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		err := g.Ping()
+		if err == nil && srv.connections() == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no reconnect: %d connections, last Ping: %v", srv.connections(), err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !g.IsConnected() {
+		t.Error("not connected after reconnecting")
+	}
+}
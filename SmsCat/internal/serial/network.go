@@ -0,0 +1,179 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Registration states of +CREG/+CEREG <stat>.
+const (
+	RegNotRegistered = 0
+	RegHome          = 1
+	RegSearching     = 2
+	RegDenied        = 3
+	RegUnknown       = 4
+	RegRoaming       = 5
+)
+
+var regStateNames = map[int]string{
+	RegNotRegistered: "not registered",
+	RegHome:          "home",
+	RegSearching:     "searching",
+	RegDenied:        "denied",
+	RegUnknown:       "unknown",
+	RegRoaming:       "roaming",
+}
+
+// RegStateName describes a +CREG/+CEREG <stat> value.
+func RegStateName(stat int) string {
+	if name, ok := regStateNames[stat]; ok {
+		return name
+	}
+	if stat < 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("stat %d", stat)
+}
+
+// accessTechNames maps the 27.007 <AcT> values reported by +COPS.
+var accessTechNames = map[int]string{
+	0: "GSM", 2: "UTRAN", 3: "EDGE", 4: "HSDPA", 5: "HSUPA", 6: "HSPA",
+	7: "LTE", 8: "EC-GSM", 9: "NB-IoT", 10: "LTE/5GC", 11: "NR", 12: "NG-RAN", 13: "EN-DC",
+}
+
+// NetworkStatus is one sample of signal quality and registration.
+// Unknown values are -1 (RSSI/BER 99 from the modem is reported as -1).
+type NetworkStatus struct {
+	Time       time.Time
+	RSSI       int // +CSQ <rssi>, 0..31
+	RSSIdBm    int // RSSI converted to dBm, 0 when unknown
+	BER        int // +CSQ <ber>, 0..7
+	CREG       int // circuit-switched registration <stat>
+	CEREG      int // EPS registration <stat>
+	Registered bool
+	State      string // describes the better of CREG and CEREG
+	Operator   string
+	AccessTech string
+}
+
+// QueryNetwork polls AT+CSQ, AT+CREG?, AT+CEREG? and AT+COPS?. Commands
+// the modem does not support leave their fields unknown; only a failure to
+// talk to the modem at all is an error.
+func (g *GSMModem) QueryNetwork() (*NetworkStatus, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.connect(); err != nil {
+		return nil, err
+	}
+
+	st := &NetworkStatus{Time: time.Now(), RSSI: -1, BER: -1, CREG: -1, CEREG: -1}
+
+	resp, err := g.exec("AT+CSQ")
+	if err != nil {
+		var atErr *ATError
+		if !errors.As(err, &atErr) {
+			g.close()
+			return nil, fmt.Errorf("signal query failed: %w", err)
+		}
+	} else {
+		st.RSSI, st.BER = parseCSQ(resp.Line("+CSQ:"))
+		if st.RSSI >= 0 {
+			st.RSSIdBm = -113 + 2*st.RSSI
+		}
+	}
+
+	if resp, err := g.exec("AT+CREG?"); err == nil {
+		st.CREG = parseRegStat(resp.Line("+CREG:"))
+	}
+	if resp, err := g.exec("AT+CEREG?"); err == nil {
+		st.CEREG = parseRegStat(resp.Line("+CEREG:"))
+	}
+	if resp, err := g.exec("AT+COPS?"); err == nil {
+		st.Operator, st.AccessTech = parseCOPS(resp.Line("+COPS:"))
+	}
+
+	best := st.CREG
+	switch {
+	case isRegistered(st.CEREG) && !isRegistered(st.CREG):
+		best = st.CEREG
+	case best < 0:
+		best = st.CEREG
+	}
+	st.Registered = isRegistered(st.CREG) || isRegistered(st.CEREG)
+	st.State = RegStateName(best)
+	return st, nil
+}
+
+func isRegistered(stat int) bool {
+	return stat == RegHome || stat == RegRoaming
+}
+
+// parseCSQ reads "+CSQ: <rssi>,<ber>"; 99 (not known) becomes -1.
+func parseCSQ(line string) (rssi, ber int) {
+	fields := splitInfoLine(line, "+CSQ:")
+	rssi, ber = -1, -1
+	if len(fields) >= 1 {
+		if n, err := strconv.Atoi(fields[0]); err == nil && n != 99 {
+			rssi = n
+		}
+	}
+	if len(fields) >= 2 {
+		if n, err := strconv.Atoi(fields[1]); err == nil && n != 99 {
+			ber = n
+		}
+	}
+	return rssi, ber
+}
+
+// parseRegStat reads <stat> from a read-command response
+// "+CREG: <n>,<stat>[,...]", or -1.
+func parseRegStat(line string) int {
+	if line == "" {
+		return -1
+	}
+	prefix := line[:strings.Index(line, ":")+1]
+	fields := splitInfoLine(line, prefix)
+	if len(fields) < 2 {
+		return -1
+	}
+	stat, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return -1
+	}
+	return stat
+}
+
+// parseCOPS reads "+COPS: <mode>[,<format>,<oper>[,<AcT>]]".
+func parseCOPS(line string) (operator, accessTech string) {
+	fields := splitInfoLine(line, "+COPS:")
+	if len(fields) >= 3 {
+		operator = strings.Trim(fields[2], "\"")
+	}
+	if len(fields) >= 4 {
+		if n, err := strconv.Atoi(fields[3]); err == nil {
+			if name, ok := accessTechNames[n]; ok {
+				accessTech = name
+			} else {
+				accessTech = fmt.Sprintf("AcT %d", n)
+			}
+		}
+	}
+	return operator, accessTech
+}
+
+// splitInfoLine strips prefix and splits the comma-separated fields.
+func splitInfoLine(line string, prefix string) []string {
+	line = strings.TrimSpace(strings.TrimPrefix(line, prefix))
+	if line == "" {
+		return nil
+	}
+	fields := strings.Split(line, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
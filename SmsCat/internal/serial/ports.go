@@ -0,0 +1,116 @@
+package serial
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// QuectelVID is the USB vendor ID of Quectel modems.
+const QuectelVID = "2C7C"
+
+// PortInfo describes a serial port found on the system. USB fields are
+// empty (Interface -1) for ports that are not USB devices.
+type PortInfo struct {
+	Path         string // COM3, /dev/ttyUSB2
+	VID          string // upper-case hex, e.g. 2C7C
+	PID          string // upper-case hex, e.g. 0125
+	Interface    int    // USB interface number, -1 when unknown
+	SerialNumber string
+}
+
+// IsUSB reports whether the port belongs to a USB device.
+func (p PortInfo) IsUSB() bool {
+	return p.VID != ""
+}
+
+func (p PortInfo) String() string {
+	if !p.IsUSB() {
+		return p.Path
+	}
+	s := fmt.Sprintf("%s (USB %s:%s", p.Path, p.VID, p.PID)
+	if p.Interface >= 0 {
+		s += fmt.Sprintf(" if%02d", p.Interface)
+	}
+	if p.SerialNumber != "" {
+		s += " sn " + p.SerialNumber
+	}
+	return s + ")"
+}
+
+// CheckAvailablePorts lists the serial ports that can currently be opened.
+func CheckAvailablePorts() []PortInfo {
+	ports, err := ListPorts()
+	if err != nil {
+		return nil
+	}
+	var available []PortInfo
+	for _, p := range ports {
+		if probePort(p.Path) {
+			available = append(available, p)
+		}
+	}
+	return available
+}
+
+// DetectedModem is the AT port of an attached modem and the profile it
+// matched.
+type DetectedModem struct {
+	Port    PortInfo
+	Profile *Profile
+}
+
+// FindModemPorts locates the AT command ports of all attached modems
+// matching one of profiles (all registered profiles when none are given).
+// Ports that are listed but cannot be opened (stale entries of an
+// unplugged device) are skipped.
+func FindModemPorts(profiles ...*Profile) ([]DetectedModem, error) {
+	if len(profiles) == 0 {
+		profiles = Profiles()
+	}
+	ports, err := ListPorts()
+	if err != nil {
+		return nil, err
+	}
+	var found []DetectedModem
+	for _, p := range ports {
+		for _, profile := range profiles {
+			if profile.Matches(p) && probePort(p.Path) {
+				found = append(found, DetectedModem{Port: p, Profile: profile})
+				break
+			}
+		}
+	}
+	if len(found) == 0 {
+		var names []string
+		for _, profile := range profiles {
+			if profile.VID != "" {
+				names = append(names, profile.Vendor)
+			}
+		}
+		return nil, fmt.Errorf("no %s modem AT port active or found", strings.Join(names, "/"))
+	}
+	return found, nil
+}
+
+// FindModemPort returns the first modem found by FindModemPorts.
+func FindModemPort(profiles ...*Profile) (PortInfo, *Profile, error) {
+	found, err := FindModemPorts(profiles...)
+	if err != nil {
+		return PortInfo{}, nil, err
+	}
+	return found[0].Port, found[0].Profile, nil
+}
+
+// probePort reports whether path can be opened right now.
+func probePort(path string) bool {
+	c := &serial.Config{Name: path, Baud: 115200, ReadTimeout: time.Millisecond * 100}
+	s, err := serial.OpenPort(c)
+	if err != nil {
+		return false
+	}
+	s.Close()
+	return true
+}
@@ -0,0 +1,84 @@
+//go:build linux
+
+package serial
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SysfsRoot is where sysfs is mounted. It can be pointed at a copied tree
+// to inspect another machine's devices.
+var SysfsRoot = "/sys"
+
+// DevDir is the directory holding the tty device nodes.
+var DevDir = "/dev"
+
+// ListPorts returns the USB serial ports (ttyUSB*, ttyACM*) known to sysfs.
+func ListPorts() ([]PortInfo, error) {
+	return ListPortsFromSysfs(SysfsRoot)
+}
+
+// ListPortsFromSysfs walks <root>/class/tty and resolves each USB tty to
+// its interface and device directories to read the USB descriptors.
+func ListPortsFromSysfs(root string) ([]PortInfo, error) {
+	classDir := filepath.Join(root, "class", "tty")
+	entries, err := os.ReadDir(classDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", classDir, err)
+	}
+
+	var ports []PortInfo
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, "ttyUSB") && !strings.HasPrefix(name, "ttyACM") {
+			continue
+		}
+		device, err := filepath.EvalSymlinks(filepath.Join(classDir, name, "device"))
+		if err != nil {
+			continue
+		}
+		info := PortInfo{Path: filepath.Join(DevDir, name), Interface: -1}
+		describeUSB(&info, device, root)
+		ports = append(ports, info)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Path < ports[j].Path })
+	return ports, nil
+}
+
+// describeUSB climbs from a tty's device directory to the USB interface
+// (bInterfaceNumber) and then the USB device (idVendor/idProduct).
+// usb-serial ttys sit one level below their interface, cdc-acm ttys point
+// at it directly, so both layouts are covered by walking up.
+func describeUSB(info *PortInfo, dir string, root string) {
+	stop := filepath.Clean(root)
+	for dir != stop && dir != filepath.Dir(dir) {
+		if info.Interface < 0 {
+			if v, ok := readSysfsAttr(dir, "bInterfaceNumber"); ok {
+				if n, err := strconv.ParseInt(v, 16, 32); err == nil {
+					info.Interface = int(n)
+				}
+			}
+		}
+		if vid, ok := readSysfsAttr(dir, "idVendor"); ok {
+			info.VID = strings.ToUpper(vid)
+			info.PID, _ = readSysfsAttr(dir, "idProduct")
+			info.PID = strings.ToUpper(info.PID)
+			info.SerialNumber, _ = readSysfsAttr(dir, "serial")
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func readSysfsAttr(dir string, name string) (string, bool) {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(b)), true
+}
@@ -0,0 +1,32 @@
+package serial
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testdata/sysfs is a trimmed copy of a sysfs tree: a Quectel EC25 whose
+// usb-serial ttys sit below their interfaces, a cdc-acm modem whose tty
+// points at its interface, a platform UART and a tty whose device is
+// gone. Colons in the real directory names are replaced by underscores so
+// the tree checks out on Windows.
+func TestListPortsFromSysfs(t *testing.T) {
+	ports, err := ListPortsFromSysfs("testdata/sysfs")
+	if err != nil {
+		t.Fatalf("ListPortsFromSysfs: %v", err)
+	}
+	want := []PortInfo{
+		{Path: "/dev/ttyACM0", VID: "1E0E", PID: "9001", Interface: 10},
+		{Path: "/dev/ttyUSB2", VID: "2C7C", PID: "0125", Interface: 2, SerialNumber: "EC25ABC123"},
+		{Path: "/dev/ttyUSB3", VID: "2C7C", PID: "0125", Interface: 3, SerialNumber: "EC25ABC123"},
+	}
+	if !reflect.DeepEqual(ports, want) {
+		t.Errorf("ports:\n got %+v\nwant %+v", ports, want)
+	}
+}
+
+func TestListPortsFromSysfsMissing(t *testing.T) {
+	if _, err := ListPortsFromSysfs(t.TempDir()); err == nil {
+		t.Error("no error for a root without class/tty")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows && !linux
+
+package serial
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// ListPorts is not implemented on this platform; set the port explicitly.
+func ListPorts() ([]PortInfo, error) {
+	return nil, fmt.Errorf("serial port discovery is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,135 @@
+//go:build windows
+
+package serial
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// ListPorts returns the COM ports present in HARDWARE\DEVICEMAP\SERIALCOMM,
+// enriched with USB vendor/product/interface numbers from Enum\USB.
+func ListPorts() ([]PortInfo, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `HARDWARE\DEVICEMAP\SERIALCOMM`, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SERIALCOMM key: %w", err)
+	}
+	defer k.Close()
+
+	names, err := k.ReadValueNames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	usb := usbPortsFromRegistry()
+
+	var ports []PortInfo
+	for _, name := range names {
+		com, _, err := k.GetStringValue(name)
+		if err != nil || com == "" {
+			continue
+		}
+		if info, ok := usb[strings.ToUpper(com)]; ok {
+			ports = append(ports, info)
+			continue
+		}
+		ports = append(ports, PortInfo{Path: com, Interface: -1})
+	}
+	sort.Slice(ports, func(i, j int) bool { return comNumber(ports[i].Path) < comNumber(ports[j].Path) })
+	return ports, nil
+}
+
+// usbPortsFromRegistry maps COM names to USB descriptors by walking
+// USB\<VID_xxxx&PID_xxxx[&MI_xx]>\<Instance>\Device Parameters\PortName.
+// Entries may be stale (device unplugged); callers only use them to
+// describe ports that SERIALCOMM reports as present.
+func usbPortsFromRegistry() map[string]PortInfo {
+	result := make(map[string]PortInfo)
+
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Enum\USB`, registry.ENUMERATE_SUB_KEYS|registry.READ)
+	if err != nil {
+		return result
+	}
+	defer k.Close()
+
+	devices, err := k.ReadSubKeyNames(-1)
+	if err != nil {
+		return result
+	}
+
+	for _, device := range devices {
+		vid, pid, iface := parseUSBDeviceID(device)
+		if vid == "" {
+			continue
+		}
+
+		deviceKeyPath := fmt.Sprintf(`SYSTEM\CurrentControlSet\Enum\USB\%s`, device)
+		dk, err := registry.OpenKey(registry.LOCAL_MACHINE, deviceKeyPath, registry.ENUMERATE_SUB_KEYS|registry.READ)
+		if err != nil {
+			continue
+		}
+		instances, err := dk.ReadSubKeyNames(-1)
+		dk.Close()
+		if err != nil {
+			continue
+		}
+
+		for _, instance := range instances {
+			paramPath := fmt.Sprintf(`%s\%s\Device Parameters`, deviceKeyPath, instance)
+			pk, err := registry.OpenKey(registry.LOCAL_MACHINE, paramPath, registry.QUERY_VALUE)
+			if err != nil {
+				continue
+			}
+			portName, _, err := pk.GetStringValue("PortName")
+			pk.Close()
+			if err != nil || portName == "" {
+				continue
+			}
+
+			// Instance IDs without '&' are the device's USB serial number;
+			// generated IDs of composite interfaces contain '&'.
+			serialNumber := ""
+			if !strings.Contains(instance, "&") {
+				serialNumber = instance
+			}
+			result[strings.ToUpper(portName)] = PortInfo{
+				Path:         portName,
+				VID:          vid,
+				PID:          pid,
+				Interface:    iface,
+				SerialNumber: serialNumber,
+			}
+		}
+	}
+	return result
+}
+
+// parseUSBDeviceID splits "VID_2C7C&PID_0125&MI_03" into its parts.
+func parseUSBDeviceID(id string) (vid, pid string, iface int) {
+	iface = -1
+	for _, part := range strings.Split(strings.ToUpper(id), "&") {
+		switch {
+		case strings.HasPrefix(part, "VID_"):
+			vid = strings.TrimPrefix(part, "VID_")
+		case strings.HasPrefix(part, "PID_"):
+			pid = strings.TrimPrefix(part, "PID_")
+		case strings.HasPrefix(part, "MI_"):
+			if n, err := strconv.ParseInt(strings.TrimPrefix(part, "MI_"), 16, 32); err == nil {
+				iface = int(n)
+			}
+		}
+	}
+	return vid, pid, iface
+}
+
+func comNumber(name string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(name), "COM"))
+	if err != nil {
+		return 1 << 30
+	}
+	return n
+}
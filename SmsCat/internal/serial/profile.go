@@ -0,0 +1,220 @@
+package serial
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// InitCommand is one step of a profile's init script, run after the SIM
+// check. A failing Required command aborts Connect; others only warn.
+type InitCommand struct {
+	Command  string
+	Required bool
+}
+
+// SendQuirks tune SendSMS for firmware that needs more time than the
+// defaults.
+type SendQuirks struct {
+	// PromptTimeout is how long to wait for the "> " prompt after AT+CMGS.
+	PromptTimeout time.Duration
+	// SendTimeout bounds the final result after the PDU has been written.
+	// Zero uses the command timeout table.
+	SendTimeout time.Duration
+	// SegmentDelay is the pause between the parts of a concatenated SMS.
+	SegmentDelay time.Duration
+}
+
+// Profile describes a modem family: how to recognise it on USB, which
+// interface carries AT commands and how to initialise it.
+type Profile struct {
+	Name   string
+	Vendor string
+
+	// VID is the USB vendor ID (upper-case hex). Profiles without one are
+	// never auto-detected and must be forced in the settings.
+	VID string
+	// PIDs restricts the match to these product IDs; empty matches any.
+	PIDs []string
+	// ATInterface is the USB interface number of the AT port.
+	ATInterface int
+
+	Init   []InitCommand
+	Quirks SendQuirks
+	// PINCounter reports remaining PIN/PUK attempts; zero if unsupported.
+	PINCounter PINCounter
+	// URCs are vendor-specific unsolicited prefixes to keep out of
+	// command responses.
+	URCs []string
+	// DTMF enables key detection during voice calls; zero if unsupported.
+	DTMF DTMFDetect
+	// ICCIDCommand is the vendor command reading the SIM's ICCID, tried
+	// before the standard AT+CCID.
+	ICCIDCommand string
+	// USSDPacked is set when USSD strings are exchanged as packed GSM
+	// 7-bit hex regardless of AT+CSCS.
+	USSDPacked bool
+}
+
+// Matches reports whether port is the AT interface of this profile's modem.
+func (p *Profile) Matches(port PortInfo) bool {
+	if p.VID == "" || !strings.EqualFold(p.VID, port.VID) {
+		return false
+	}
+	if p.ATInterface != port.Interface {
+		return false
+	}
+	if len(p.PIDs) == 0 {
+		return true
+	}
+	for _, pid := range p.PIDs {
+		if strings.EqualFold(pid, port.PID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Profile) String() string {
+	return p.Vendor + " (" + p.Name + ")"
+}
+
+var (
+	// QuectelProfile covers the EC2x/EG2x/EC200 family. It is the default
+	// for modems created without a profile.
+	QuectelProfile = &Profile{
+		Name:        "quectel",
+		Vendor:      "Quectel",
+		VID:         QuectelVID,
+		ATInterface: 3,
+		Init: []InitCommand{
+			{"AT+CMGF=1", true},
+			{"AT+CSCS=\"UCS2\"", false},
+			{"AT+CSMP=17,167,0,8", false},
+			{"AT+CGSMS=2", false}, // prefer packet domain
+			// Store incoming SMS (+CMTI) and route status reports directly (+CDS)
+			{"AT+CNMI=2,1,0,1,0", false},
+			{"AT+CTZU=1", false}, // keep the clock on network time
+		},
+		Quirks: SendQuirks{
+			PromptTimeout: 5 * time.Second,
+			SegmentDelay:  2 * time.Second,
+		},
+		PINCounter:   PINCounter{Command: "AT+QPINC=\"SC\"", Prefix: "+QPINC:", PIN: 1, PUK: 2},
+		URCs:         []string{"+QUSIM:", "+QSTK:"},
+		DTMF:         DTMFDetect{Command: "AT+QTONEDET=1", Prefix: "+QTONEDET:", ASCII: true},
+		ICCIDCommand: "AT+QCCID",
+	}
+
+	// SIMComProfile covers the SIM7600 series, whose AT port is MI_02.
+	SIMComProfile = &Profile{
+		Name:        "sim7600",
+		Vendor:      "SIMCom",
+		VID:         "1E0E",
+		PIDs:        []string{"9001", "9011"},
+		ATInterface: 2,
+		Init: []InitCommand{
+			{"AT+CMGF=0", true},
+			{"AT+CNMI=2,1,0,1,0", false},
+			{"AT+CTZU=1", false},
+		},
+		Quirks: SendQuirks{
+			PromptTimeout: 5 * time.Second,
+			SegmentDelay:  2 * time.Second,
+		},
+		PINCounter:   PINCounter{Command: "AT+SPIC", Prefix: "+SPIC:", PIN: 0, PUK: 1},
+		URCs:         []string{"+CPSI:", "PB DONE", "SMS DONE"},
+		ICCIDCommand: "AT+CICCID",
+	}
+
+	// HuaweiProfile covers E-series sticks in serial mode, whose PC UI port
+	// is MI_02. They flood ^RSSI/^MODE unless AT^CURC=0 is accepted and
+	// only route status reports with CNMI <ds>=2.
+	HuaweiProfile = &Profile{
+		Name:        "huawei",
+		Vendor:      "Huawei",
+		VID:         "12D1",
+		ATInterface: 2,
+		Init: []InitCommand{
+			{"AT^CURC=0", false},
+			{"AT+CMGF=0", true},
+			{"AT+CNMI=2,1,0,2,0", false},
+		},
+		Quirks: SendQuirks{
+			PromptTimeout: 10 * time.Second,
+			SendTimeout:   60 * time.Second,
+			SegmentDelay:  3 * time.Second,
+		},
+		// ^CPIN: <code>,<times>,<puk_times>,<pin_times>,<puk2_times>,<pin2_times>
+		PINCounter:   PINCounter{Command: "AT^CPIN?", Prefix: "^CPIN:", PIN: 3, PUK: 2},
+		URCs:         []string{"^RSSI:", "^MODE:", "^BOOT:", "^SRVST:", "^SIMST:", "^HCSQ:", "^DSFLOWRPT:"},
+		USSDPacked:   true,
+		ICCIDCommand: "AT^ICCID?",
+	}
+
+	// GenericProfile sticks to 3GPP 27.005 commands. It has no USB IDs and
+	// is only used when forced, e.g. for modems behind a USB-serial adapter.
+	GenericProfile = &Profile{
+		Name:        "generic",
+		Vendor:      "Generic",
+		ATInterface: -1,
+		Init: []InitCommand{
+			{"AT+CMGF=0", true},
+			{"AT+CNMI=2,1,0,1,0", false},
+		},
+		Quirks: SendQuirks{
+			PromptTimeout: 10 * time.Second,
+			SegmentDelay:  2 * time.Second,
+		},
+	}
+)
+
+var (
+	profileMu sync.RWMutex
+	profiles  = []*Profile{QuectelProfile, SIMComProfile, HuaweiProfile, GenericProfile}
+)
+
+// RegisterProfile adds p to the registry, replacing a profile of the same
+// name.
+func RegisterProfile(p *Profile) {
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	for i, existing := range profiles {
+		if strings.EqualFold(existing.Name, p.Name) {
+			profiles[i] = p
+			return
+		}
+	}
+	profiles = append(profiles, p)
+}
+
+// Profiles returns the registered profiles in detection order.
+func Profiles() []*Profile {
+	profileMu.RLock()
+	defer profileMu.RUnlock()
+	return append([]*Profile(nil), profiles...)
+}
+
+// LookupProfile returns the profile called name, or nil.
+func LookupProfile(name string) *Profile {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	for _, p := range Profiles() {
+		if strings.EqualFold(p.Name, name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// ProfileFor returns the first profile whose USB IDs match port, or nil.
+func ProfileFor(port PortInfo) *Profile {
+	for _, p := range Profiles() {
+		if p.Matches(port) {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package serial
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"smallNfast/internal/pdu"
+)
+
+// InboundMessage is one SMS-DELIVER read from the modem's storage.
+type InboundMessage struct {
+	Index     int // storage index, for AT+CMGD
+	Status    int // <stat> from AT+CMGL (0 unread, 1 read)
+	SMSC      string
+	Sender    string
+	Timestamp time.Time // service centre time stamp
+	Alphabet  string
+	Text      string
+
+	// Concatenation info from the UDH; ConcatTotal is 0 for single messages.
+	ConcatRef   int
+	ConcatTotal int
+	ConcatSeq   int
+}
+
+// decodeInbound decodes a listed SMS-DELIVER PDU into an InboundMessage.
+func decodeInbound(pduHex string) (*InboundMessage, error) {
+	d, err := pdu.DecodeDeliver(pduHex)
+	if err != nil {
+		return nil, err
+	}
+	msg := &InboundMessage{
+		SMSC:      d.SMSC,
+		Sender:    d.Sender,
+		Timestamp: d.Timestamp,
+		Alphabet:  d.Alphabet(),
+		Text:      d.Text,
+	}
+	msg.ConcatRef, msg.ConcatTotal, msg.ConcatSeq, _ = d.UDH.Concat()
+	return msg, nil
+}
+
+// ListMessages reads every message in the preferred storage (AT+CMGL=4) in
+// PDU mode and decodes the SMS-DELIVERs. Entries that fail to decode are
+// logged and skipped; they stay in storage.
+func (g *GSMModem) ListMessages() ([]InboundMessage, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entries, err := g.listPDUs()
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []InboundMessage
+	for _, e := range entries {
+		if t := describeStored(e).Type; t != PDUDeliver {
+			g.log(fmt.Sprintf("Inbox: skipping stored %s %d", t, e.index), true)
+			continue
+		}
+		msg, err := decodeInbound(e.pdu)
+		if err != nil {
+			g.log(fmt.Sprintf("Inbox: cannot decode message %d: %v", e.index, err), false)
+			continue
+		}
+		msg.Index = e.index
+		msg.Status = e.stat
+		msgs = append(msgs, *msg)
+	}
+	return msgs, nil
+}
+
+// DeleteMessage removes the message at index from the preferred storage.
+func (g *GSMModem) DeleteMessage(index int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.port == nil {
+		return fmt.Errorf("port not open")
+	}
+	if _, err := g.exec(fmt.Sprintf("AT+CMGD=%d", index)); err != nil {
+		return fmt.Errorf("failed to delete message %d: %w", index, err)
+	}
+	return nil
+}
+
+// parseCMGLHeader parses "+CMGL: <index>,<stat>,[<alpha>],<length>".
+func parseCMGLHeader(line string) (index int, stat int, err error) {
+	fields := strings.Split(strings.TrimSpace(strings.TrimPrefix(line, "+CMGL:")), ",")
+	if len(fields) < 2 {
+		return 0, 0, fmt.Errorf("malformed CMGL header: %s", line)
+	}
+	if index, err = strconv.Atoi(strings.TrimSpace(fields[0])); err != nil {
+		return 0, 0, fmt.Errorf("malformed CMGL index: %s", line)
+	}
+	if stat, err = strconv.Atoi(strings.TrimSpace(fields[1])); err != nil {
+		return 0, 0, fmt.Errorf("malformed CMGL status: %s", line)
+	}
+	return index, stat, nil
+}
@@ -0,0 +1,172 @@
+package serial
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Replay plays recorded captures back to a GSMModem in place of a serial
+// port, so a session recorded at a customer site can be reproduced without
+// the hardware. Plug Open into GSMModem.OpenFunc; every Open takes the next
+// capture, the way a reconnect opened a new capture file when recording.
+//
+// Replay is driven by what the modem writes, not by the recorded clock:
+// every write is compared with the next recorded write, and then the bytes
+// read after it in the recording are handed to Read. Bytes read before the
+// first write (URCs at power-up) are available right after Open. A write
+// that differs from the recording is noted in Mismatches and still
+// releases the recorded answer.
+type Replay struct {
+	// ReadTimeout bounds how long Read blocks when no data is pending.
+	ReadTimeout time.Duration
+
+	mu         sync.Mutex
+	captures   []*Capture
+	ports      []*replayPort
+	mismatches []string
+}
+
+// NewReplay returns a replay of captures, in order of Open.
+func NewReplay(captures ...*Capture) *Replay {
+	return &Replay{ReadTimeout: 100 * time.Millisecond, captures: captures}
+}
+
+// Open is a GSMModem.OpenFunc that returns a port playing the next capture.
+func (r *Replay) Open(name string, baud int) (io.ReadWriteCloser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.ports) >= len(r.captures) {
+		return nil, fmt.Errorf("replay: no capture left for %s", name)
+	}
+	p := &replayPort{
+		replay: r,
+		index:  len(r.ports),
+		events: r.captures[len(r.ports)].Events,
+		ready:  make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+	p.release()
+	r.ports = append(r.ports, p)
+	return p, nil
+}
+
+// Mismatches describes every write that differed from the recording.
+func (r *Replay) Mismatches() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.mismatches...)
+}
+
+// Done reports whether every capture has been opened and played to its
+// end.
+func (r *Replay) Done() bool {
+	r.mu.Lock()
+	ports := append([]*replayPort(nil), r.ports...)
+	opened := len(ports) == len(r.captures)
+	r.mu.Unlock()
+	if !opened {
+		return false
+	}
+	// Not under r.mu: a port holds its own lock while reporting mismatches
+	for _, p := range ports {
+		p.mu.Lock()
+		left := p.pos < len(p.events)
+		p.mu.Unlock()
+		if left {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Replay) mismatch(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mismatches = append(r.mismatches, fmt.Sprintf(format, args...))
+}
+
+// replayPort plays one capture.
+type replayPort struct {
+	replay    *Replay
+	index     int // of the capture, for mismatch messages
+	mu        sync.Mutex
+	events    []CaptureEvent
+	pos       int    // next event to play
+	pending   []byte // released, not yet read
+	ready     chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// release moves the recorded reads at pos into pending. Callers hold p.mu
+// or own p exclusively.
+func (p *replayPort) release() {
+	n := len(p.pending)
+	for p.pos < len(p.events) && !p.events[p.pos].Write {
+		p.pending = append(p.pending, p.events[p.pos].Data...)
+		p.pos++
+	}
+	if len(p.pending) > n {
+		select {
+		case p.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Read implements io.Reader. It returns (0, nil) after ReadTimeout when
+// nothing is pending, like a serial port with a read timeout.
+func (p *replayPort) Read(b []byte) (int, error) {
+	timer := time.NewTimer(p.replay.ReadTimeout)
+	defer timer.Stop()
+	for {
+		p.mu.Lock()
+		if len(p.pending) > 0 {
+			n := copy(b, p.pending)
+			p.pending = p.pending[n:]
+			p.mu.Unlock()
+			return n, nil
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-p.ready:
+		case <-p.closed:
+			return 0, io.EOF
+		case <-timer.C:
+			return 0, nil
+		}
+	}
+}
+
+// Write implements io.Writer. It checks b against the next recorded write
+// and releases the reads recorded after it.
+func (p *replayPort) Write(b []byte) (int, error) {
+	select {
+	case <-p.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pos >= len(p.events) {
+		p.replay.mismatch("capture %d: write %q after the end of the capture", p.index+1, b)
+		return len(b), nil
+	}
+	want := p.events[p.pos]
+	if !bytes.Equal(b, want.Data) {
+		p.replay.mismatch("capture %d at %.3fs: wrote %q, recorded %q", p.index+1, want.Offset.Seconds(), b, want.Data)
+	}
+	p.pos++
+	p.release()
+	return len(b), nil
+}
+
+// Close implements io.Closer.
+func (p *replayPort) Close() error {
+	p.closeOnce.Do(func() { close(p.closed) })
+	return nil
+}
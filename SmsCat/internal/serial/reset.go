@@ -0,0 +1,53 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Ping checks that the modem answers a bare AT, connecting first if
+// needed. A modem that does not answer is closed so the next use reopens
+// it. A locked SIM is not a failure: the modem itself answered.
+func (g *GSMModem) Ping() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.connect(); err != nil {
+		var lockErr *SIMLockError
+		if errors.As(err, &lockErr) {
+			return nil
+		}
+		return err
+	}
+	if _, err := g.exec("AT"); err != nil {
+		var atErr *ATError
+		if !errors.As(err, &atErr) {
+			g.close()
+		}
+		return fmt.Errorf("modem not responding: %w", err)
+	}
+	return nil
+}
+
+// Reset reboots the modem with AT+CFUN=1,1 and closes the port. The USB
+// device drops off the bus while it restarts and may come back under
+// another port name, so callers wait for it before reconnecting.
+func (g *GSMModem) Reset() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Only the handshake is needed; a locked SIM must not block the reset
+	g.dropLost()
+	if g.port == nil {
+		if err := g.open(); err != nil {
+			return err
+		}
+	}
+	g.log("Resetting modem (AT+CFUN=1,1)", false)
+	_, err := g.exec("AT+CFUN=1,1")
+	g.close()
+	if err != nil {
+		return fmt.Errorf("reset failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SIM states reported by AT+CPIN?.
+const (
+	SIMReady = "READY"
+	SIMPIN   = "SIM PIN"
+	SIMPUK   = "SIM PUK"
+)
+
+// simReadyTimeout is how long the SIM may take to become READY after a
+// code has been accepted.
+const simReadyTimeout = 10 * time.Second
+
+// PINCounter is the vendor command that reports remaining PIN/PUK
+// attempts. PIN and PUK index the comma-separated fields after Prefix.
+type PINCounter struct {
+	Command string
+	Prefix  string
+	PIN     int
+	PUK     int
+}
+
+// SIMAttempts are the remaining PIN and PUK tries, -1 when unknown.
+type SIMAttempts struct {
+	PIN int
+	PUK int
+}
+
+func (a SIMAttempts) String() string {
+	var parts []string
+	if a.PIN >= 0 {
+		parts = append(parts, fmt.Sprintf("%d PIN attempt(s) left", a.PIN))
+	}
+	if a.PUK >= 0 {
+		parts = append(parts, fmt.Sprintf("%d PUK attempt(s) left", a.PUK))
+	}
+	if len(parts) == 0 {
+		return "attempts unknown"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SIMStatus is the lock state of the SIM.
+type SIMStatus struct {
+	State    string
+	Attempts SIMAttempts
+}
+
+// SIMLockError is returned by Connect when the SIM needs a code that SMSCat
+// will not enter on its own.
+type SIMLockError struct {
+	State    string
+	Attempts SIMAttempts
+	Reason   string
+}
+
+func (e *SIMLockError) Error() string {
+	return fmt.Sprintf("SIM locked (%s, %s): %s", e.State, e.Attempts, e.Reason)
+}
+
+// SIMStatus reports the lock state and remaining attempts. It opens the
+// port if needed but neither unlocks nor initialises the modem.
+func (g *GSMModem) SIMStatus() (*SIMStatus, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.dropLost()
+	if g.port == nil {
+		if err := g.open(); err != nil {
+			return nil, err
+		}
+		defer g.close()
+	}
+	state, err := g.simState()
+	if err != nil {
+		return nil, err
+	}
+	return &SIMStatus{State: state, Attempts: g.simAttempts()}, nil
+}
+
+// UnlockSIM enters code as the PIN, or as the PUK together with newPIN when
+// the SIM is PUK-locked, then completes the connection. An accepted code
+// replaces the configured PIN for later reconnects.
+func (g *GSMModem) UnlockSIM(code string, newPIN string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.port != nil {
+		return nil // only a ready SIM stays connected
+	}
+	if err := g.open(); err != nil {
+		return err
+	}
+
+	state, err := g.simState()
+	if err != nil {
+		g.close()
+		return err
+	}
+	switch state {
+	case SIMReady:
+	case SIMPIN:
+		if err := g.enterPIN(code, ""); err != nil {
+			err = fmt.Errorf("PIN rejected (%s): %w", g.simAttempts(), err)
+			g.close()
+			return err
+		}
+		g.PIN = code
+	case SIMPUK:
+		if newPIN == "" {
+			g.close()
+			return fmt.Errorf("a new PIN is required to unlock with the PUK")
+		}
+		if err := g.enterPIN(code, newPIN); err != nil {
+			err = fmt.Errorf("PUK rejected (%s): %w", g.simAttempts(), err)
+			g.close()
+			return err
+		}
+		g.PIN = newPIN
+	default:
+		g.close()
+		return fmt.Errorf("SIM not ready: %s", state)
+	}
+	g.rejectedPIN = ""
+	g.log("SIM unlocked.", false)
+
+	g.close()
+	return g.connect()
+}
+
+// unlockSIM runs during connect. It enters the configured PIN when the SIM
+// asks for one, but never a PIN that was already rejected or the last
+// remaining attempt, and never a PUK: those need an operator.
+func (g *GSMModem) unlockSIM() error {
+	state, err := g.simState()
+	if err != nil {
+		return fmt.Errorf("SIM not ready: %w", err)
+	}
+
+	switch state {
+	case SIMReady:
+		return nil
+	case SIMPIN:
+		attempts := g.simAttempts()
+		switch {
+		case g.PIN == "":
+			return &SIMLockError{State: state, Attempts: attempts, Reason: "no PIN configured"}
+		case g.PIN == g.rejectedPIN:
+			return &SIMLockError{State: state, Attempts: attempts, Reason: "configured PIN was rejected, enter the PIN from the UI"}
+		case attempts.PIN == 1:
+			return &SIMLockError{State: state, Attempts: attempts, Reason: "not risking the last attempt, enter the PIN from the UI"}
+		}
+		g.log(fmt.Sprintf("SIM requires a PIN (%s), entering configured PIN...", attempts), false)
+		if err := g.enterPIN(g.PIN, ""); err != nil {
+			var atErr *ATError
+			if !errors.As(err, &atErr) {
+				return err
+			}
+			g.rejectedPIN = g.PIN
+			return &SIMLockError{State: state, Attempts: g.simAttempts(), Reason: "configured PIN was rejected"}
+		}
+		g.log("SIM PIN accepted.", false)
+		return nil
+	case SIMPUK:
+		return &SIMLockError{State: state, Attempts: g.simAttempts(), Reason: "PUK required, enter it from the UI"}
+	}
+	return fmt.Errorf("SIM not ready: %s", state)
+}
+
+// simState returns the AT+CPIN? state, e.g. "READY" or "SIM PIN".
+func (g *GSMModem) simState() (string, error) {
+	resp, err := g.exec("AT+CPIN?")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimPrefix(resp.Line("+CPIN:"), "+CPIN:")), nil
+}
+
+// simAttempts queries the profile's PIN counter, if it has one.
+func (g *GSMModem) simAttempts() SIMAttempts {
+	attempts := SIMAttempts{PIN: -1, PUK: -1}
+	counter := g.profile().PINCounter
+	if counter.Command == "" {
+		return attempts
+	}
+	resp, err := g.exec(counter.Command)
+	if err != nil {
+		return attempts
+	}
+	fields := splitInfoLine(resp.Line(counter.Prefix), counter.Prefix)
+	field := func(i int) int {
+		if i < 0 || i >= len(fields) {
+			return -1
+		}
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	attempts.PIN = field(counter.PIN)
+	attempts.PUK = field(counter.PUK)
+	return attempts
+}
+
+// enterPIN sends AT+CPIN with the PIN, or the PUK and a new PIN, and waits
+// for the SIM to become ready.
+func (g *GSMModem) enterPIN(code string, newPIN string) error {
+	cmd := fmt.Sprintf("AT+CPIN=\"%s\"", code)
+	if newPIN != "" {
+		cmd = fmt.Sprintf("AT+CPIN=\"%s\",\"%s\"", code, newPIN)
+	}
+	if _, err := g.exec(cmd); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(simReadyTimeout)
+	for {
+		// The SIM answers "SIM busy" errors while it initialises
+		if state, err := g.simState(); err == nil && state == SIMReady {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("SIM did not become ready after unlocking")
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
@@ -0,0 +1,107 @@
+package serial
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// pinEntries counts the AT+CPIN=<code> commands the simulator received.
+func pinEntries(sim *Simulator) int {
+	n := 0
+	for _, cmd := range sim.Received() {
+		if strings.HasPrefix(cmd, "AT+CPIN=") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestConnectSIMLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    string
+		attempts int // PIN attempts left
+		pin      string
+		reason   string // "" connects
+		entries  int    // codes sent over both connects
+	}{
+		{"correct PIN unlocks", SIMPIN, 3, "1234", "", 1},
+		{"wrong PIN not retried", SIMPIN, 3, "0000", "configured PIN was rejected", 1},
+		{"last attempt kept", SIMPIN, 1, "1234", "not risking the last attempt", 0},
+		{"no PIN configured", SIMPIN, 3, "", "no PIN configured", 0},
+		{"PUK never entered", SIMPUK, 0, "1234", "PUK required", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := NewSimulator()
+			sim.SIMStatus, sim.PIN, sim.PUK = tt.state, "1234", "87654321"
+			sim.PINAttempts = tt.attempts
+			g := newTestModem(t, sim)
+			g.PIN = tt.pin
+
+			// The second connect is what a reconnecting worker does
+			for i := 0; i < 2; i++ {
+				err := g.Connect()
+				if tt.reason == "" {
+					if err != nil {
+						t.Fatalf("connect %d: %v", i+1, err)
+					}
+					continue
+				}
+				var lockErr *SIMLockError
+				if !errors.As(err, &lockErr) {
+					t.Fatalf("connect %d: err = %v, want a SIMLockError", i+1, err)
+				}
+				if lockErr.State != tt.state || !strings.Contains(lockErr.Reason, tt.reason) {
+					t.Errorf("connect %d: %v, want %s: %s", i+1, lockErr, tt.state, tt.reason)
+				}
+				if g.IsConnected() {
+					t.Errorf("connect %d: port left open on a locked SIM", i+1)
+				}
+			}
+			if n := pinEntries(sim); n != tt.entries {
+				t.Errorf("entered a code %d times, want %d", n, tt.entries)
+			}
+		})
+	}
+}
+
+func TestUnlockSIMAfterRejectedPIN(t *testing.T) {
+	sim := NewSimulator()
+	sim.SIMStatus, sim.PIN = SIMPIN, "1234"
+	g := newTestModem(t, sim)
+	g.PIN = "0000"
+	if err := g.Connect(); err == nil {
+		t.Fatal("connected with a wrong PIN")
+	}
+
+	if err := g.UnlockSIM("1111", ""); err == nil {
+		t.Fatal("UnlockSIM accepted a wrong PIN")
+	}
+	if err := g.UnlockSIM("1234", ""); err != nil {
+		t.Fatalf("UnlockSIM: %v", err)
+	}
+	if !g.IsConnected() || g.PIN != "1234" {
+		t.Errorf("after unlock: connected %v, PIN %q", g.IsConnected(), g.PIN)
+	}
+	if n := pinEntries(sim); n != 3 {
+		t.Errorf("entered a code %d times, want 3", n)
+	}
+}
+
+func TestUnlockSIMWithPUK(t *testing.T) {
+	sim := NewSimulator()
+	sim.SIMStatus, sim.PIN, sim.PUK = SIMPUK, "1234", "87654321"
+	g := newTestModem(t, sim)
+
+	if err := g.UnlockSIM("87654321", ""); err == nil || !strings.Contains(err.Error(), "new PIN") {
+		t.Errorf("UnlockSIM without a new PIN: %v", err)
+	}
+	if err := g.UnlockSIM("87654321", "4321"); err != nil {
+		t.Fatalf("UnlockSIM: %v", err)
+	}
+	if !g.IsConnected() || g.PIN != "4321" {
+		t.Errorf("after unlock: connected %v, PIN %q", g.IsConnected(), g.PIN)
+	}
+}
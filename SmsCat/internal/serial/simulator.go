@@ -0,0 +1,622 @@
+package serial
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SimHandler produces the reply lines for a command. Returning nil means
+// the simulator falls back to its built-in behaviour.
+type SimHandler func(cmd string) []string
+
+// Simulator is an in-process AT command modem. It implements
+// io.ReadWriteCloser so it can be plugged into GSMModem.OpenFunc, and it
+// answers the commands SMSCat uses during init and sending.
+//
+// Replies are framed the way a real modem frames them ("\r\n<line>\r\n"),
+// echo is on until ATE0, and AT+CMGS answers with a "> " prompt before it
+// accepts the PDU terminated by Ctrl+Z (or cancelled by ESC).
+type Simulator struct {
+	// ResponseDelay is applied before every reply.
+	ResponseDelay time.Duration
+	// SendDelay is applied between the Ctrl+Z and the +CMGS reply.
+	SendDelay time.Duration
+	// ReadTimeout bounds how long Read blocks when no data is pending.
+	ReadTimeout time.Duration
+	// SIMStatus is the value reported by AT+CPIN? (default "READY").
+	// Set it to "SIM PIN" together with PIN to simulate a locked SIM.
+	SIMStatus string
+	// PIN and PUK are the codes AT+CPIN accepts. PINAttempts/PUKAttempts
+	// count down on wrong codes (defaults 3 and 10) and are reported by
+	// AT+QPINC="SC"; running out of PIN attempts switches to "SIM PUK".
+	PIN         string
+	PUK         string
+	PINAttempts int
+	PUKAttempts int
+	// CSQ is the +CSQ <rssi> value (default 20, 99 = unknown).
+	CSQ int
+	// RegStat is the <stat> reported by AT+CREG? and AT+CEREG? (default 1).
+	RegStat int
+	// Operator is the name reported by AT+COPS? (default "SIMNET").
+	Operator string
+	// SMSC is the service centre stored on the SIM, read and written with
+	// AT+CSCA in the character set selected by AT+CSCS.
+	SMSC string
+	// CallResponse decides how ATD<number>; calls go: "" answers after
+	// RingDelay, otherwise the line sent instead ("BUSY", "NO ANSWER",
+	// "NO CARRIER"). DTMFKey, when set, is pressed once after answering
+	// and reported as +QTONEDET.
+	CallResponse string
+	RingDelay    time.Duration
+	DTMFKey      string
+	// USSDReply is the text the network answers every USSD request with
+	// (+CUSD: 0,"<text>",15), in the character set selected by AT+CSCS.
+	USSDReply string
+	// Messages is the message storage listed by AT+CMGL=4 and emptied by
+	// AT+CMGD, keyed by index. StorageSize is its capacity for AT+CPMS
+	// (default 50).
+	Messages    map[int]SimMessage
+	StorageSize int
+	// Resets counts the AT+CFUN=1,1 reboots received.
+	Resets int
+	// Info is what the identification commands report (AT+CGMM, AT+CGMR,
+	// AT+CGSN, AT+CIMI, AT+QCCID/AT+CCID, AT+CNUM); empty values answer
+	// ERROR, an empty Number lists no number.
+	Info ModemInfo
+	// ClockOffset shifts the time AT+CCLK? reports from the host clock.
+	// It is reported in UTC+8.
+	ClockOffset time.Duration
+
+	mu        sync.Mutex
+	echo      bool
+	pduMode   bool
+	charset   string
+	inPrompt  bool
+	lineBuf   bytes.Buffer
+	out       chan []byte
+	pending   []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+	handlers  []simHandlerEntry
+	failures  []*simFailure
+	sendFails []int
+	nextMR    int
+	callStat  int // +CLCC <stat> of the current call, -1 when idle
+	storage   string
+	received  []string
+	sent      []string
+}
+
+// SimMessage is a stored message: its <stat> and PDU hex.
+type SimMessage struct {
+	Stat int
+	PDU  string
+}
+
+type simHandlerEntry struct {
+	prefix string
+	fn     SimHandler
+}
+
+type simFailure struct {
+	prefix string
+	reply  []string
+	times  int
+}
+
+// simInfo is the identity a new simulator reports.
+var simInfo = ModemInfo{
+	Model:    "EC25",
+	Revision: "EC25EFAR06A03M4G",
+	IMEI:     "867698040000001",
+	IMSI:     "460001234567890",
+	ICCID:    "89860012345678901234",
+}
+
+// NewSimulator returns a simulator with a ready SIM and no delays.
+func NewSimulator() *Simulator {
+	return &Simulator{
+		ReadTimeout: 100 * time.Millisecond,
+		SIMStatus:   "READY",
+		PINAttempts: 3,
+		PUKAttempts: 10,
+		CSQ:         20,
+		RegStat:     1,
+		Operator:    "SIMNET",
+		callStat:    -1,
+		Messages:    make(map[int]SimMessage),
+		StorageSize: 50,
+		storage:     "SM",
+		Info:        simInfo,
+		echo:        true,
+		out:         make(chan []byte, 256),
+		closed:      make(chan struct{}),
+	}
+}
+
+// Open is a GSMModem.OpenFunc that always returns this simulator. Opening
+// a closed simulator re-attaches it, like re-opening a COM port.
+func (s *Simulator) Open(name string, baud int) (io.ReadWriteCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.closed:
+		s.closed = make(chan struct{})
+		s.closeOnce = sync.Once{}
+		s.pending = nil
+		s.inPrompt = false
+		s.lineBuf.Reset()
+	default:
+	}
+	return s, nil
+}
+
+// Handle registers a scripted reply for commands starting with prefix.
+// Handlers are matched in registration order, before the built-ins.
+func (s *Simulator) Handle(prefix string, fn SimHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, simHandlerEntry{prefix: strings.ToUpper(prefix), fn: fn})
+}
+
+// FailCommand makes the next times commands starting with prefix answer
+// with reply instead of their normal response. times <= 0 fails forever.
+func (s *Simulator) FailCommand(prefix string, times int, reply ...string) {
+	if len(reply) == 0 {
+		reply = []string{"ERROR"}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &simFailure{prefix: strings.ToUpper(prefix), reply: reply, times: times})
+}
+
+// FailSends makes the next PDU submissions answer "+CMS ERROR: <code>",
+// one entry per submission.
+func (s *Simulator) FailSends(codes ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendFails = append(s.sendFails, codes...)
+}
+
+// Inject queues unsolicited lines (e.g. "+CMTI: \"SM\",3") for the reader.
+func (s *Simulator) Inject(lines ...string) {
+	s.reply(0, lines...)
+}
+
+// Received returns every command line the simulator has seen.
+func (s *Simulator) Received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.received...)
+}
+
+// SentPDUs returns the hex PDUs accepted through AT+CMGS.
+func (s *Simulator) SentPDUs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.sent...)
+}
+
+// done returns the channel closed by the current Close.
+func (s *Simulator) done() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Read implements io.Reader. It returns (0, nil) after ReadTimeout when
+// nothing is pending, like a serial port with a read timeout.
+func (s *Simulator) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	if len(s.pending) > 0 {
+		n := copy(p, s.pending)
+		s.pending = s.pending[n:]
+		s.mu.Unlock()
+		return n, nil
+	}
+	closed := s.closed
+	s.mu.Unlock()
+
+	timer := time.NewTimer(s.ReadTimeout)
+	defer timer.Stop()
+	select {
+	case data := <-s.out:
+		// Drain whatever else is already buffered, like a UART FIFO.
+		for more := true; more; {
+			select {
+			case next := <-s.out:
+				data = append(data, next...)
+			default:
+				more = false
+			}
+		}
+		n := copy(p, data)
+		if n < len(data) {
+			s.mu.Lock()
+			s.pending = append(s.pending, data[n:]...)
+			s.mu.Unlock()
+		}
+		return n, nil
+	case <-closed:
+		return 0, io.EOF
+	case <-timer.C:
+		return 0, nil
+	}
+}
+
+// Write implements io.Writer and feeds the command interpreter.
+func (s *Simulator) Write(p []byte) (int, error) {
+	select {
+	case <-s.done():
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	for _, b := range p {
+		s.feed(b)
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer. Open re-attaches a closed simulator.
+func (s *Simulator) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeOnce.Do(func() { close(s.closed) })
+	return nil
+}
+
+func (s *Simulator) feed(b byte) {
+	s.mu.Lock()
+	if s.inPrompt {
+		switch b {
+		case 0x1A: // Ctrl+Z submits the PDU
+			pdu := strings.TrimSpace(s.lineBuf.String())
+			s.lineBuf.Reset()
+			s.inPrompt = false
+			s.mu.Unlock()
+			s.submit(pdu)
+			return
+		case 0x1B: // ESC cancels the prompt
+			s.lineBuf.Reset()
+			s.inPrompt = false
+			s.mu.Unlock()
+			s.reply(s.ResponseDelay, "OK")
+			return
+		}
+		s.lineBuf.WriteByte(b)
+		s.mu.Unlock()
+		return
+	}
+
+	if b == '\r' || b == '\n' {
+		cmd := strings.TrimSpace(s.lineBuf.String())
+		s.lineBuf.Reset()
+		echo := s.echo
+		s.mu.Unlock()
+		if cmd == "" {
+			return
+		}
+		if echo {
+			s.emit([]byte(cmd + "\r"))
+		}
+		s.execute(cmd)
+		return
+	}
+	s.lineBuf.WriteByte(b)
+	s.mu.Unlock()
+}
+
+func (s *Simulator) execute(cmd string) {
+	upper := strings.ToUpper(cmd)
+
+	s.mu.Lock()
+	s.received = append(s.received, cmd)
+	for i, f := range s.failures {
+		if strings.HasPrefix(upper, f.prefix) {
+			if f.times > 0 {
+				f.times--
+				if f.times == 0 {
+					s.failures = append(s.failures[:i], s.failures[i+1:]...)
+				}
+			}
+			reply := f.reply
+			s.mu.Unlock()
+			s.reply(s.ResponseDelay, reply...)
+			return
+		}
+	}
+	handlers := append([]simHandlerEntry(nil), s.handlers...)
+	s.mu.Unlock()
+
+	for _, h := range handlers {
+		if strings.HasPrefix(upper, h.prefix) {
+			if lines := h.fn(cmd); lines != nil {
+				s.reply(s.ResponseDelay, lines...)
+				return
+			}
+		}
+	}
+
+	s.reply(s.ResponseDelay, s.builtin(upper)...)
+}
+
+// enterPIN checks AT+CPIN=<pin> or AT+CPIN=<puk>,<newpin>. s.mu is held.
+func (s *Simulator) enterPIN(codes []string) []string {
+	const wrong = "+CME ERROR: incorrect password"
+	switch {
+	case s.SIMStatus == "SIM PIN" && len(codes) == 1:
+		if codes[0] == s.PIN {
+			s.SIMStatus, s.PINAttempts = "READY", 3
+			return []string{"OK"}
+		}
+		if s.PINAttempts--; s.PINAttempts <= 0 {
+			s.SIMStatus = "SIM PUK"
+		}
+		return []string{wrong}
+	case s.SIMStatus == "SIM PUK" && len(codes) == 2:
+		if codes[0] == s.PUK {
+			s.SIMStatus, s.PIN = "READY", codes[1]
+			s.PINAttempts, s.PUKAttempts = 3, 10
+			return []string{"OK"}
+		}
+		s.PUKAttempts--
+		return []string{wrong}
+	}
+	return []string{"+CME ERROR: operation not allowed"}
+}
+
+// builtin implements the default answers for the commands SMSCat sends.
+func (s *Simulator) builtin(cmd string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case cmd == "AT":
+		return []string{"OK"}
+	case cmd == "ATE0":
+		s.echo = false
+		return []string{"OK"}
+	case cmd == "ATE1":
+		s.echo = true
+		return []string{"OK"}
+	case cmd == "AT+CPIN?":
+		return []string{"+CPIN: " + s.SIMStatus, "OK"}
+	case strings.HasPrefix(cmd, "AT+CPIN="):
+		return s.enterPIN(strings.Split(strings.ReplaceAll(cmd[len("AT+CPIN="):], "\"", ""), ","))
+	case cmd == "AT+QPINC=\"SC\"":
+		return []string{fmt.Sprintf("+QPINC: \"SC\",%d,%d", s.PINAttempts, s.PUKAttempts), "OK"}
+	case strings.HasPrefix(cmd, "AT+CSCS="):
+		s.charset = strings.Trim(cmd[len("AT+CSCS="):], "\"")
+		return []string{"OK"}
+	case cmd == "AT+CSCA?":
+		sca := s.SMSC
+		if s.charset == "UCS2" {
+			sca = encodeUCS2(sca)
+		}
+		toa := 129
+		if strings.HasPrefix(s.SMSC, "+") {
+			toa = 145
+		}
+		return []string{fmt.Sprintf("+CSCA: \"%s\",%d", sca, toa), "OK"}
+	case strings.HasPrefix(cmd, "AT+CSCA="):
+		sca := strings.Trim(strings.Split(cmd[len("AT+CSCA="):], ",")[0], "\"")
+		if s.charset == "UCS2" {
+			sca = decodeUCS2Hex(sca)
+		}
+		s.SMSC = sca
+		return []string{"OK"}
+	case cmd == "AT+CSQ":
+		return []string{fmt.Sprintf("+CSQ: %d,99", s.CSQ), "OK"}
+	case cmd == "AT+CREG?", cmd == "AT+CEREG?":
+		return []string{fmt.Sprintf("%s: 0,%d", cmd[2:len(cmd)-1], s.RegStat), "OK"}
+	case cmd == "AT+COPS?":
+		if s.RegStat != 1 && s.RegStat != 5 {
+			return []string{"+COPS: 0", "OK"}
+		}
+		return []string{fmt.Sprintf("+COPS: 0,0,\"%s\",7", s.Operator), "OK"}
+	case cmd == "AT+CMGF=0":
+		s.pduMode = true
+		return []string{"OK"}
+	case cmd == "AT+CMGF=1":
+		s.pduMode = false
+		return []string{"OK"}
+	case strings.HasPrefix(cmd, "AT+CMGS="):
+		if !s.pduMode {
+			return []string{"+CMS ERROR: 302"}
+		}
+		s.inPrompt = true
+		s.lineBuf.Reset()
+		delay := s.ResponseDelay
+		go func() {
+			time.Sleep(delay)
+			s.emit([]byte("\r\n> "))
+		}()
+		return nil
+	case strings.HasPrefix(cmd, "ATD") && strings.HasSuffix(cmd, ";"):
+		if s.callStat >= 0 {
+			return []string{"+CME ERROR: operation not allowed"}
+		}
+		s.callStat = 3
+		time.AfterFunc(s.RingDelay, s.ring)
+		return []string{"OK"}
+	case strings.HasPrefix(cmd, "AT+CUSD=1,"):
+		text := s.USSDReply
+		if s.charset == "UCS2" {
+			text = encodeUCS2(text)
+		}
+		s.reply(s.ResponseDelay+100*time.Millisecond, fmt.Sprintf("+CUSD: 0,\"%s\",15", text))
+		return []string{"OK"}
+	case cmd == "AT+CUSD=2":
+		return []string{"OK"}
+	case cmd == "AT+CFUN=1,1":
+		s.Resets++
+		return []string{"OK"}
+	case cmd == "AT+CPMS?":
+		n := len(s.Messages)
+		mem := s.storage
+		if s.charset == "UCS2" {
+			mem = encodeUCS2(mem)
+		}
+		return []string{fmt.Sprintf("+CPMS: \"%[1]s\",%[2]d,%[3]d,\"%[1]s\",%[2]d,%[3]d,\"%[1]s\",%[2]d,%[3]d", mem, n, s.StorageSize), "OK"}
+	case strings.HasPrefix(cmd, "AT+CPMS="):
+		mem := strings.Trim(strings.Split(cmd[len("AT+CPMS="):], ",")[0], "\"")
+		if s.charset == "UCS2" {
+			mem = decodeUCS2Hex(mem)
+		}
+		if !validStorage(mem) {
+			return []string{"+CMS ERROR: 302"}
+		}
+		s.storage = mem
+		n := len(s.Messages)
+		return []string{fmt.Sprintf("+CPMS: %[1]d,%[2]d,%[1]d,%[2]d,%[1]d,%[2]d", n, s.StorageSize), "OK"}
+	case cmd == "AT+CMGL=4":
+		if !s.pduMode {
+			return []string{"+CMS ERROR: 302"}
+		}
+		var indexes []int
+		for i := range s.Messages {
+			indexes = append(indexes, i)
+		}
+		sort.Ints(indexes)
+		var lines []string
+		for _, i := range indexes {
+			m := s.Messages[i]
+			lines = append(lines, fmt.Sprintf("+CMGL: %d,%d,,%d", i, m.Stat, len(m.PDU)/2), m.PDU)
+			if m.Stat == StatUnread {
+				m.Stat = StatRead
+				s.Messages[i] = m
+			}
+		}
+		return append(lines, "OK")
+	case strings.HasPrefix(cmd, "AT+CMGD="):
+		i, err := strconv.Atoi(strings.Split(cmd[len("AT+CMGD="):], ",")[0])
+		if _, ok := s.Messages[i]; err != nil || !ok {
+			return []string{"+CMS ERROR: 321"}
+		}
+		delete(s.Messages, i)
+		return []string{"OK"}
+	case cmd == "AT+CGMM":
+		return simValue("", s.Info.Model)
+	case cmd == "AT+CGMR":
+		return simValue("Revision: ", s.Info.Revision)
+	case cmd == "AT+CGSN":
+		return simValue("", s.Info.IMEI)
+	case cmd == "AT+CIMI":
+		return simValue("", s.Info.IMSI)
+	case cmd == "AT+QCCID":
+		return simValue("+QCCID: ", s.Info.ICCID)
+	case cmd == "AT+CCID":
+		return simValue("+CCID: ", s.Info.ICCID)
+	case cmd == "AT+CNUM":
+		if s.Info.Number == "" {
+			return []string{"OK"}
+		}
+		number := s.Info.Number
+		if s.charset == "UCS2" {
+			number = encodeUCS2(number)
+		}
+		return []string{fmt.Sprintf("+CNUM: \"\",\"%s\",145", number), "OK"}
+	case cmd == "AT+CCLK?":
+		t := time.Now().Add(s.ClockOffset).In(time.FixedZone("", 8*3600))
+		return []string{fmt.Sprintf("+CCLK: \"%s+32\"", t.Format("06/01/02,15:04:05")), "OK"}
+	case cmd == "ATH":
+		s.callStat = -1
+		return []string{"OK"}
+	case cmd == "AT+CLCC":
+		if s.callStat < 0 {
+			return []string{"OK"}
+		}
+		return []string{fmt.Sprintf("+CLCC: 1,0,%d,0,0,\"\",129", s.callStat), "OK"}
+	case strings.HasPrefix(cmd, "AT+CMEE="),
+		strings.HasPrefix(cmd, "AT+QTONEDET="),
+		strings.HasPrefix(cmd, "AT+CSMP="),
+		strings.HasPrefix(cmd, "AT+CGSMS="),
+		strings.HasPrefix(cmd, "AT+CNMI="),
+		strings.HasPrefix(cmd, "AT+CTZU="):
+		return []string{"OK"}
+	}
+	return []string{"ERROR"}
+}
+
+// simValue answers an identification command with label+value, or ERROR
+// when value is empty.
+func simValue(label string, value string) []string {
+	if value == "" {
+		return []string{"ERROR"}
+	}
+	return []string{label + value, "OK"}
+}
+
+// ring ends the alerting phase of a call: it is answered, or ended with
+// CallResponse.
+func (s *Simulator) ring() {
+	s.mu.Lock()
+	if s.callStat != 3 {
+		s.mu.Unlock()
+		return // hung up while ringing
+	}
+	if s.CallResponse != "" {
+		s.callStat = -1
+		line := s.CallResponse
+		s.mu.Unlock()
+		s.reply(0, line)
+		return
+	}
+	s.callStat = 0
+	key := s.DTMFKey
+	s.mu.Unlock()
+	if key != "" {
+		s.reply(s.ResponseDelay+200*time.Millisecond, fmt.Sprintf("+QTONEDET: %d", key[0]))
+	}
+}
+
+func (s *Simulator) submit(pdu string) {
+	s.mu.Lock()
+	code := 0
+	if len(s.sendFails) > 0 {
+		code = s.sendFails[0]
+		s.sendFails = s.sendFails[1:]
+	}
+	if code == 0 {
+		s.sent = append(s.sent, pdu)
+	}
+	s.nextMR = (s.nextMR + 1) & 0xFF
+	mr := s.nextMR
+	s.mu.Unlock()
+
+	if code != 0 {
+		s.reply(s.ResponseDelay+s.SendDelay, fmt.Sprintf("+CMS ERROR: %d", code))
+		return
+	}
+	s.reply(s.ResponseDelay+s.SendDelay, fmt.Sprintf("+CMGS: %d", mr), "OK")
+}
+
+// reply frames lines and delivers them after delay without blocking the writer.
+func (s *Simulator) reply(delay time.Duration, lines ...string) {
+	if len(lines) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.WriteString("\r\n" + l + "\r\n")
+	}
+	data := buf.Bytes()
+	if delay <= 0 {
+		s.emit(data)
+		return
+	}
+	time.AfterFunc(delay, func() { s.emit(data) })
+}
+
+func (s *Simulator) emit(data []byte) {
+	select {
+	case s.out <- data:
+	case <-s.done():
+	}
+}
@@ -0,0 +1,153 @@
+package serial
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"smallNfast/internal/pdu"
+)
+
+// QuerySMSC reads the service centre address stored on the SIM (AT+CSCA?).
+// An empty address means the SIM has none.
+func (g *GSMModem) QuerySMSC() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.connect(); err != nil {
+		return "", err
+	}
+	resp, err := g.exec("AT+CSCA?")
+	if err != nil {
+		return "", fmt.Errorf("failed to read SMSC: %w", err)
+	}
+	return g.parseCSCA(resp.Line("+CSCA:")), nil
+}
+
+// SetSMSC stores number as the SIM's service centre address (AT+CSCA).
+func (g *GSMModem) SetSMSC(number string) error {
+	digits, international, err := pdu.NormalizeNumber(number)
+	if err != nil {
+		return fmt.Errorf("SMSC: %w", err)
+	}
+	if digits == "" {
+		return fmt.Errorf("SMSC: empty number")
+	}
+	addr, toa := digits, pdu.TOAUnknown
+	if international {
+		addr, toa = "+"+digits, pdu.TOAInternational
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.connect(); err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf("AT+CSCA=\"%s\",%d", g.encodeString(addr), toa)
+	if _, err := g.exec(cmd); err != nil {
+		return fmt.Errorf("failed to set SMSC: %w", err)
+	}
+	g.log(fmt.Sprintf("SMSC set to %s", addr), false)
+	return nil
+}
+
+// parseCSCA reads the address from "+CSCA: "<sca>",<tosca>".
+func (g *GSMModem) parseCSCA(line string) string {
+	fields := splitInfoLine(line, "+CSCA:")
+	if len(fields) == 0 {
+		return ""
+	}
+	return g.decodeString(strings.Trim(fields[0], "\""))
+}
+
+// encodeString converts a string parameter to the TE character set chosen
+// with AT+CSCS during init.
+func (g *GSMModem) encodeString(s string) string {
+	if g.charset == "UCS2" {
+		return encodeUCS2(s)
+	}
+	return s
+}
+
+// decodeString reverses encodeString for strings read from the modem.
+func (g *GSMModem) decodeString(s string) string {
+	if g.charset != "UCS2" {
+		return s
+	}
+	return decodeUCS2Hex(s)
+}
+
+// encodeUCS2 converts a string to UCS2 Hex string (Big Endian)
+func encodeUCS2(s string) string {
+	runes := []rune(s)
+	var sb strings.Builder
+	for _, r := range runes {
+		sb.WriteString(fmt.Sprintf("%04X", r))
+	}
+	return sb.String()
+}
+
+// decodeUCS2Hex decodes big-endian UCS2 hex; anything else is returned
+// unchanged.
+func decodeUCS2Hex(s string) string {
+	if len(s)%4 != 0 {
+		return s
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return s
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// cmsHint explains +CMS ERROR codes that point at the SMSC.
+func cmsHint(final string) string {
+	code := strings.TrimSpace(strings.TrimPrefix(final, "+CMS ERROR:"))
+	switch strings.ToUpper(code) {
+	case "330", "SMSC ADDRESS UNKNOWN":
+		return " (no SMSC on the SIM: set one in the UI or sms.smsc)"
+	case "331", "NO NETWORK SERVICE":
+		return " (no network service)"
+	case "38", "NETWORK OUT OF ORDER":
+		return " (SMSC unreachable: check the SMSC address)"
+	}
+	return ""
+}
+
+// rejectCauses are the +CMS ERROR causes (3GPP 24.011 RP-causes, 23.040
+// TP-FCS and 27.005) that refuse the recipient or the message itself.
+var rejectCauses = map[string]bool{
+	"1": true, "UNASSIGNED (UNALLOCATED) NUMBER": true,
+	"8": true, "OPERATOR DETERMINED BARRING": true,
+	"10": true, "CALL BARRED": true,
+	"21": true, "SHORT MESSAGE TRANSFER REJECTED": true,
+	"29": true, "FACILITY REJECTED": true,
+	"96": true, "INVALID MANDATORY INFORMATION": true,
+	"176": true, "TPDU NOT SUPPORTED": true,
+	"195": true, "INVALID SME ADDRESS": true,
+	"196": true, "DESTINATION SME BARRED": true,
+	"304": true, "INVALID PDU MODE PARAMETER": true,
+}
+
+// IsRejected reports whether err is a +CMS ERROR refusing the recipient
+// or the message, e.g. an unassigned number or a barred destination.
+// Another modem would be refused the same way.
+func IsRejected(err error) bool {
+	var atErr *ATError
+	if !errors.As(err, &atErr) || !strings.HasPrefix(atErr.Final, "+CMS ERROR:") {
+		return false
+	}
+	code := strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(atErr.Final, "+CMS ERROR:")))
+	if n, err := strconv.Atoi(code); err == nil && n >= 128 && n <= 159 {
+		return true // TP-PID and TP-DCS errors
+	}
+	return rejectCauses[code]
+}
@@ -0,0 +1,236 @@
+package serial
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"smallNfast/internal/pdu"
+)
+
+// Message storages selectable with AT+CPMS.
+var storageNames = []string{"SM", "ME", "MT", "SR", "BM"}
+
+// <stat> values of stored messages in PDU mode.
+const (
+	StatUnread = 0
+	StatRead   = 1
+	StatUnsent = 2
+	StatSent   = 3
+)
+
+// PDU types stored on the SIM, from the TP-MTI of the first octet.
+const (
+	PDUDeliver      = "deliver"
+	PDUSubmit       = "submit"
+	PDUStatusReport = "status-report"
+)
+
+// MemoryUsage is the fill level of one message storage.
+type MemoryUsage struct {
+	Name  string
+	Used  int
+	Total int
+}
+
+// Percent returns how full the storage is, 0 when its size is unknown.
+func (m MemoryUsage) Percent() int {
+	if m.Total <= 0 {
+		return 0
+	}
+	return m.Used * 100 / m.Total
+}
+
+func (m MemoryUsage) String() string {
+	return fmt.Sprintf("%s %d/%d", m.Name, m.Used, m.Total)
+}
+
+// StorageStatus is the answer to AT+CPMS?: the storages messages are read
+// and deleted from, written to, and received into.
+type StorageStatus struct {
+	Read    MemoryUsage
+	Write   MemoryUsage
+	Receive MemoryUsage
+}
+
+// StoredMessage is one entry of the preferred storage, of any type.
+type StoredMessage struct {
+	Index     int
+	Status    int       // StatUnread .. StatSent
+	Type      string    // PDUDeliver, PDUSubmit or PDUStatusReport
+	Timestamp time.Time // service centre time stamp; zero for submits
+}
+
+// storedPDU is a raw AT+CMGL entry.
+type storedPDU struct {
+	index int
+	stat  int
+	pdu   string
+}
+
+// QueryStorage reports the selected storages and their fill levels.
+func (g *GSMModem) QueryStorage() (*StorageStatus, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.connect(); err != nil {
+		return nil, err
+	}
+	resp, err := g.exec("AT+CPMS?")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message storage: %w", err)
+	}
+	return g.parseCPMS(resp.Line("+CPMS:"))
+}
+
+// SetStorage selects mem (SM, ME, MT, ...) for reading, writing and
+// receiving messages. The choice is kept and applied again on reconnect.
+func (g *GSMModem) SetStorage(mem string) error {
+	mem = strings.ToUpper(strings.TrimSpace(mem))
+	if !validStorage(mem) {
+		return fmt.Errorf("unknown message storage %q (want one of %s)", mem, strings.Join(storageNames, ", "))
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.Storage = mem
+	if err := g.connect(); err != nil {
+		return err
+	}
+	return g.selectStorage()
+}
+
+// selectStorage applies g.Storage, in the TE character set like every
+// string parameter. It does not take g.mu; callers hold it.
+func (g *GSMModem) selectStorage() error {
+	if g.Storage == "" {
+		return nil
+	}
+	mem := g.encodeString(g.Storage)
+	cmd := fmt.Sprintf("AT+CPMS=\"%s\",\"%s\",\"%s\"", mem, mem, mem)
+	if _, err := g.exec(cmd); err != nil {
+		return fmt.Errorf("failed to select message storage %s: %w", g.Storage, err)
+	}
+	return nil
+}
+
+func validStorage(mem string) bool {
+	for _, name := range storageNames {
+		if mem == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ListStored lists every message in the preferred storage with its status,
+// type and time stamp, including sent messages and status reports that
+// ListMessages skips.
+func (g *GSMModem) ListStored() ([]StoredMessage, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entries, err := g.listPDUs()
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]StoredMessage, 0, len(entries))
+	for _, e := range entries {
+		msgs = append(msgs, describeStored(e))
+	}
+	return msgs, nil
+}
+
+// listPDUs runs AT+CMGL=4 in PDU mode. It does not take g.mu; callers
+// hold it.
+func (g *GSMModem) listPDUs() ([]storedPDU, error) {
+	if err := g.connect(); err != nil {
+		return nil, err
+	}
+	if _, err := g.exec("AT+CMGF=0"); err != nil {
+		return nil, fmt.Errorf("failed to set PDU mode: %w", err)
+	}
+	resp, err := g.exec("AT+CMGL=4")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	var entries []storedPDU
+	for i := 0; i < len(resp.Lines); i++ {
+		header := resp.Lines[i]
+		if !strings.HasPrefix(header, "+CMGL:") || i+1 >= len(resp.Lines) {
+			continue
+		}
+		i++
+		index, stat, err := parseCMGLHeader(header)
+		if err != nil {
+			g.log(fmt.Sprintf("Inbox: %v", err), true)
+			continue
+		}
+		entries = append(entries, storedPDU{index: index, stat: stat, pdu: resp.Lines[i]})
+	}
+	return entries, nil
+}
+
+// describeStored classifies a stored PDU by its TP-MTI and extracts the
+// service centre time stamp where the type has one.
+func describeStored(e storedPDU) StoredMessage {
+	msg := StoredMessage{Index: e.index, Status: e.stat, Type: PDUDeliver}
+	t, err := pdu.Type(e.pdu)
+	if err != nil {
+		return msg
+	}
+	msg.Type = storedTypes[t]
+	switch t {
+	case pdu.TypeDeliver:
+		if d, err := pdu.DecodeDeliver(e.pdu); err == nil {
+			msg.Timestamp = d.Timestamp
+		}
+	case pdu.TypeStatusReport:
+		if r, err := pdu.DecodeStatusReport(e.pdu); err == nil {
+			msg.Timestamp = r.Discharge
+		}
+	}
+	return msg
+}
+
+// storedTypes maps PDU types to StoredMessage.Type.
+var storedTypes = map[string]string{
+	pdu.TypeDeliver:      PDUDeliver,
+	pdu.TypeSubmit:       PDUSubmit,
+	pdu.TypeStatusReport: PDUStatusReport,
+}
+
+// parseCPMS reads "+CPMS: <mem1>,<used1>,<total1>,<mem2>,...". Devices
+// that omit the storage names report only the counters.
+func (g *GSMModem) parseCPMS(line string) (*StorageStatus, error) {
+	fields := splitInfoLine(line, "+CPMS:")
+	var usage []MemoryUsage
+	for i := 0; i < len(fields); {
+		var m MemoryUsage
+		if _, err := strconv.Atoi(fields[i]); err != nil {
+			m.Name = g.decodeString(strings.Trim(fields[i], "\""))
+			i++
+		}
+		if i+1 >= len(fields) {
+			break
+		}
+		m.Used, _ = strconv.Atoi(fields[i])
+		m.Total, _ = strconv.Atoi(fields[i+1])
+		usage = append(usage, m)
+		i += 2
+	}
+	if len(usage) == 0 {
+		return nil, fmt.Errorf("malformed CPMS answer: %q", line)
+	}
+	st := &StorageStatus{Read: usage[0], Write: usage[0], Receive: usage[0]}
+	if len(usage) > 1 {
+		st.Write = usage[1]
+	}
+	if len(usage) > 2 {
+		st.Receive = usage[2]
+	}
+	return st, nil
+}
@@ -0,0 +1,30 @@
+package serial
+
+import "testing"
+
+// The init script selects UCS2, so the storage names travel as UCS2 hex.
+func TestStorageInUCS2(t *testing.T) {
+	sim := NewSimulator()
+	g := newTestModem(t, sim)
+	g.Storage = "ME"
+	if err := g.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if n := count(sim.Received(), `AT+CPMS="004D0045","004D0045","004D0045"`); n != 1 {
+		t.Errorf("ME selected %d times in UCS2, commands: %q", n, sim.Received())
+	}
+	st, err := g.QueryStorage()
+	if err != nil {
+		t.Fatalf("QueryStorage: %v", err)
+	}
+	if st.Read.Name != "ME" || st.Receive.Name != "ME" {
+		t.Errorf("storage = %+v, want ME", st)
+	}
+
+	if err := g.SetStorage("sm"); err != nil {
+		t.Fatalf("SetStorage: %v", err)
+	}
+	if st, err := g.QueryStorage(); err != nil || st.Read.Name != "SM" {
+		t.Errorf("after SetStorage: %+v, %v, want SM", st, err)
+	}
+}
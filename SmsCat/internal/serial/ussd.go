@@ -0,0 +1,199 @@
+package serial
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"smallNfast/internal/pdu"
+)
+
+// ussdTimeout bounds the wait for the network's +CUSD answer.
+const ussdTimeout = 30 * time.Second
+
+// USSD result codes (<m> of +CUSD).
+const (
+	USSDDone           = 0 // no further action required
+	USSDActionNeeded   = 1 // the network expects a reply
+	USSDTerminated     = 2 // terminated by the network
+	USSDOtherClient    = 3 // answered by another local client
+	USSDNotSupported   = 4
+	USSDNetworkTimeout = 5
+)
+
+// USSDReply is the network's answer to a USSD request.
+type USSDReply struct {
+	Status int
+	Text   string
+	DCS    int
+}
+
+// USSD sends a USSD request such as "*100#" (AT+CUSD=1,"<code>",15) and
+// waits for the network's answer. A session left open by the network is
+// cancelled so the next request starts fresh.
+func (g *GSMModem) USSD(code string) (*USSDReply, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, fmt.Errorf("USSD: empty code")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.connect(); err != nil {
+		return nil, err
+	}
+
+	// The answer usually arrives as an unsolicited +CUSD after the OK
+	replies := make(chan string, 1)
+	g.OnURC("+CUSD:", false, func(u URC) {
+		select {
+		case replies <- u.Line:
+		default:
+		}
+	})
+	defer g.OnURC("+CUSD:", false, nil)
+
+	resp, err := g.exec(fmt.Sprintf("AT+CUSD=1,\"%s\",15", g.encodeUSSD(code)))
+	if err != nil {
+		var atErr *ATError
+		if !errors.As(err, &atErr) {
+			g.close()
+		}
+		return nil, fmt.Errorf("USSD %s: %w", code, err)
+	}
+
+	line := resp.Line("+CUSD:")
+	if line == "" {
+		select {
+		case line = <-replies:
+		case <-time.After(ussdTimeout):
+			g.exec("AT+CUSD=2") // best effort, the session may be gone
+			return nil, fmt.Errorf("USSD %s: %w after %v", code, ErrTimeout, ussdTimeout)
+		case <-g.at.done:
+			return nil, fmt.Errorf("USSD %s: port closed", code)
+		}
+	}
+
+	reply := g.parseCUSD(line)
+	g.log(fmt.Sprintf("USSD %s → %q", code, reply.Text), true)
+	switch reply.Status {
+	case USSDDone:
+	case USSDActionNeeded:
+		if _, err := g.exec("AT+CUSD=2"); err != nil {
+			g.log(fmt.Sprintf("Warning: closing USSD session failed: %v", err), true)
+		}
+	case USSDNotSupported:
+		return reply, fmt.Errorf("USSD %s: not supported by the network", code)
+	default:
+		if reply.Text == "" {
+			return reply, fmt.Errorf("USSD %s: session ended without an answer (%d)", code, reply.Status)
+		}
+	}
+	return reply, nil
+}
+
+// parseCUSD reads "+CUSD: <m>[,"<str>",<dcs>]". The text may contain
+// commas, so it is taken between the first and the last quote. Replies in
+// a text character set that span several lines only keep the first one.
+func (g *GSMModem) parseCUSD(line string) *USSDReply {
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "+CUSD:"))
+	reply := &USSDReply{DCS: 15}
+
+	status := rest
+	if i := strings.Index(rest, ","); i >= 0 {
+		status, rest = rest[:i], rest[i+1:]
+	} else {
+		rest = ""
+	}
+	reply.Status, _ = strconv.Atoi(strings.TrimSpace(status))
+
+	start := strings.Index(rest, "\"")
+	if start < 0 {
+		return reply
+	}
+	str := rest[start+1:]
+	if end := strings.LastIndex(str, "\""); end >= 0 {
+		if dcs, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(str[end+1:], ","))); err == nil {
+			reply.DCS = dcs
+		}
+		str = str[:end]
+	}
+	reply.Text = g.decodeUSSD(str, reply.DCS)
+	return reply
+}
+
+// encodeUSSD converts a request to what the modem expects: packed GSM 7-bit
+// hex for profiles with USSDPacked, else the TE character set.
+func (g *GSMModem) encodeUSSD(code string) string {
+	if g.profile().USSDPacked {
+		if septets, ok := pdu.EncodeGSM7(code); ok {
+			return strings.ToUpper(hex.EncodeToString(pdu.PackSeptets(septets, 0)))
+		}
+	}
+	return g.encodeString(code)
+}
+
+// decodeUSSD reverses encodeUSSD for the answer. Modems in UCS2 mode
+// convert every answer to UCS2 hex; others pass UCS2 answers through as
+// hex and, with USSDPacked, GSM 7-bit answers as packed hex.
+func (g *GSMModem) decodeUSSD(str string, dcs int) string {
+	alphabet := cbsAlphabet(dcs)
+	switch {
+	case g.profile().USSDPacked && alphabet == pdu.AlphabetGSM7:
+		b, err := hex.DecodeString(str)
+		if err != nil {
+			return str
+		}
+		septets := pdu.UnpackSeptets(b, len(b)*8/7)
+		// A final septet that only fills the last octet is padding
+		if n := len(septets); n > 0 && n%8 == 0 && (septets[n-1] == 0x0D || septets[n-1] == 0x00) {
+			septets = septets[:n-1]
+		}
+		return pdu.DecodeGSM7(septets)
+	case dcs == 0x11:
+		return stripLanguage(str)
+	case g.charset == "UCS2", alphabet == pdu.AlphabetUCS2:
+		return decodeUCS2Hex(str)
+	}
+	return str
+}
+
+// stripLanguage decodes a DCS 0x11 answer without the ISO 639 language
+// in front of it: two letters when the modem converted it to text, else
+// the two octets of packed GSM 7-bit that start the raw hex.
+func stripLanguage(str string) string {
+	text := []rune(decodeUCS2Hex(str))
+	isLetter := func(r rune) bool { return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' }
+	if len(text) >= 2 && isLetter(text[0]) && isLetter(text[1]) {
+		return string(text[2:])
+	}
+	if len(str) >= 4 {
+		return decodeUCS2Hex(str[4:])
+	}
+	return ""
+}
+
+// cbsAlphabet returns the alphabet of a USSD/cell broadcast data coding
+// scheme (3GPP TS 23.038 section 5).
+func cbsAlphabet(dcs int) string {
+	switch {
+	case dcs == 0x11: // UCS2 preceded by a language indication
+		return pdu.AlphabetUCS2
+	case dcs&0xC0 == 0x40, dcs&0xF0 == 0x90: // general data coding, with UDH
+		switch (dcs >> 2) & 0x03 {
+		case 0x01:
+			return pdu.Alphabet8Bit
+		case 0x02:
+			return pdu.AlphabetUCS2
+		}
+	case dcs&0xF0 == 0xF0:
+		if dcs&0x04 != 0 {
+			return pdu.Alphabet8Bit
+		}
+	}
+	return pdu.AlphabetGSM7
+}
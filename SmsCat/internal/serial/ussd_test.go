@@ -0,0 +1,31 @@
+package serial
+
+import "testing"
+
+func TestParseCUSD(t *testing.T) {
+	tests := []struct {
+		name    string
+		charset string
+		line    string
+		status  int
+		dcs     int
+		want    string
+	}{
+		{"text", "GSM", `+CUSD: 0,"Balance 1,234.56 CNY, valid",15`, 0, 15, "Balance 1,234.56 CNY, valid"},
+		{"ucs2", "UCS2", `+CUSD: 0,"4F59989D0031002E0035",72`, 0, 72, "余额1.5"},
+		{"language as packed octets", "GSM", `+CUSD: 0,"653700480069",17`, 0, 17, "Hi"},
+		{"language converted to text", "UCS2", `+CUSD: 0,"007A00684F59989D",17`, 0, 17, "余额"},
+		{"language only", "GSM", `+CUSD: 0,"6537",17`, 0, 17, ""},
+		{"no text", "GSM", `+CUSD: 2`, 2, 15, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGSMModem("SIM0", nil)
+			g.charset = tt.charset
+			r := g.parseCUSD(tt.line)
+			if r.Status != tt.status || r.DCS != tt.dcs || r.Text != tt.want {
+				t.Errorf("parseCUSD(%s) = %d, %q, dcs %d; want %d, %q, dcs %d", tt.line, r.Status, r.Text, r.DCS, tt.status, tt.want, tt.dcs)
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"unsafe"
 
 	"smallNfast/internal/app"
+	"smallNfast/internal/config"
 	"smallNfast/internal/db"
 	filelogger "smallNfast/internal/logger"
 	"smallNfast/internal/monitor"
@@ -167,6 +168,11 @@ func main() {
 	}
 
 	monitorService := monitor.NewService(nil)
+	if settings, err := config.LoadSettings(config.SettingsFile); err != nil {
+		sugar.Warnf("Failed to load %s, using defaults: %v", config.SettingsFile, err)
+	} else {
+		monitorService.Settings = settings
+	}
 	myApp := app.NewApp(monitorService, versionStr)
 
 	// Link App to Systray
@@ -202,9 +208,13 @@ func main() {
 
 				// Auto-Start Monitor (Only after DB is connected)
 				myApp.AddLog("Starting monitor service...")
-				monitorService.Start()
-				myApp.AddLog("Monitor service started")
-				filelogger.Write("DEBUG: Monitor service started")
+				if err := monitorService.Start(); err != nil {
+					sugar.Warnf("Failed to start monitor service: %v", err)
+					myApp.AddLog(fmt.Sprintf("Failed to start monitor service: %v", err))
+				} else {
+					myApp.AddLog("Monitor service started")
+					filelogger.Write("DEBUG: Monitor service started")
+				}
 
 				// Cleanup Test Number
 				if err := db.RemoveRecipientByNumber("18922803837"); err != nil {